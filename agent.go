@@ -2,6 +2,7 @@ package dome
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"connectrpc.com/connect"
@@ -22,6 +23,7 @@ type AgentInfo struct {
 	Status       string
 	Capabilities []string
 	Metadata     map[string]string
+	Runtime      *RuntimeConfig
 	Token        string
 }
 
@@ -37,6 +39,9 @@ type StartOptions struct {
 	ParentID     string
 	Capabilities []string
 	Metadata     map[string]string
+	// Runtime describes where the agent runs. When nil, Start detects it
+	// with DetectRuntime unless WithoutRuntimeDetection was used.
+	Runtime *RuntimeConfig
 }
 
 // Start announces the agent to the Dome control plane and begins background
@@ -45,34 +50,57 @@ type StartOptions struct {
 //
 // If an agent with the same name already exists (CodeAlreadyExists), Start
 // finds the existing agent and returns its info. This makes Start idempotent
-// and safe to call on every startup.
+// and safe to call on every startup. When the capabilities or metadata set
+// in opts differ from the stored agent, Start updates it to match (see
+// WithDriftPolicy to warn or fail instead).
 //
-// On success, Start begins a background heartbeat goroutine (unless
+// On success, Start begins a background goroutine that refreshes the
+// agent's control plane status and sends heartbeats (unless
 // WithoutHeartbeat was used).
 //
 // If WithGracefulDegradation was set and the call fails (e.g. API
 // unreachable), Start logs a warning and retries in the background instead
 // of returning an error. AgentID returns empty until background registration
-// succeeds.
+// succeeds; use WaitRegistered or WithOnRegistered to learn when it does.
+// Background registration then proceeds exactly as a successful Start.
+// Failures that retrying cannot fix are returned regardless: ErrAgentDrift,
+// and requests the control plane rejects as invalid or unauthorized.
 func (c *Client) Start(ctx context.Context, opts StartOptions) (*AgentInfo, error) {
 	if opts.Name == "" {
 		return nil, errorf("agent name is required")
 	}
 
+	// A revoked or suspended agent stays so until the control plane
+	// reports otherwise.
+	c.transitionIf(unblocked, StateRegistering, "registering agent "+opts.Name, nil)
 	info, err := c.doRegister(ctx, opts)
 	if err != nil {
-		if c.config.gracefulDegradation {
+		if c.config.gracefulDegradation && !permanentRegistrationError(err) {
 			c.logger.Warn("registration failed, retrying in background",
 				"agent_name", opts.Name,
 				"error", err,
 			)
+			c.degrade("registration failed, retrying in background", err)
 			c.startBackgroundRegistration(opts)
 			return &AgentInfo{Name: opts.Name}, nil
 		}
+		c.transitionIf(unblocked, StateInitialized, "registration failed", err)
 		return nil, err
 	}
 
+	c.activate(ctx, info)
+	c.startMonitor(info.ID)
+
+	return info, nil
+}
+
+// activate performs the post-registration sequence shared by Start and
+// background registration: it records the agent, flushes queued auth
+// events, starts the policy syncer, emits agent.started and signals
+// WaitRegistered. It does not start the heartbeat.
+func (c *Client) activate(ctx context.Context, info *AgentInfo) {
 	c.setAgentID(info.ID)
+	c.setAgentStatus(agentFromInfo(info), true)
 
 	// Cache agent context for Cedar evaluation and flush pending auth events.
 	c.mu.Lock()
@@ -80,6 +108,7 @@ func (c *Client) Start(ctx context.Context, opts StartOptions) (*AgentInfo, erro
 		ID:           info.ID,
 		Capabilities: info.Capabilities,
 	}
+	c.info = info
 	pendingEvents := c.pendingAuthEvents
 	c.pendingAuthEvents = nil
 	c.mu.Unlock()
@@ -97,11 +126,27 @@ func (c *Client) Start(ctx context.Context, opts StartOptions) (*AgentInfo, erro
 	// Emit agent.started event (fire-and-forget).
 	c.reportEvent(ctx, "agent.started")
 
-	if !c.config.disableHeartbeat {
-		c.startHeartbeat(info.ID)
+	c.registeredOnce.Do(func() { close(c.registered) })
+	if c.config.onRegistered != nil {
+		c.config.onRegistered(info)
 	}
+}
 
-	return info, nil
+// WaitRegistered blocks until the agent is registered and returns its
+// registration. After a successful Start it returns immediately; under
+// WithGracefulDegradation it waits for background registration to succeed.
+// It fails if ctx is done or the client is closed first.
+func (c *Client) WaitRegistered(ctx context.Context) (*AgentInfo, error) {
+	select {
+	case <-c.registered:
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.info, nil
+	case <-c.closed:
+		return nil, errorf("wait for registration: client closed")
+	case <-ctx.Done():
+		return nil, errorf("wait for registration: %w", ctx.Err())
+	}
 }
 
 // Register is a deprecated alias for Start. Use Start instead.
@@ -111,10 +156,13 @@ func (c *Client) Register(ctx context.Context, opts StartOptions) (*AgentInfo, e
 	return c.Start(ctx, opts)
 }
 
-// doRegister performs the actual registration RPC call with idempotency handling.
+// doRegister performs the actual registration RPC call with idempotency
+// handling: an existing agent with the same name is looked up and
+// reconciled with opts according to the configured DriftPolicy.
 func (c *Client) doRegister(ctx context.Context, opts RegisterOptions) (*AgentInfo, error) {
 	req := &apiv1.RegisterAgentRequest{
 		Name:         opts.Name,
+		Description:  opts.Description,
 		Capabilities: opts.Capabilities,
 		Metadata:     opts.Metadata,
 	}
@@ -122,12 +170,25 @@ func (c *Client) doRegister(ctx context.Context, opts RegisterOptions) (*AgentIn
 	if opts.ParentID != "" {
 		req.ParentId = &opts.ParentID
 	}
+	runtime := opts.Runtime
+	if runtime == nil && !c.config.disableRuntimeDetection {
+		runtime = DetectRuntime()
+	}
+	req.Runtime = runtimeToProto(runtime)
 
 	resp, err := c.rpc.RegisterAgent(ctx, connect.NewRequest(req))
 	if err != nil {
 		// Idempotent: if the agent already exists, find it by name.
 		if connect.CodeOf(err) == connect.CodeAlreadyExists {
-			return c.findExistingAgent(ctx, opts.Name)
+			existing, err := c.findExistingAgent(ctx, opts.Name, opts.ParentID)
+			if err != nil {
+				return nil, err
+			}
+			existing, err = c.reconcileAgent(ctx, existing, opts)
+			if err != nil {
+				return nil, err
+			}
+			return agentFromProto(existing, ""), nil
 		}
 		return nil, errorf("register agent: %w", err)
 	}
@@ -136,9 +197,9 @@ func (c *Client) doRegister(ctx context.Context, opts RegisterOptions) (*AgentIn
 }
 
 // startBackgroundRegistration spawns a goroutine that retries registration
-// with exponential backoff. On success, it sets the agent ID and transitions
-// to the heartbeat loop within the same goroutine (avoiding a deadlock with
-// startHeartbeat which would try to cancel/wait on itself).
+// with exponential backoff. On success, it activates the agent and goes on to
+// monitor it within the same goroutine (avoiding a deadlock with
+// startMonitor which would try to cancel/wait on itself).
 func (c *Client) startBackgroundRegistration(opts RegisterOptions) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -155,8 +216,14 @@ func (c *Client) startBackgroundRegistration(opts RegisterOptions) {
 	go func() {
 		defer close(c.stopped)
 
+		var registered *AgentInfo
+		var failed error
 		err := retryWithBackoff(ctx, func(retryCtx context.Context) error {
 			info, regErr := c.doRegister(retryCtx, opts)
+			if permanentRegistrationError(regErr) {
+				failed = regErr
+				return nil
+			}
 			if regErr != nil {
 				c.logger.Debug("background registration retry failed",
 					"agent_name", opts.Name,
@@ -169,7 +236,7 @@ func (c *Client) startBackgroundRegistration(opts RegisterOptions) {
 				"agent_id", info.ID,
 				"agent_name", opts.Name,
 			)
-			c.setAgentID(info.ID)
+			registered = info
 			return nil
 		}, registrationRetryBase, registrationRetryMax)
 
@@ -177,30 +244,72 @@ func (c *Client) startBackgroundRegistration(opts RegisterOptions) {
 			c.logger.Debug("background registration canceled", "error", err)
 			return
 		}
-
-		// Registration succeeded — run heartbeat in this goroutine.
-		if !c.config.disableHeartbeat {
-			c.runHeartbeat(ctx, c.AgentID())
+		if failed != nil {
+			c.logger.Error("dome: background registration failed, not retrying",
+				"agent_name", opts.Name,
+				"error", failed,
+			)
+			c.transitionIf(unblocked, StateInitialized, "registration failed", failed)
+			return
 		}
+
+		// Registration succeeded — finish it like Start and monitor the
+		// agent in this goroutine.
+		c.activate(ctx, registered)
+		c.monitorAgent(ctx, registered.ID)
 	}()
 }
 
-// findExistingAgent looks up an agent by name via ListAgents.
-func (c *Client) findExistingAgent(ctx context.Context, name string) (*AgentInfo, error) {
-	resp, err := c.rpc.ListAgents(ctx, connect.NewRequest(&apiv1.ListAgentsRequest{
-		Limit: 100,
-	}))
-	if err != nil {
-		return nil, errorf("list agents for idempotent registration: %w", err)
+// findExistingAgent looks up an agent by name, among the children of
+// parentID if set and among top-level agents otherwise. The registry has no
+// lookup by name, so it pages through ListAgents until the agent is found.
+// An agent with the name but another parent is never returned, so an agent
+// cannot adopt (and a child later revoke) an agent it does not own.
+func (c *Client) findExistingAgent(ctx context.Context, name, parentID string) (*apiv1.Agent, error) {
+	req := &apiv1.ListAgentsRequest{Limit: defaultAgentPageSize}
+	if parentID != "" {
+		req.ParentId = &parentID
 	}
-
-	for _, a := range resp.Msg.GetAgents() {
-		if a.GetName() == name {
-			return agentFromProto(a, ""), nil
+	foreign := false
+	for {
+		resp, err := c.rpc.ListAgents(ctx, connect.NewRequest(req))
+		if err != nil {
+			return nil, errorf("list agents for idempotent registration: %w", err)
+		}
+		agents := resp.Msg.GetAgents()
+		for _, a := range agents {
+			if a.GetName() != name {
+				continue
+			}
+			if a.GetParentId() != parentID {
+				foreign = true
+				continue
+			}
+			return a, nil
+		}
+		req.Offset += int32(len(agents))
+		if len(agents) == 0 || req.Offset >= resp.Msg.GetTotal() {
+			if foreign {
+				return nil, errorf("agent %q already exists under another parent", name)
+			}
+			return nil, errorf("agent %q already exists but could not be found", name)
 		}
 	}
+}
 
-	return nil, errorf("agent %q already exists but could not be found", name)
+// permanentRegistrationError reports whether retrying registration cannot
+// succeed: the stored agent drifted under DriftFail, or the control plane
+// rejected the request itself.
+func permanentRegistrationError(err error) bool {
+	if errors.Is(err, ErrAgentDrift) {
+		return true
+	}
+	switch connect.CodeOf(err) {
+	case connect.CodeInvalidArgument, connect.CodePermissionDenied, connect.CodeUnauthenticated,
+		connect.CodeFailedPrecondition, connect.CodeOutOfRange, connect.CodeUnimplemented:
+		return true
+	}
+	return false
 }
 
 // agentFromProto converts a protobuf Agent to an AgentInfo.
@@ -214,6 +323,7 @@ func agentFromProto(a *apiv1.Agent, token string) *AgentInfo {
 		Status:       a.GetStatus().String(),
 		Capabilities: a.GetCapabilities(),
 		Metadata:     a.GetMetadata(),
+		Runtime:      runtimeFromProto(a.GetRuntime()),
 		Token:        token,
 	}
 }
@@ -0,0 +1,393 @@
+package dome
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"time"
+
+	"connectrpc.com/connect"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	apiv1 "github.com/Dome-Systems/sdk-dome-go/internal/api"
+)
+
+// defaultAgentPageSize is the page size used by Agents.List.
+const defaultAgentPageSize = 100
+
+// ErrAgentNotFound is returned (wrapped) when an agent does not exist.
+var ErrAgentNotFound = errors.New("dome: agent not found")
+
+// AgentStatus is the lifecycle status of an agent in the control plane.
+type AgentStatus string
+
+// Agent statuses.
+const (
+	AgentStatusUnknown     AgentStatus = ""
+	AgentStatusPending     AgentStatus = "pending"
+	AgentStatusProvisioned AgentStatus = "provisioned"
+	AgentStatusActive      AgentStatus = "active"
+	AgentStatusSuspended   AgentStatus = "suspended"
+	AgentStatusRevoked     AgentStatus = "revoked"
+)
+
+// ConnectionStatus reports whether an agent is heartbeating.
+type ConnectionStatus string
+
+// Connection statuses.
+const (
+	ConnectionStatusUnknown        ConnectionStatus = ""
+	ConnectionStatusConnected      ConnectionStatus = "connected"
+	ConnectionStatusDisconnected   ConnectionStatus = "disconnected"
+	ConnectionStatusNeverConnected ConnectionStatus = "never_connected"
+)
+
+// Agent is an agent record in the control plane.
+type Agent struct {
+	ID           string
+	Name         string
+	TenantID     string
+	ParentID     string
+	Status       AgentStatus
+	Connection   ConnectionStatus
+	Capabilities []string
+	Metadata     map[string]string
+	Runtime      *RuntimeConfig
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	// LastSeenAt is the time of the last heartbeat, or zero if none.
+	LastSeenAt time.Time
+}
+
+// ListAgentsOptions filters and pages Agents.List and Agents.ListPage.
+type ListAgentsOptions struct {
+	// Status restricts the results to agents with this status.
+	Status AgentStatus
+	// ParentID restricts the results to children of this agent.
+	ParentID string
+	// PageSize is the number of agents fetched per request. Defaults to 100.
+	PageSize int
+	// Offset is the index of the first agent returned.
+	Offset int
+}
+
+// AgentPage is one page of ListAgents results.
+type AgentPage struct {
+	Agents []*Agent
+	// Offset is the index of the first agent in Agents.
+	Offset int
+	// Total is the number of agents matching the filter.
+	Total int
+}
+
+// HasMore reports whether agents remain after this page.
+func (p *AgentPage) HasMore() bool {
+	return len(p.Agents) > 0 && p.Offset+len(p.Agents) < p.Total
+}
+
+// AgentUpdate describes changes to an agent. Nil fields are left unchanged.
+type AgentUpdate struct {
+	Name        *string
+	Description *string
+	// Capabilities replaces the agent's capabilities. The control plane
+	// cannot remove them all: an empty, non-nil slice is an error.
+	Capabilities []string
+	// Metadata replaces the agent's metadata. An empty, non-nil map is an
+	// error, as for Capabilities.
+	Metadata map[string]string
+}
+
+// RevokeOptions configures Agents.Revoke.
+type RevokeOptions struct {
+	// Cascade also revokes every descendant of the agent.
+	Cascade bool
+}
+
+// AgentEventType is the kind of change reported by Agents.Watch.
+type AgentEventType string
+
+// Agent event types.
+const (
+	AgentEventRegistered      AgentEventType = "registered"
+	AgentEventUpdated         AgentEventType = "updated"
+	AgentEventRevoked         AgentEventType = "revoked"
+	AgentEventHeartbeatMissed AgentEventType = "heartbeat_missed"
+)
+
+// AgentEvent is a change to an agent in the control plane.
+type AgentEvent struct {
+	Type  AgentEventType
+	Agent *Agent
+	Time  time.Time
+}
+
+// WatchFilter restricts the events delivered by Agents.Watch.
+type WatchFilter struct {
+	// Status restricts events to agents with this status.
+	Status AgentStatus
+	// ParentID restricts events to children of this agent.
+	ParentID string
+}
+
+// Agents is the administrative API for agents in the control plane. It
+// acts with the client's credentials, which must be authorized for the
+// operations used.
+type Agents struct {
+	client *Client
+}
+
+// Agents returns the administrative API for listing, inspecting, updating,
+// revoking and watching agents.
+func (c *Client) Agents() *Agents {
+	return &Agents{client: c}
+}
+
+// Get returns the agent with the given ID. Unknown agents return an error
+// wrapping ErrAgentNotFound.
+func (a *Agents) Get(ctx context.Context, id string) (*Agent, error) {
+	resp, err := a.client.rpc.GetAgent(ctx, connect.NewRequest(&apiv1.GetAgentRequest{Id: id}))
+	if err != nil {
+		return nil, agentError("get agent", id, err)
+	}
+	return agentToSDK(resp.Msg.GetAgent()), nil
+}
+
+// ListPage returns one page of agents.
+func (a *Agents) ListPage(ctx context.Context, opts ListAgentsOptions) (*AgentPage, error) {
+	pageSize := opts.PageSize
+	if pageSize <= 0 {
+		pageSize = defaultAgentPageSize
+	}
+	req := &apiv1.ListAgentsRequest{
+		Limit:  int32(pageSize),
+		Offset: int32(opts.Offset),
+	}
+	if opts.Status != AgentStatusUnknown {
+		status := agentStatusToProto(opts.Status)
+		req.Status = &status
+	}
+	if opts.ParentID != "" {
+		req.ParentId = &opts.ParentID
+	}
+
+	resp, err := a.client.rpc.ListAgents(ctx, connect.NewRequest(req))
+	if err != nil {
+		return nil, errorf("list agents: %w", err)
+	}
+	page := &AgentPage{
+		Agents: make([]*Agent, 0, len(resp.Msg.GetAgents())),
+		Offset: opts.Offset,
+		Total:  int(resp.Msg.GetTotal()),
+	}
+	for _, agent := range resp.Msg.GetAgents() {
+		page.Agents = append(page.Agents, agentToSDK(agent))
+	}
+	return page, nil
+}
+
+// List iterates over every agent matching opts, fetching pages as needed.
+// Iteration stops after the first error, which is yielded with a nil agent.
+//
+//	for agent, err := range client.Agents().List(ctx, dome.ListAgentsOptions{}) {
+//		if err != nil {
+//			return err
+//		}
+//		fmt.Println(agent.Name)
+//	}
+func (a *Agents) List(ctx context.Context, opts ListAgentsOptions) iter.Seq2[*Agent, error] {
+	return func(yield func(*Agent, error) bool) {
+		for {
+			page, err := a.ListPage(ctx, opts)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			for _, agent := range page.Agents {
+				if !yield(agent, nil) {
+					return
+				}
+			}
+			if !page.HasMore() {
+				return
+			}
+			opts.Offset += len(page.Agents)
+		}
+	}
+}
+
+// Update applies changes to an agent and returns the updated record.
+func (a *Agents) Update(ctx context.Context, id string, update AgentUpdate) (*Agent, error) {
+	// An empty list or map on the wire means "unchanged", so a request to
+	// clear one would silently do nothing.
+	if update.Capabilities != nil && len(update.Capabilities) == 0 {
+		return nil, errorf("update agent %s: cannot remove all capabilities", id)
+	}
+	if update.Metadata != nil && len(update.Metadata) == 0 {
+		return nil, errorf("update agent %s: cannot remove all metadata", id)
+	}
+	resp, err := a.client.rpc.UpdateAgent(ctx, connect.NewRequest(&apiv1.UpdateAgentRequest{
+		Id:           id,
+		Name:         update.Name,
+		Description:  update.Description,
+		Capabilities: update.Capabilities,
+		Metadata:     update.Metadata,
+	}))
+	if err != nil {
+		return nil, agentError("update agent", id, err)
+	}
+	return agentToSDK(resp.Msg.GetAgent()), nil
+}
+
+// Revoke revokes an agent, and with opts.Cascade its descendants, and
+// returns the number of agents revoked.
+func (a *Agents) Revoke(ctx context.Context, id string, opts RevokeOptions) (int, error) {
+	resp, err := a.client.rpc.RevokeAgent(ctx, connect.NewRequest(&apiv1.RevokeAgentRequest{
+		Id:                   id,
+		CascadeToDescendants: opts.Cascade,
+	}))
+	if err != nil {
+		return 0, agentError("revoke agent", id, err)
+	}
+	return int(resp.Msg.GetRevokedCount()), nil
+}
+
+// Watch streams changes to agents matching filter until ctx is done or the
+// stream fails. A stream failure is yielded as the final element, with a
+// zero event. Watch does not reconnect.
+func (a *Agents) Watch(ctx context.Context, filter WatchFilter) iter.Seq2[AgentEvent, error] {
+	return func(yield func(AgentEvent, error) bool) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		stream, err := a.client.rpc.WatchAgents(ctx, connect.NewRequest(watchRequest(filter)))
+		if err != nil {
+			yield(AgentEvent{}, errorf("watch agents: %w", err))
+			return
+		}
+		defer func() { _ = stream.Close() }()
+
+		for stream.Receive() {
+			if !yield(agentEventToSDK(stream.Msg()), nil) {
+				return
+			}
+		}
+		if err := stream.Err(); err != nil && ctx.Err() == nil {
+			yield(AgentEvent{}, errorf("watch agents: %w", err))
+		}
+	}
+}
+
+func watchRequest(filter WatchFilter) *apiv1.WatchAgentsRequest {
+	req := &apiv1.WatchAgentsRequest{}
+	if filter.Status != AgentStatusUnknown {
+		status := agentStatusToProto(filter.Status)
+		req.StatusFilter = &status
+	}
+	if filter.ParentID != "" {
+		req.ParentIdFilter = &filter.ParentID
+	}
+	return req
+}
+
+// agentError wraps an RPC error for the agent id, mapping NotFound to
+// ErrAgentNotFound.
+func agentError(op, id string, err error) error {
+	if connect.CodeOf(err) == connect.CodeNotFound {
+		return errorf("%s %s: %w", op, id, ErrAgentNotFound)
+	}
+	return errorf("%s %s: %w", op, id, err)
+}
+
+// agentToSDK converts a protobuf Agent to an Agent.
+func agentToSDK(a *apiv1.Agent) *Agent {
+	if a == nil {
+		return nil
+	}
+	return &Agent{
+		ID:           a.GetId(),
+		Name:         a.GetName(),
+		TenantID:     a.GetTenantId(),
+		ParentID:     a.GetParentId(),
+		Status:       agentStatusFromProto(a.GetStatus()),
+		Connection:   connectionStatusFromProto(a.GetConnectionStatus()),
+		Capabilities: a.GetCapabilities(),
+		Metadata:     a.GetMetadata(),
+		Runtime:      runtimeFromProto(a.GetRuntime()),
+		CreatedAt:    timeFromProto(a.GetCreatedAt()),
+		UpdatedAt:    timeFromProto(a.GetUpdatedAt()),
+		LastSeenAt:   timeFromProto(a.GetLastSeenAt()),
+	}
+}
+
+func agentEventToSDK(e *apiv1.AgentEvent) AgentEvent {
+	var t AgentEventType
+	switch e.GetType() {
+	case apiv1.AgentEventType_AGENT_EVENT_TYPE_REGISTERED:
+		t = AgentEventRegistered
+	case apiv1.AgentEventType_AGENT_EVENT_TYPE_UPDATED:
+		t = AgentEventUpdated
+	case apiv1.AgentEventType_AGENT_EVENT_TYPE_REVOKED:
+		t = AgentEventRevoked
+	case apiv1.AgentEventType_AGENT_EVENT_TYPE_HEARTBEAT_MISSED:
+		t = AgentEventHeartbeatMissed
+	}
+	return AgentEvent{
+		Type:  t,
+		Agent: agentToSDK(e.GetAgent()),
+		Time:  timeFromProto(e.GetTimestamp()),
+	}
+}
+
+func agentStatusFromProto(s apiv1.AgentStatus) AgentStatus {
+	switch s {
+	case apiv1.AgentStatus_AGENT_STATUS_PENDING:
+		return AgentStatusPending
+	case apiv1.AgentStatus_AGENT_STATUS_PROVISIONED:
+		return AgentStatusProvisioned
+	case apiv1.AgentStatus_AGENT_STATUS_ACTIVE:
+		return AgentStatusActive
+	case apiv1.AgentStatus_AGENT_STATUS_SUSPENDED:
+		return AgentStatusSuspended
+	case apiv1.AgentStatus_AGENT_STATUS_REVOKED:
+		return AgentStatusRevoked
+	default:
+		return AgentStatusUnknown
+	}
+}
+
+func agentStatusToProto(s AgentStatus) apiv1.AgentStatus {
+	switch s {
+	case AgentStatusPending:
+		return apiv1.AgentStatus_AGENT_STATUS_PENDING
+	case AgentStatusProvisioned:
+		return apiv1.AgentStatus_AGENT_STATUS_PROVISIONED
+	case AgentStatusActive:
+		return apiv1.AgentStatus_AGENT_STATUS_ACTIVE
+	case AgentStatusSuspended:
+		return apiv1.AgentStatus_AGENT_STATUS_SUSPENDED
+	case AgentStatusRevoked:
+		return apiv1.AgentStatus_AGENT_STATUS_REVOKED
+	default:
+		return apiv1.AgentStatus_AGENT_STATUS_UNSPECIFIED
+	}
+}
+
+func connectionStatusFromProto(s apiv1.ConnectionStatus) ConnectionStatus {
+	switch s {
+	case apiv1.ConnectionStatus_CONNECTION_STATUS_CONNECTED:
+		return ConnectionStatusConnected
+	case apiv1.ConnectionStatus_CONNECTION_STATUS_DISCONNECTED:
+		return ConnectionStatusDisconnected
+	case apiv1.ConnectionStatus_CONNECTION_STATUS_NEVER_CONNECTED:
+		return ConnectionStatusNeverConnected
+	default:
+		return ConnectionStatusUnknown
+	}
+}
+
+func timeFromProto(ts *timestamppb.Timestamp) time.Time {
+	if ts == nil {
+		return time.Time{}
+	}
+	return ts.AsTime()
+}
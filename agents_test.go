@@ -0,0 +1,142 @@
+package dome_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	dome "github.com/Dome-Systems/sdk-dome-go"
+	apiv1 "github.com/Dome-Systems/sdk-dome-go/internal/api"
+	"github.com/Dome-Systems/sdk-dome-go/internal/api/agentv1connect"
+)
+
+// registryServer serves the registry RPCs from a mock handler.
+func registryServer(t *testing.T) (*mockHandler, string) {
+	t.Helper()
+	handler := newMockHandler()
+	return handler, serveRegistry(t, handler)
+}
+
+// serveRegistry serves handler and returns its URL.
+func serveRegistry(t *testing.T, handler agentv1connect.AgentRegistryHandler) string {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.Handle(agentv1connect.NewAgentRegistryHandler(handler))
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func adminClient(t *testing.T, url string) *dome.Client {
+	t.Helper()
+	client, err := dome.NewClient(dome.WithAPIKey("admin-key"), dome.WithAPIURL(url))
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+	return client
+}
+
+func TestAgents_List(t *testing.T) {
+	handler, url := registryServer(t)
+	for i := 0; i < 7; i++ {
+		status := apiv1.AgentStatus_AGENT_STATUS_ACTIVE
+		if i%3 == 0 {
+			status = apiv1.AgentStatus_AGENT_STATUS_SUSPENDED
+		}
+		handler.agents[fmt.Sprintf("a%d", i)] = &apiv1.Agent{Id: fmt.Sprintf("a%d", i), Name: fmt.Sprintf("agent-%d", i), Status: status}
+	}
+	agents := adminClient(t, url).Agents()
+	ctx := context.Background()
+
+	var ids []string
+	for agent, err := range agents.List(ctx, dome.ListAgentsOptions{PageSize: 2}) {
+		if err != nil {
+			t.Fatalf("List error: %v", err)
+		}
+		ids = append(ids, agent.ID)
+	}
+	if fmt.Sprint(ids) != "[a0 a1 a2 a3 a4 a5 a6]" {
+		t.Errorf("listed %v, want all seven agents in order", ids)
+	}
+
+	page, err := agents.ListPage(ctx, dome.ListAgentsOptions{Status: dome.AgentStatusSuspended, PageSize: 2})
+	if err != nil {
+		t.Fatalf("ListPage error: %v", err)
+	}
+	if page.Total != 3 || len(page.Agents) != 2 || !page.HasMore() || page.Agents[0].Status != dome.AgentStatusSuspended {
+		t.Errorf("page = %+v, want 2 of 3 suspended agents", page)
+	}
+
+	// Stopping early does not fetch further pages.
+	for range agents.List(ctx, dome.ListAgentsOptions{PageSize: 1}) {
+		break
+	}
+}
+
+func TestAgents_GetUpdateRevoke(t *testing.T) {
+	handler, url := registryServer(t)
+	parent := "a1"
+	handler.agents["a1"] = &apiv1.Agent{Id: "a1", Name: "planner", Status: apiv1.AgentStatus_AGENT_STATUS_ACTIVE}
+	handler.agents["a2"] = &apiv1.Agent{Id: "a2", Name: "worker", ParentId: &parent, Status: apiv1.AgentStatus_AGENT_STATUS_ACTIVE}
+	agents := adminClient(t, url).Agents()
+	ctx := context.Background()
+
+	agent, err := agents.Get(ctx, "a2")
+	if err != nil {
+		t.Fatalf("Get error: %v", err)
+	}
+	if agent.Name != "worker" || agent.ParentID != "a1" || agent.Status != dome.AgentStatusActive {
+		t.Errorf("agent = %+v", agent)
+	}
+	if _, err := agents.Get(ctx, "missing"); !errors.Is(err, dome.ErrAgentNotFound) {
+		t.Errorf("error = %v, want ErrAgentNotFound", err)
+	}
+
+	name := "senior-worker"
+	agent, err = agents.Update(ctx, "a2", dome.AgentUpdate{Name: &name, Capabilities: []string{"mcp:call"}})
+	if err != nil {
+		t.Fatalf("Update error: %v", err)
+	}
+	if agent.Name != name || len(agent.Capabilities) != 1 {
+		t.Errorf("updated agent = %+v", agent)
+	}
+
+	n, err := agents.Revoke(ctx, "a1", dome.RevokeOptions{Cascade: true})
+	if err != nil {
+		t.Fatalf("Revoke error: %v", err)
+	}
+	if n != 2 || handler.agentStatus("a2") != apiv1.AgentStatus_AGENT_STATUS_REVOKED {
+		t.Errorf("revoked %d agents, want cascade to a2", n)
+	}
+}
+
+func TestAgents_Watch(t *testing.T) {
+	handler, url := registryServer(t)
+	agents := adminClient(t, url).Agents()
+
+	handler.watch <- &apiv1.AgentEvent{
+		Type:  apiv1.AgentEventType_AGENT_EVENT_TYPE_REVOKED,
+		Agent: &apiv1.Agent{Id: "a1", Status: apiv1.AgentStatus_AGENT_STATUS_REVOKED},
+	}
+	handler.watch <- nil // the stream fails
+
+	var events []dome.AgentEvent
+	var streamErr error
+	for e, err := range agents.Watch(context.Background(), dome.WatchFilter{}) {
+		if err != nil {
+			streamErr = err
+			break
+		}
+		events = append(events, e)
+	}
+	if len(events) != 1 || events[0].Type != dome.AgentEventRevoked || events[0].Agent.Status != dome.AgentStatusRevoked {
+		t.Errorf("events = %+v", events)
+	}
+	if streamErr == nil {
+		t.Error("expected the stream failure to be yielded")
+	}
+}
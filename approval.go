@@ -0,0 +1,252 @@
+package dome
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Dome-Systems/sdk-dome-go/internal/approval"
+)
+
+// approvalCancelTimeout bounds withdrawing an abandoned approval request.
+const approvalCancelTimeout = 5 * time.Second
+
+// ApprovalRequest describes an action that policy permits only with human
+// approval.
+type ApprovalRequest struct {
+	AgentID      string
+	Action       string
+	Resource     string
+	ResourceType string
+	Context      map[string]string
+	// Reason is the policy decision that required approval.
+	Reason string
+	// Approvers lists the approver groups named by the @approval
+	// annotations, if any.
+	Approvers     []string
+	PolicyVersion string
+}
+
+// ApprovalResult is the outcome of an approval request.
+type ApprovalResult struct {
+	// ID identifies the approval request.
+	ID       string
+	Approved bool
+	// DecidedBy identifies the person who decided, if known.
+	DecidedBy string
+	Reason    string
+}
+
+// Approver obtains human decisions on approval requests. The default
+// Approver submits requests to the Dome control plane; install another with
+// WithApprover, e.g. a LocalApprover in tests.
+type Approver interface {
+	// Decide submits req and blocks until it is approved or rejected, or
+	// ctx is done.
+	Decide(ctx context.Context, req ApprovalRequest) (*ApprovalResult, error)
+}
+
+// RequestApproval evaluates req like Check and, if policy requires human
+// approval, submits the request to the Approver and waits for the outcome.
+// Set a deadline on ctx to bound the wait; if it passes first,
+// RequestApproval returns ctx's error and the pending request is withdrawn.
+//
+// Decisions that need no approval are returned as Check returns them. A
+// decided request returns a final decision with ApprovalID set: allowed if
+// approved, denied if rejected. Quotas on the action are taken only once it
+// is approved, so an approved request can still be denied with
+// QuotaExceeded. Requests, approvals and rejections are
+// reported to the control plane as "approval.requested",
+// "approval.approved" and "approval.rejected" events.
+func (c *Client) RequestApproval(ctx context.Context, req CheckRequest) (*Decision, error) {
+	decision, err := c.Check(ctx, req)
+	if err != nil || !decision.RequiresApproval {
+		return decision, err
+	}
+
+	agentID := c.AgentID()
+	ar := ApprovalRequest{
+		AgentID:       agentID,
+		Action:        req.Action,
+		Resource:      req.Resource,
+		ResourceType:  req.ResourceType,
+		Context:       req.Context,
+		Reason:        decision.Reason,
+		Approvers:     decision.Approvers,
+		PolicyVersion: decision.PolicyVersion,
+	}
+	c.logger.Info("dome: approval requested", "action", req.Action, "resource", req.Resource, "approvers", decision.Approvers)
+	go c.reportEventData(context.Background(), agentID, "approval.requested", map[string]any{
+		"action":    req.Action,
+		"resource":  req.Resource,
+		"approvers": strings.Join(decision.Approvers, ","),
+	})
+
+	res, err := c.config.approver.Decide(ctx, ar)
+	if err != nil {
+		return nil, errorf("request approval for %s on %s: %w", req.Action, req.Resource, err)
+	}
+
+	final := &Decision{
+		Allowed:       res.Approved,
+		PolicyVersion: decision.PolicyVersion,
+		Approvers:     decision.Approvers,
+		ApprovalID:    res.ID,
+	}
+	eventType := "approval.rejected"
+	if res.Approved {
+		eventType = "approval.approved"
+		final.Reason = "approved"
+	} else {
+		final.Reason = "rejected"
+	}
+	if res.DecidedBy != "" {
+		final.Reason += " by " + res.DecidedBy
+	}
+	if res.Reason != "" {
+		final.Reason += ": " + res.Reason
+	}
+	if res.Approved && len(decision.quotas) > 0 {
+		if q := c.enforceQuotas(ctx, decision.agentID, decision.quotas); q != nil {
+			final.Allowed = false
+			final.QuotaExceeded = true
+			final.Reason = fmt.Sprintf("%s, but quota exceeded: %s (%s)", final.Reason, q.ID, q)
+		}
+	}
+
+	c.logger.Info("dome: approval decided", "approval_id", res.ID, "approved", res.Approved, "decided_by", res.DecidedBy)
+	go c.reportEventData(context.Background(), agentID, eventType, map[string]any{
+		"approval_id": res.ID,
+		"action":      req.Action,
+		"resource":    req.Resource,
+		"decided_by":  res.DecidedBy,
+	})
+	return final, nil
+}
+
+// controlPlaneApprover is the default Approver, backed by the control
+// plane approval API.
+type controlPlaneApprover struct {
+	client *approval.Client
+}
+
+func newControlPlaneApprover(httpClient *http.Client, apiURL string) *controlPlaneApprover {
+	return &controlPlaneApprover{client: approval.NewClient(httpClient, apiURL, approval.DefaultPollInterval)}
+}
+
+func (a *controlPlaneApprover) Decide(ctx context.Context, req ApprovalRequest) (*ApprovalResult, error) {
+	st, err := a.client.Submit(ctx, approval.Request{
+		AgentID:       req.AgentID,
+		Action:        req.Action,
+		Resource:      req.Resource,
+		ResourceType:  req.ResourceType,
+		Context:       req.Context,
+		Reason:        req.Reason,
+		Approvers:     req.Approvers,
+		PolicyVersion: req.PolicyVersion,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !st.Final() {
+		id := st.ID
+		st, err = a.client.Wait(ctx, id)
+		if err != nil {
+			// Withdraw the request so nobody approves an action that
+			// will not happen.
+			cctx, cancel := context.WithTimeout(context.Background(), approvalCancelTimeout)
+			defer cancel()
+			_ = a.client.Cancel(cctx, id)
+			return nil, err
+		}
+	}
+
+	res := &ApprovalResult{
+		ID:        st.ID,
+		Approved:  st.Status == approval.StatusApproved,
+		DecidedBy: st.DecidedBy,
+		Reason:    st.Reason,
+	}
+	if st.Status == approval.StatusExpired && res.Reason == "" {
+		res.Reason = "approval request expired"
+	}
+	return res, nil
+}
+
+// LocalApprover is an in-process Approver for tests and local development.
+// Submitted requests are delivered on Requests and wait until approved or
+// rejected there.
+//
+//	approver := dome.NewLocalApprover()
+//	client, _ := dome.NewClient(dome.WithApprover(approver))
+//	go func() {
+//		for p := range approver.Requests() {
+//			p.Approve("alice", "ok")
+//		}
+//	}()
+type LocalApprover struct {
+	requests chan *PendingApproval
+
+	mu     sync.Mutex
+	nextID int
+}
+
+// NewLocalApprover creates a LocalApprover.
+func NewLocalApprover() *LocalApprover {
+	return &LocalApprover{requests: make(chan *PendingApproval)}
+}
+
+// Requests returns the channel on which submitted requests are delivered.
+func (a *LocalApprover) Requests() <-chan *PendingApproval {
+	return a.requests
+}
+
+// Decide implements Approver.
+func (a *LocalApprover) Decide(ctx context.Context, req ApprovalRequest) (*ApprovalResult, error) {
+	a.mu.Lock()
+	a.nextID++
+	id := "local-" + strconv.Itoa(a.nextID)
+	a.mu.Unlock()
+
+	p := &PendingApproval{ID: id, Request: req, result: make(chan ApprovalResult, 1)}
+	select {
+	case a.requests <- p:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	select {
+	case res := <-p.result:
+		return &res, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// PendingApproval is an approval request awaiting a LocalApprover decision.
+type PendingApproval struct {
+	ID      string
+	Request ApprovalRequest
+
+	once   sync.Once
+	result chan ApprovalResult
+}
+
+// Approve approves the request. Only the first Approve or Reject counts.
+func (p *PendingApproval) Approve(decidedBy, reason string) {
+	p.decide(true, decidedBy, reason)
+}
+
+// Reject rejects the request. Only the first Approve or Reject counts.
+func (p *PendingApproval) Reject(decidedBy, reason string) {
+	p.decide(false, decidedBy, reason)
+}
+
+func (p *PendingApproval) decide(approved bool, decidedBy, reason string) {
+	p.once.Do(func() {
+		p.result <- ApprovalResult{ID: p.ID, Approved: approved, DecidedBy: decidedBy, Reason: reason}
+	})
+}
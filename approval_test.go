@@ -0,0 +1,192 @@
+package dome_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	dome "github.com/Dome-Systems/sdk-dome-go"
+	"github.com/Dome-Systems/sdk-dome-go/internal/api/agentv1connect"
+	"github.com/Dome-Systems/sdk-dome-go/internal/policy"
+)
+
+const approvalCedar = `
+@id("wire-transfer")
+@approval("finance")
+permit(
+    principal is Dome::Agent,
+    action == Dome::Action::"payments:transfer",
+    resource
+);
+
+@id("read")
+permit(
+    principal is Dome::Agent,
+    action == Dome::Action::"payments:read",
+    resource
+);
+`
+
+var approvalBundle = policy.BundleResponse{
+	Version:  "v1",
+	Policies: []policy.PolicyFile{{Filename: "payments.cedar", Content: approvalCedar}},
+}
+
+// approvalClient starts an agent against serverURL with the given options.
+func approvalClient(t *testing.T, serverURL string, opts ...dome.Option) *dome.Client {
+	t.Helper()
+	client, err := dome.NewClient(append([]dome.Option{
+		dome.WithAPIKey("test-key"),
+		dome.WithAPIURL(serverURL),
+		dome.WithoutHeartbeat(),
+	}, opts...)...)
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+	if _, err := client.Start(context.Background(), dome.StartOptions{Name: "approval-agent"}); err != nil {
+		t.Fatalf("Start error: %v", err)
+	}
+	return client
+}
+
+func TestCheck_RequiresApproval(t *testing.T) {
+	client := startedClient(t, testServerWithBundle(t, approvalBundle))
+
+	d, err := client.Check(context.Background(), dome.CheckRequest{Action: "payments:transfer", Resource: "acct-1"})
+	if err != nil {
+		t.Fatalf("Check error: %v", err)
+	}
+	if d.Allowed || !d.RequiresApproval {
+		t.Fatalf("decision = %+v, want not allowed, requiring approval", d)
+	}
+	if len(d.Approvers) != 1 || d.Approvers[0] != "finance" {
+		t.Errorf("Approvers = %v, want [finance]", d.Approvers)
+	}
+	if code := dome.DenialCode(d); code != dome.ErrorCodeApprovalRequired {
+		t.Errorf("DenialCode = %q, want %q", code, dome.ErrorCodeApprovalRequired)
+	}
+}
+
+func TestRequestApproval_LocalApprover(t *testing.T) {
+	approver := dome.NewLocalApprover()
+	client := approvalClient(t, testServerWithBundle(t, approvalBundle), dome.WithApprover(approver))
+	transfer := dome.CheckRequest{Action: "payments:transfer", Resource: "acct-1"}
+
+	go func() {
+		p := <-approver.Requests()
+		if p.Request.Action != "payments:transfer" || p.Request.Approvers[0] != "finance" {
+			t.Errorf("request = %+v", p.Request)
+		}
+		p.Approve("alice", "within limits")
+		(<-approver.Requests()).Reject("bob", "")
+	}()
+
+	d, err := client.RequestApproval(context.Background(), transfer)
+	if err != nil {
+		t.Fatalf("RequestApproval error: %v", err)
+	}
+	if !d.Allowed || d.ApprovalID == "" || d.Reason != "approved by alice: within limits" {
+		t.Errorf("decision = %+v, want approved by alice", d)
+	}
+
+	d, err = client.RequestApproval(context.Background(), transfer)
+	if err != nil {
+		t.Fatalf("RequestApproval error: %v", err)
+	}
+	if d.Allowed || d.Reason != "rejected by bob" {
+		t.Errorf("decision = %+v, want rejected by bob", d)
+	}
+
+	// Decisions that need no approval are returned without asking.
+	d, err = client.RequestApproval(context.Background(), dome.CheckRequest{Action: "payments:read", Resource: "acct-1"})
+	if err != nil || !d.Allowed || d.ApprovalID != "" {
+		t.Errorf("decision = %+v, err = %v, want allowed without approval", d, err)
+	}
+
+	// Nobody answers: the context deadline bounds the wait.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := client.RequestApproval(ctx, transfer); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error = %v, want DeadlineExceeded", err)
+	}
+}
+
+func TestRequestApproval_ControlPlane(t *testing.T) {
+	handler := newMockHandler()
+	mux := http.NewServeMux()
+	path, h := agentv1connect.NewAgentRegistryHandler(handler)
+	mux.Handle(path, h)
+	mux.HandleFunc("/api/v1/policies/bundle", func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(approvalBundle)
+	})
+	mux.HandleFunc("POST /api/v1/approvals", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if body["action"] != "payments:transfer" || body["agent_id"] == "" {
+			t.Errorf("submitted = %v", body)
+		}
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(map[string]string{"id": "apr-7", "status": "pending"})
+	})
+	mux.HandleFunc("GET /api/v1/approvals/apr-7", func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{"id": "apr-7", "status": "rejected", "decided_by": "carol"})
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	client := approvalClient(t, server.URL)
+	d, err := client.RequestApproval(context.Background(), dome.CheckRequest{Action: "payments:transfer", Resource: "acct-1"})
+	if err != nil {
+		t.Fatalf("RequestApproval error: %v", err)
+	}
+	if d.Allowed || d.ApprovalID != "apr-7" || !strings.Contains(d.Reason, "carol") {
+		t.Errorf("decision = %+v, want rejected by carol", d)
+	}
+	waitFor(t, func() bool {
+		events := strings.Join(handler.eventTypes(), " ")
+		return strings.Contains(events, "approval.requested") && strings.Contains(events, "approval.rejected")
+	})
+}
+
+func TestRequestApproval_TakesQuotaOnlyWhenApproved(t *testing.T) {
+	bundle := approvalBundle
+	bundle.Quotas = []policy.QuotaRule{{ID: "transfers", Action: "payments:transfer", Limit: 1, Window: "1h"}}
+	approver := dome.NewLocalApprover()
+	client := approvalClient(t, testServerWithBundle(t, bundle), dome.WithApprover(approver))
+	transfer := dome.CheckRequest{Action: "payments:transfer", Resource: "acct-1"}
+
+	// Asking for approval, and being rejected, uses no quota.
+	for i := 0; i < 3; i++ {
+		if d, _ := client.Check(context.Background(), transfer); !d.RequiresApproval {
+			t.Fatalf("decision = %+v, want approval required", d)
+		}
+	}
+	go func() {
+		(<-approver.Requests()).Reject("bob", "")
+		(<-approver.Requests()).Approve("alice", "")
+		(<-approver.Requests()).Approve("alice", "")
+	}()
+	if d, _ := client.RequestApproval(context.Background(), transfer); d.Allowed {
+		t.Fatalf("decision = %+v, want rejected", d)
+	}
+	if usage := client.QuotaUsage(); len(usage) != 0 {
+		t.Errorf("QuotaUsage before approval = %+v, want none", usage)
+	}
+
+	if d, _ := client.RequestApproval(context.Background(), transfer); !d.Allowed {
+		t.Fatalf("decision = %+v, want approved", d)
+	}
+	d, err := client.RequestApproval(context.Background(), transfer)
+	if err != nil {
+		t.Fatalf("RequestApproval error: %v", err)
+	}
+	if d.Allowed || !d.QuotaExceeded {
+		t.Errorf("decision = %+v, want approved but over quota", d)
+	}
+}
@@ -0,0 +1,182 @@
+package dome
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"connectrpc.com/connect"
+
+	apiv1 "github.com/Dome-Systems/sdk-dome-go/internal/api"
+	"github.com/Dome-Systems/sdk-dome-go/internal/identity"
+	"github.com/Dome-Systems/sdk-dome-go/internal/policy"
+)
+
+// callerCacheTTL is how long a resolved caller's agent record is reused.
+const callerCacheTTL = time.Minute
+
+// ErrUnauthenticated is returned (wrapped) when an inbound request carries
+// no valid Dome identity token.
+var ErrUnauthenticated = errors.New("dome: unauthenticated")
+
+// Caller is the verified identity of another Dome agent calling this one.
+type Caller struct {
+	AgentID      string
+	TenantID     string
+	Name         string
+	Capabilities []string
+	// Claims holds every claim of the caller's identity token.
+	Claims map[string]any
+	// Chain lists the agents that called the caller, outermost first, as
+	// reported in AgentChainHeader. Unlike AgentID, it is not verified.
+	Chain []string
+}
+
+// callerCache caches caller agent records resolved via GetAgent.
+type callerCache struct {
+	mu      sync.Mutex
+	entries map[string]callerEntry
+}
+
+type callerEntry struct {
+	agent   *apiv1.Agent
+	expires time.Time
+}
+
+func (cc *callerCache) get(id string, now time.Time) (*apiv1.Agent, bool) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	e, ok := cc.entries[id]
+	if !ok || now.After(e.expires) {
+		return nil, false
+	}
+	return e.agent, true
+}
+
+func (cc *callerCache) put(id string, agent *apiv1.Agent, now time.Time) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	if cc.entries == nil {
+		cc.entries = make(map[string]callerEntry)
+	}
+	cc.entries[id] = callerEntry{agent: agent, expires: now.Add(callerCacheTTL)}
+}
+
+func (cc *callerCache) invalidate(id string) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	delete(cc.entries, id)
+}
+
+// tokenVerifier returns the verifier for inbound identity tokens, creating
+// it on first use.
+func (c *Client) tokenVerifier() *identity.Verifier {
+	c.verifierOnce.Do(func() {
+		url := c.config.jwksURL
+		if url == "" {
+			url = strings.TrimRight(c.config.apiURL, "/") + "/.well-known/jwks.json"
+		}
+		keys := identity.NewKeySet(c.httpClient, url, identity.DefaultJWKSRefresh)
+		c.verifier = identity.NewVerifier(keys, c.config.tokenIssuer)
+	})
+	return c.verifier
+}
+
+// tokenAudience returns the audience inbound identity tokens must be issued
+// for: the WithTokenAudience value or, by default, this agent's ID.
+func (c *Client) tokenAudience() string {
+	if c.config.tokenAudience != "" {
+		return c.config.tokenAudience
+	}
+	return c.AgentID()
+}
+
+// AuthenticateCaller verifies a Dome identity token against the control
+// plane's signing keys and resolves the calling agent's current attributes.
+// The token must be issued for this agent: its "aud" claim must list the
+// agent's ID, or the audience set with WithTokenAudience, so tokens sent to
+// other agents cannot be replayed here. Until the agent is started, tokens
+// are only accepted with WithTokenAudience. Revoked or suspended callers
+// are rejected.
+func (c *Client) AuthenticateCaller(ctx context.Context, token string) (*Caller, error) {
+	if token == "" {
+		return nil, errorf("%w: missing identity token", ErrUnauthenticated)
+	}
+	audience := c.tokenAudience()
+	if audience == "" {
+		return nil, errorf("%w: agent not started and no token audience configured", ErrUnauthenticated)
+	}
+
+	claims, err := c.tokenVerifier().Verify(ctx, token, audience)
+	if err != nil {
+		return nil, errorf("%w: %v", ErrUnauthenticated, err)
+	}
+
+	agent, err := c.resolveAgent(ctx, claims.AgentID)
+	if err != nil {
+		return nil, err
+	}
+	switch agent.GetStatus() {
+	case apiv1.AgentStatus_AGENT_STATUS_REVOKED, apiv1.AgentStatus_AGENT_STATUS_SUSPENDED:
+		return nil, errorf("%w: caller agent %s is %s", ErrUnauthenticated, claims.AgentID, agent.GetStatus())
+	}
+	if claims.TenantID != "" && agent.GetTenantId() != "" && claims.TenantID != agent.GetTenantId() {
+		return nil, errorf("%w: caller tenant mismatch", ErrUnauthenticated)
+	}
+
+	tenantID := agent.GetTenantId()
+	if tenantID == "" {
+		tenantID = claims.TenantID
+	}
+	return &Caller{
+		AgentID:      claims.AgentID,
+		TenantID:     tenantID,
+		Name:         agent.GetName(),
+		Capabilities: agent.GetCapabilities(),
+		Claims:       claims.Raw,
+	}, nil
+}
+
+// resolveAgent fetches an agent record, using a short-lived cache.
+func (c *Client) resolveAgent(ctx context.Context, id string) (*apiv1.Agent, error) {
+	now := time.Now()
+	if agent, ok := c.callers.get(id, now); ok {
+		return agent, nil
+	}
+
+	resp, err := c.rpc.GetAgent(ctx, connect.NewRequest(&apiv1.GetAgentRequest{Id: id}))
+	if err != nil {
+		if connect.CodeOf(err) == connect.CodeNotFound {
+			return nil, errorf("%w: unknown caller agent %s", ErrUnauthenticated, id)
+		}
+		return nil, errorf("resolve caller agent: %w", err)
+	}
+
+	agent := resp.Msg.GetAgent()
+	c.callers.put(id, agent, now)
+	return agent, nil
+}
+
+// CheckCaller evaluates a policy decision with the given caller, rather than
+// this agent, as the Cedar principal. A non-empty call chain is available to
+// policies as the set context["caller.chain"].
+func (c *Client) CheckCaller(ctx context.Context, caller *Caller, req CheckRequest) (*Decision, error) {
+	if caller == nil {
+		return nil, errorf("caller is required")
+	}
+	if len(caller.Chain) > 0 {
+		attrs := make(map[string]any, len(req.ContextAttributes)+1)
+		for k, v := range req.ContextAttributes {
+			attrs[k] = v
+		}
+		attrs["caller.chain"] = caller.Chain
+		req.ContextAttributes = attrs
+	}
+	return c.checkAs(ctx, policy.AgentContext{
+		ID:           caller.AgentID,
+		TenantID:     caller.TenantID,
+		Capabilities: caller.Capabilities,
+	}, c.blockedStatus(), req)
+}
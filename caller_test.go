@@ -0,0 +1,193 @@
+package dome_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	dome "github.com/Dome-Systems/sdk-dome-go"
+	apiv1 "github.com/Dome-Systems/sdk-dome-go/internal/api"
+	"github.com/Dome-Systems/sdk-dome-go/internal/api/agentv1connect"
+	"github.com/Dome-Systems/sdk-dome-go/internal/policy"
+)
+
+// identityEnv is a control plane stand-in that serves the registry RPCs, a
+// policy bundle and a JWKS document, and can mint identity tokens.
+type identityEnv struct {
+	url     string
+	handler *mockHandler
+	key     *ecdsa.PrivateKey
+}
+
+func newIdentityEnv(t *testing.T, bundle policy.BundleResponse) *identityEnv {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := &identityEnv{handler: newMockHandler(), key: key}
+
+	mux := http.NewServeMux()
+	path, h := agentv1connect.NewAgentRegistryHandler(env.handler)
+	mux.Handle(path, h)
+	mux.HandleFunc("/api/v1/policies/bundle", func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(bundle)
+	})
+	mux.HandleFunc("/.well-known/jwks.json", func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "EC", "crv": "P-256", "kid": "k1", "use": "sig",
+			"x": b64url(key.X.FillBytes(make([]byte, 32))),
+			"y": b64url(key.Y.FillBytes(make([]byte, 32))),
+		}}})
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	env.url = server.URL
+	return env
+}
+
+// addAgent registers an agent directly in the mock registry.
+func (e *identityEnv) addAgent(id string, status apiv1.AgentStatus, capabilities ...string) {
+	e.handler.mu.Lock()
+	defer e.handler.mu.Unlock()
+	e.handler.agents[id] = &apiv1.Agent{
+		Id:           id,
+		Name:         id,
+		TenantId:     "tenant-1",
+		Status:       status,
+		Capabilities: capabilities,
+	}
+}
+
+// token mints an ES256 identity token for agentID, issued for audience.
+func (e *identityEnv) token(t *testing.T, agentID, audience string, ttl time.Duration) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": "ES256", "kid": "k1"})
+	payload, _ := json.Marshal(map[string]any{
+		"sub":       agentID,
+		"aud":       audience,
+		"tenant_id": "tenant-1",
+		"exp":       time.Now().Add(ttl).Unix(),
+	})
+	input := b64url(header) + "." + b64url(payload)
+	digest := sha256.Sum256([]byte(input))
+	r, s, err := ecdsa.Sign(rand.Reader, e.key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig := append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	return input + "." + b64url(sig)
+}
+
+func b64url(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+
+const callerCedar = `
+@id("reports-readers")
+permit(
+    principal is Dome::Agent,
+    action == Dome::Action::"read",
+    resource
+) when {
+    principal.capabilities.contains("reports:read")
+};
+`
+
+func TestMiddleware_AuthorizeCaller(t *testing.T) {
+	env := newIdentityEnv(t, policy.BundleResponse{
+		Version:  "v1",
+		Policies: []policy.PolicyFile{{Filename: "caller.cedar", Content: callerCedar}},
+	})
+	env.addAgent("reader", apiv1.AgentStatus_AGENT_STATUS_ACTIVE, "reports:read")
+	env.addAgent("writer", apiv1.AgentStatus_AGENT_STATUS_ACTIVE, "reports:write")
+	env.addAgent("revoked", apiv1.AgentStatus_AGENT_STATUS_REVOKED, "reports:read")
+
+	// The hosting agent itself lacks reports:read; only the caller matters.
+	client := startedClient(t, env.url)
+	handler := client.MiddlewareWithOptions(okHandler(), dome.MiddlewareOptions{AuthorizeCaller: true})
+	self := client.AgentID()
+
+	tests := []struct {
+		name  string
+		token string
+		want  int
+	}{
+		{"permitted caller", env.token(t, "reader", self, time.Hour), http.StatusOK},
+		{"forbidden caller", env.token(t, "writer", self, time.Hour), http.StatusForbidden},
+		{"revoked caller", env.token(t, "revoked", self, time.Hour), http.StatusUnauthorized},
+		{"unknown caller", env.token(t, "ghost", self, time.Hour), http.StatusUnauthorized},
+		{"expired token", env.token(t, "reader", self, -time.Hour), http.StatusUnauthorized},
+		{"other audience", env.token(t, "reader", "agent-elsewhere", time.Hour), http.StatusUnauthorized},
+		{"no token", "", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/reports/q3", nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d (%s)", rec.Code, tt.want, rec.Body)
+			}
+			if tt.want == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") == "" {
+				t.Error("expected WWW-Authenticate header on 401")
+			}
+		})
+	}
+}
+
+func TestAuthenticateCaller(t *testing.T) {
+	env := newIdentityEnv(t, policy.BundleResponse{})
+	env.addAgent("reader", apiv1.AgentStatus_AGENT_STATUS_ACTIVE, "reports:read")
+
+	client, err := dome.NewClient(dome.WithAPIKey("test-key"), dome.WithAPIURL(env.url), dome.WithoutHeartbeat(),
+		dome.WithTokenAudience("reports-service"))
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	caller, err := client.AuthenticateCaller(context.Background(), env.token(t, "reader", "reports-service", time.Hour))
+	if err != nil {
+		t.Fatalf("AuthenticateCaller error: %v", err)
+	}
+	if caller.AgentID != "reader" || caller.TenantID != "tenant-1" || len(caller.Capabilities) != 1 {
+		t.Errorf("caller = %+v", caller)
+	}
+
+	_, err = client.AuthenticateCaller(context.Background(), "garbage")
+	if !errors.Is(err, dome.ErrUnauthenticated) {
+		t.Errorf("error = %v, want ErrUnauthenticated", err)
+	}
+}
+
+func TestAuthenticateCaller_RequiresAudience(t *testing.T) {
+	env := newIdentityEnv(t, policy.BundleResponse{})
+	env.addAgent("reader", apiv1.AgentStatus_AGENT_STATUS_ACTIVE, "reports:read")
+
+	// Without WithTokenAudience, an agent that has not started has no
+	// audience to verify tokens against.
+	client, err := dome.NewClient(dome.WithAPIKey("test-key"), dome.WithAPIURL(env.url), dome.WithoutHeartbeat())
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	_, err = client.AuthenticateCaller(context.Background(), env.token(t, "reader", "", time.Hour))
+	if !errors.Is(err, dome.ErrUnauthenticated) {
+		t.Errorf("error = %v, want ErrUnauthenticated", err)
+	}
+}
@@ -2,6 +2,7 @@ package dome
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/Dome-Systems/sdk-dome-go/internal/policy"
 )
@@ -17,6 +18,38 @@ type CheckRequest struct {
 	ResourceType string
 	// Context provides additional key-value pairs for policy evaluation.
 	Context map[string]string
+	// ContextAttributes are typed context values, for comparisons that
+	// strings cannot express, e.g. context["llm.max_tokens"] <= 4096. They
+	// accept the same value types as ResourceAttributes and take precedence
+	// over Context entries with the same key.
+	ContextAttributes map[string]any
+	// ResourceAttributes are attached to the resource entity so policies can
+	// reference them, e.g. resource.classification. Values may be strings,
+	// bools, integers (Cedar Long), floats (always Cedar decimal, compared
+	// with decimal("2.5") and lessThan etc.), time.Time, time.Duration,
+	// EntityRef, slices and string-keyed maps. "path" and "type" are
+	// reserved.
+	ResourceAttributes map[string]any
+	// ResourceParents places the resource in entity hierarchies, for use
+	// with Cedar's "in" operator.
+	ResourceParents []EntityRef
+	// Entities supplies additional entities referenced by the request, e.g.
+	// the owner of a document or the MCP server hosting a tool.
+	Entities []Entity
+}
+
+// EntityRef identifies a Cedar entity by type and ID, e.g.
+// EntityRef{Type: "Dome::User", ID: "alice"}.
+type EntityRef struct {
+	Type string
+	ID   string
+}
+
+// Entity is an additional entity supplied with a CheckRequest.
+type Entity struct {
+	UID        EntityRef
+	Attributes map[string]any
+	Parents    []EntityRef
 }
 
 // Decision is the result of a policy evaluation.
@@ -28,12 +61,58 @@ type Decision struct {
 	// PolicyVersion is the version of the policy bundle used for evaluation,
 	// or empty if no policies are loaded.
 	PolicyVersion string
+	// QuotaExceeded is true when policy allowed the action but a rate limit
+	// declared by the bundle or a @quota annotation was exhausted.
+	QuotaExceeded bool
+	// RequiresApproval is true when policy permits the action only with
+	// human approval (an @approval annotation). Allowed is false; use
+	// RequestApproval to obtain a final decision.
+	RequiresApproval bool
+	// Approvers lists the approver groups named by the @approval
+	// annotations, if any.
+	Approvers []string
+	// ApprovalID identifies the approval request behind a decision returned
+	// by RequestApproval.
+	ApprovalID string
+
+	// quotas are the quotas RequestApproval takes once the request behind
+	// a RequiresApproval decision is approved.
+	quotas  []policy.Quota
+	agentID string
 }
 
 // Check evaluates a policy decision against the locally cached Cedar policy
 // bundle. If no policies are loaded (bundle not yet fetched, or policy
 // disabled), Check returns allowed (fail-open for v0.4.0; fail-closed in v1.0).
-func (c *Client) Check(_ context.Context, req CheckRequest) (*Decision, error) {
+//
+// Allowed decisions are subject to the quotas declared in the bundle. Once a
+// quota's token bucket for this agent is exhausted, Check denies with
+// QuotaExceeded set and a "quota exceeded" reason.
+//
+// Actions permitted by a policy annotated with @approval are not allowed
+// outright: Check returns a decision with RequiresApproval set, and the
+// caller obtains a final decision from RequestApproval.
+//
+// While the control plane reports the agent revoked or suspended, Check
+// denies everything without evaluating policy; see WithOnRevoked.
+func (c *Client) Check(ctx context.Context, req CheckRequest) (*Decision, error) {
+	c.mu.Lock()
+	agentCtx := c.agentCtx
+	c.mu.Unlock()
+
+	return c.checkAs(ctx, agentCtx, c.blockedStatus(), req)
+}
+
+// checkAs evaluates req with agentCtx as the Cedar principal. blocked is
+// the principal's status if it is revoked or suspended, in which case req
+// is denied, or AgentStatusUnknown.
+func (c *Client) checkAs(ctx context.Context, agentCtx policy.AgentContext, blocked AgentStatus, req CheckRequest) (*Decision, error) {
+	if blocked != AgentStatusUnknown {
+		return &Decision{
+			Allowed: false,
+			Reason:  fmt.Sprintf("agent is %s", blocked),
+		}, nil
+	}
 	if c.config.disablePolicy || !c.policyEngine.HasPolicies() {
 		return &Decision{
 			Allowed: true,
@@ -41,10 +120,6 @@ func (c *Client) Check(_ context.Context, req CheckRequest) (*Decision, error) {
 		}, nil
 	}
 
-	c.mu.Lock()
-	agentCtx := c.agentCtx
-	c.mu.Unlock()
-
 	// Determine required capability — defaults to the action itself.
 	requiredCap := req.Action
 	if req.Context != nil {
@@ -59,12 +134,104 @@ func (c *Client) Check(_ context.Context, req CheckRequest) (*Decision, error) {
 		ResourceType:       req.ResourceType,
 		RequiredCapability: requiredCap,
 		Context:            req.Context,
+		ContextAttributes:  toPolicyAttributes(req.ContextAttributes),
+		ResourceAttributes: toPolicyAttributes(req.ResourceAttributes),
+		ResourceParents:    toPolicyRefs(req.ResourceParents),
+		Entities:           toPolicyEntities(req.Entities),
+	}
+	if err := input.Validate(); err != nil {
+		return nil, errorf("check: %w", err)
 	}
 
 	d := c.policyEngine.Evaluate(agentCtx, input)
+	if d.Allow && d.RequiresApproval {
+		// No quota is taken for a request that may yet be rejected;
+		// RequestApproval takes it once the request is approved.
+		return &Decision{
+			Allowed:          false,
+			Reason:           d.Reason,
+			PolicyVersion:    d.PolicyVersion,
+			RequiresApproval: true,
+			Approvers:        d.Approvers,
+			quotas:           d.Quotas,
+			agentID:          agentCtx.ID,
+		}, nil
+	}
+	if d.Allow && len(d.Quotas) > 0 {
+		if q := c.enforceQuotas(ctx, agentCtx.ID, d.Quotas); q != nil {
+			return &Decision{
+				Allowed:       false,
+				Reason:        fmt.Sprintf("quota exceeded: %s (%s)", q.ID, q),
+				PolicyVersion: d.PolicyVersion,
+				QuotaExceeded: true,
+			}, nil
+		}
+	}
 	return &Decision{
 		Allowed:       d.Allow,
 		Reason:        d.Reason,
 		PolicyVersion: d.PolicyVersion,
 	}, nil
 }
+
+func toPolicyRef(r EntityRef) policy.EntityRef {
+	return policy.EntityRef{Type: r.Type, ID: r.ID}
+}
+
+func toPolicyRefs(refs []EntityRef) []policy.EntityRef {
+	if len(refs) == 0 {
+		return nil
+	}
+	out := make([]policy.EntityRef, len(refs))
+	for i, r := range refs {
+		out[i] = toPolicyRef(r)
+	}
+	return out
+}
+
+func toPolicyEntities(entities []Entity) []policy.EntityInput {
+	if len(entities) == 0 {
+		return nil
+	}
+	out := make([]policy.EntityInput, len(entities))
+	for i, e := range entities {
+		out[i] = policy.EntityInput{
+			UID:        toPolicyRef(e.UID),
+			Attributes: toPolicyAttributes(e.Attributes),
+			Parents:    toPolicyRefs(e.Parents),
+		}
+	}
+	return out
+}
+
+// toPolicyAttributes rewrites public EntityRef values (at any depth) to their
+// internal equivalents so the policy engine can convert them.
+func toPolicyAttributes(attrs map[string]any) map[string]any {
+	if len(attrs) == 0 {
+		return nil
+	}
+	out := make(map[string]any, len(attrs))
+	for k, v := range attrs {
+		out[k] = toPolicyValue(v)
+	}
+	return out
+}
+
+func toPolicyValue(v any) any {
+	switch x := v.(type) {
+	case EntityRef:
+		return toPolicyRef(x)
+	case []EntityRef:
+		return toPolicyRefs(x)
+	case []any:
+		out := make([]any, len(x))
+		for i, item := range x {
+			out[i] = toPolicyValue(item)
+		}
+		return out
+	case map[string]any:
+		return toPolicyAttributes(x)
+	default:
+		return v
+	}
+}
@@ -0,0 +1,239 @@
+package dome_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	dome "github.com/Dome-Systems/sdk-dome-go"
+	"github.com/Dome-Systems/sdk-dome-go/internal/api/agentv1connect"
+	"github.com/Dome-Systems/sdk-dome-go/internal/policy"
+)
+
+// testServerWithBundle creates a test server that serves both the agent
+// registry RPCs and the given policy bundle.
+func testServerWithBundle(t *testing.T, bundle policy.BundleResponse) string {
+	t.Helper()
+
+	handler := newMockHandler()
+	mux := http.NewServeMux()
+	path, h := agentv1connect.NewAgentRegistryHandler(handler)
+	mux.Handle(path, h)
+	mux.HandleFunc("/api/v1/policies/bundle", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(bundle)
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return server.URL
+}
+
+// startedClient creates a client against serverURL and starts an agent with
+// the given capabilities.
+func startedClient(t *testing.T, serverURL string, capabilities ...string) *dome.Client {
+	t.Helper()
+
+	client, err := dome.NewClient(
+		dome.WithAPIKey("test-key"),
+		dome.WithAPIURL(serverURL),
+		dome.WithoutHeartbeat(),
+	)
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+
+	if _, err := client.Start(context.Background(), dome.StartOptions{
+		Name:         "check-agent",
+		Capabilities: capabilities,
+	}); err != nil {
+		t.Fatalf("Start error: %v", err)
+	}
+	return client
+}
+
+const permitLLMCedar = `
+@id("llm-chat")
+permit(
+    principal is Dome::Agent,
+    action == Dome::Action::"llm:chat",
+    resource
+);
+`
+
+func TestCheck_QuotaExceeded(t *testing.T) {
+	serverURL := testServerWithBundle(t, policy.BundleResponse{
+		Version:  "v1",
+		Policies: []policy.PolicyFile{{Filename: "llm.cedar", Content: permitLLMCedar}},
+		Quotas: []policy.QuotaRule{
+			{ID: "llm-per-minute", Action: "llm:chat", Limit: 2, Window: "1m"},
+		},
+	})
+	client := startedClient(t, serverURL)
+
+	req := dome.CheckRequest{Action: "llm:chat", Resource: "openai/gpt-4"}
+	for i := 0; i < 2; i++ {
+		d, err := client.Check(context.Background(), req)
+		if err != nil {
+			t.Fatalf("Check error: %v", err)
+		}
+		if !d.Allowed {
+			t.Fatalf("call %d: expected allow, got deny: %s", i, d.Reason)
+		}
+	}
+
+	d, err := client.Check(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Check error: %v", err)
+	}
+	if d.Allowed || !d.QuotaExceeded {
+		t.Fatalf("expected quota denial, got allowed=%v quotaExceeded=%v", d.Allowed, d.QuotaExceeded)
+	}
+	if !strings.HasPrefix(d.Reason, "quota exceeded: llm-per-minute") {
+		t.Errorf("Reason = %q, want quota exceeded prefix", d.Reason)
+	}
+
+	usage := client.QuotaUsage()
+	if len(usage) != 1 || usage[0].Allowed != 2 || usage[0].Denied != 1 {
+		t.Errorf("QuotaUsage = %+v, want 2 allowed and 1 denied", usage)
+	}
+}
+
+func TestCheck_QuotaDenialRefundsEarlierQuotas(t *testing.T) {
+	serverURL := testServerWithBundle(t, policy.BundleResponse{
+		Version:  "v1",
+		Policies: []policy.PolicyFile{{Filename: "llm.cedar", Content: permitLLMCedar}},
+		Quotas: []policy.QuotaRule{
+			{ID: "a-per-minute", Action: "llm:chat", Limit: 2, Window: "1m"},
+			{ID: "b-gpt4-per-minute", Action: "llm:chat", Resource: "openai/gpt-4", Limit: 1, Window: "1m"},
+		},
+	})
+	client := startedClient(t, serverURL)
+
+	gpt4 := dome.CheckRequest{Action: "llm:chat", Resource: "openai/gpt-4"}
+	if d, _ := client.Check(context.Background(), gpt4); !d.Allowed {
+		t.Fatalf("expected first call allowed, got: %s", d.Reason)
+	}
+	for i := 0; i < 3; i++ {
+		if d, _ := client.Check(context.Background(), gpt4); !d.QuotaExceeded {
+			t.Fatalf("call %d: expected gpt-4 quota denial, got: %+v", i, d)
+		}
+	}
+
+	// The denied calls must not have used up the shared quota.
+	other := dome.CheckRequest{Action: "llm:chat", Resource: "openai/gpt-4o-mini"}
+	if d, _ := client.Check(context.Background(), other); !d.Allowed {
+		t.Fatalf("expected shared quota to have capacity, got: %s", d.Reason)
+	}
+
+	usage := client.QuotaUsage()
+	if len(usage) != 2 || usage[0].Allowed != 2 || usage[0].Denied != 0 || usage[1].Allowed != 1 || usage[1].Denied != 3 {
+		t.Errorf("QuotaUsage = %+v", usage)
+	}
+}
+
+type countingBackend struct{ calls int }
+
+func (b *countingBackend) Take(_ context.Context, _ string, _ dome.QuotaLimit) (bool, error) {
+	b.calls++
+	return b.calls <= 1, nil
+}
+
+func (b *countingBackend) Refund(context.Context, string, dome.QuotaLimit) error { return nil }
+
+func TestCheck_CustomQuotaBackend(t *testing.T) {
+	serverURL := testServerWithBundle(t, policy.BundleResponse{
+		Version: "v1",
+		Policies: []policy.PolicyFile{{
+			Filename: "llm.cedar",
+			Content:  "@quota(\"100/1m\")\n" + permitLLMCedar,
+		}},
+	})
+
+	backend := &countingBackend{}
+	client, err := dome.NewClient(
+		dome.WithAPIKey("test-key"),
+		dome.WithAPIURL(serverURL),
+		dome.WithoutHeartbeat(),
+		dome.WithQuotaBackend(backend),
+	)
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+	if _, err := client.Start(context.Background(), dome.StartOptions{Name: "backend-agent"}); err != nil {
+		t.Fatalf("Start error: %v", err)
+	}
+
+	req := dome.CheckRequest{Action: "llm:chat", Resource: "openai/gpt-4"}
+	if d, _ := client.Check(context.Background(), req); !d.Allowed {
+		t.Fatalf("expected first call allowed, got: %s", d.Reason)
+	}
+	if d, _ := client.Check(context.Background(), req); !d.QuotaExceeded {
+		t.Fatalf("expected backend to exhaust quota, got: %+v", d)
+	}
+	if backend.calls != 2 {
+		t.Errorf("backend calls = %d, want 2", backend.calls)
+	}
+}
+
+const classificationCedar = `
+@id("no-restricted")
+forbid(
+    principal,
+    action,
+    resource
+) when {
+    resource has classification && resource.classification == "restricted"
+};
+
+@id("allow-all")
+permit(principal, action, resource);
+`
+
+func TestCheck_ResourceAttributes(t *testing.T) {
+	serverURL := testServerWithBundle(t, policy.BundleResponse{
+		Version:  "v1",
+		Policies: []policy.PolicyFile{{Filename: "docs.cedar", Content: classificationCedar}},
+	})
+	client := startedClient(t, serverURL)
+
+	d, err := client.Check(context.Background(), dome.CheckRequest{
+		Action:             "read",
+		Resource:           "docs/plan.md",
+		ResourceAttributes: map[string]any{"classification": "restricted"},
+	})
+	if err != nil {
+		t.Fatalf("Check error: %v", err)
+	}
+	if d.Allowed {
+		t.Error("expected restricted document to be denied")
+	}
+
+	d, err = client.Check(context.Background(), dome.CheckRequest{
+		Action:             "read",
+		Resource:           "docs/plan.md",
+		ResourceAttributes: map[string]any{"classification": "public", "owner": dome.EntityRef{Type: "Dome::User", ID: "alice"}},
+		Entities:           []dome.Entity{{UID: dome.EntityRef{Type: "Dome::User", ID: "alice"}}},
+	})
+	if err != nil {
+		t.Fatalf("Check error: %v", err)
+	}
+	if !d.Allowed {
+		t.Errorf("expected public document to be allowed, got: %s", d.Reason)
+	}
+
+	_, err = client.Check(context.Background(), dome.CheckRequest{
+		Action:             "read",
+		Resource:           "docs/plan.md",
+		ResourceAttributes: map[string]any{"type": "override"},
+	})
+	if err == nil {
+		t.Error("expected error for reserved resource attribute")
+	}
+}
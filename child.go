@@ -0,0 +1,242 @@
+package dome
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"connectrpc.com/connect"
+
+	apiv1 "github.com/Dome-Systems/sdk-dome-go/internal/api"
+	"github.com/Dome-Systems/sdk-dome-go/internal/policy"
+)
+
+// childRevokeTimeout bounds revoking a child agent on Close.
+const childRevokeTimeout = 10 * time.Second
+
+// ChildOptions configures a child agent spawned by SpawnChild.
+type ChildOptions struct {
+	Name        string
+	Description string
+	// Capabilities must be a subset of the parent agent's capabilities.
+	Capabilities []string
+	Metadata     map[string]string
+}
+
+// ChildAgent is a sub-agent registered under a parent agent with a subset
+// of the parent's capabilities. It evaluates policy as itself, sends its own
+// heartbeats and tracks its own control plane status until closed.
+type ChildAgent struct {
+	parent   *Client
+	info     *AgentInfo
+	agentCtx policy.AgentContext
+
+	mu     sync.Mutex
+	status AgentStatus // control plane status, as last observed
+
+	cancel  func()
+	stopped chan struct{}
+	once    sync.Once
+	err     error
+}
+
+// childSet tracks the open children of a client.
+type childSet struct {
+	mu       sync.Mutex
+	children map[*ChildAgent]struct{}
+}
+
+func (s *childSet) add(ch *ChildAgent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.children == nil {
+		s.children = make(map[*ChildAgent]struct{})
+	}
+	s.children[ch] = struct{}{}
+}
+
+func (s *childSet) remove(ch *ChildAgent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.children, ch)
+}
+
+// all returns the open children.
+func (s *childSet) all() []*ChildAgent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]*ChildAgent, 0, len(s.children))
+	for ch := range s.children {
+		out = append(out, ch)
+	}
+	return out
+}
+
+// SpawnChild registers a child agent under this agent and returns a handle
+// for it. The child's capabilities must be a subset of this agent's; asking
+// for any other capability is an error and registers nothing. Start must
+// have completed first.
+//
+// The child heartbeats on its own (unless WithoutHeartbeat was used), has
+// its status refreshed at the heartbeat interval, and is revoked, together
+// with its descendants, when it or this client is closed.
+func (c *Client) SpawnChild(ctx context.Context, opts ChildOptions) (*ChildAgent, error) {
+	if opts.Name == "" {
+		return nil, errorf("child agent name is required")
+	}
+
+	c.mu.Lock()
+	parentCtx := c.agentCtx
+	c.mu.Unlock()
+	if parentCtx.ID == "" {
+		return nil, errorf("spawn child %s: parent agent is not registered", opts.Name)
+	}
+	for _, capability := range opts.Capabilities {
+		if !slices.Contains(parentCtx.Capabilities, capability) {
+			return nil, errorf("spawn child %s: capability %q is not held by parent agent %s", opts.Name, capability, parentCtx.ID)
+		}
+	}
+
+	info, err := c.doRegister(ctx, StartOptions{
+		Name:         opts.Name,
+		Description:  opts.Description,
+		ParentID:     parentCtx.ID,
+		Capabilities: opts.Capabilities,
+		Metadata:     opts.Metadata,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ch := &ChildAgent{
+		parent: c,
+		info:   info,
+		// Evaluate with the requested capabilities even if an existing
+		// registration reports more.
+		agentCtx: policy.AgentContext{
+			ID:           info.ID,
+			TenantID:     parentCtx.TenantID,
+			Capabilities: slices.Clone(opts.Capabilities),
+		},
+	}
+	ch.status = agentFromInfo(info).Status
+	monCtx, cancel := context.WithCancel(context.Background())
+	ch.cancel = cancel
+	ch.stopped = make(chan struct{})
+	go func() {
+		defer close(ch.stopped)
+		ch.monitor(monCtx)
+	}()
+	c.children.add(ch)
+
+	c.logger.Info("dome: child agent spawned", "agent_id", info.ID, "parent_id", parentCtx.ID, "agent_name", opts.Name)
+	c.reportEventData(ctx, info.ID, "agent.started", map[string]any{"parent_id": parentCtx.ID})
+	return ch, nil
+}
+
+// ID returns the child agent's ID.
+func (ch *ChildAgent) ID() string { return ch.info.ID }
+
+// Info returns the child agent's registration.
+func (ch *ChildAgent) Info() *AgentInfo { return ch.info }
+
+// Check evaluates a policy decision with the child agent as the Cedar
+// principal. It behaves like Client.Check, but denies everything while the
+// child or its parent is revoked or suspended. The child's capabilities
+// are limited to those the parent holds now, so a later Client.Update that
+// drops a capability from the parent drops it from its children too.
+func (ch *ChildAgent) Check(ctx context.Context, req CheckRequest) (*Decision, error) {
+	c := ch.parent
+	c.mu.Lock()
+	parentCaps := c.agentCtx.Capabilities
+	c.mu.Unlock()
+
+	agentCtx := ch.agentCtx
+	agentCtx.Capabilities = slices.DeleteFunc(slices.Clone(agentCtx.Capabilities), func(capability string) bool {
+		return !slices.Contains(parentCaps, capability)
+	})
+
+	blocked := ch.blockedStatus()
+	if blocked == AgentStatusUnknown {
+		blocked = c.blockedStatus()
+	}
+	return c.checkAs(ctx, agentCtx, blocked, req)
+}
+
+// monitor sends the child's heartbeats, unless WithoutHeartbeat was used,
+// and refreshes its status at the heartbeat interval until ctx is
+// canceled.
+func (ch *ChildAgent) monitor(ctx context.Context) {
+	c := ch.parent
+	var wg sync.WaitGroup
+	if !c.config.disableHeartbeat {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.runHeartbeat(ctx, ch.info.ID, false)
+		}()
+	}
+
+	ticker := time.NewTicker(c.config.heartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		case <-ticker.C:
+			ch.refreshStatus(ctx)
+		}
+	}
+}
+
+// blockedStatus returns the child's status if it is revoked or suspended,
+// or AgentStatusUnknown otherwise.
+func (ch *ChildAgent) blockedStatus() AgentStatus {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	if ch.status == AgentStatusRevoked || ch.status == AgentStatusSuspended {
+		return ch.status
+	}
+	return AgentStatusUnknown
+}
+
+// refreshStatus fetches the child's status from the control plane.
+func (ch *ChildAgent) refreshStatus(ctx context.Context) {
+	agent, ok := ch.parent.fetchAgentStatus(ctx, ch.info.ID)
+	if !ok {
+		return
+	}
+	ch.mu.Lock()
+	from := ch.status
+	ch.status = agent.Status
+	ch.mu.Unlock()
+	if from != agent.Status {
+		ch.parent.logger.Info("dome: child agent status changed", "agent_id", ch.info.ID, "from", from, "to", agent.Status)
+	}
+}
+
+// Close stops the child's heartbeat and revokes it and its descendants in
+// the control plane. It is safe to call Close multiple times.
+func (ch *ChildAgent) Close() error {
+	ch.once.Do(func() {
+		ch.parent.children.remove(ch)
+		ch.cancel()
+		<-ch.stopped
+
+		ctx, cancel := context.WithTimeout(context.Background(), childRevokeTimeout)
+		defer cancel()
+		_, err := ch.parent.rpc.RevokeAgent(ctx, connect.NewRequest(&apiv1.RevokeAgentRequest{
+			Id:                   ch.info.ID,
+			CascadeToDescendants: true,
+		}))
+		if err != nil {
+			ch.parent.logger.Warn("dome: child agent revocation failed", "agent_id", ch.info.ID, "error", err)
+			ch.err = errorf("revoke child agent %s: %w", ch.info.ID, err)
+			return
+		}
+		ch.parent.logger.Info("dome: child agent revoked", "agent_id", ch.info.ID)
+	})
+	return ch.err
+}
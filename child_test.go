@@ -0,0 +1,204 @@
+package dome_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+
+	dome "github.com/Dome-Systems/sdk-dome-go"
+	apiv1 "github.com/Dome-Systems/sdk-dome-go/internal/api"
+	"github.com/Dome-Systems/sdk-dome-go/internal/api/agentv1connect"
+	"github.com/Dome-Systems/sdk-dome-go/internal/policy"
+)
+
+const capabilityCedar = `
+@id("by-capability")
+permit(
+    principal is Dome::Agent,
+    action,
+    resource
+) when { principal.capabilities.contains(context.required_capability) };
+`
+
+func TestSpawnChild(t *testing.T) {
+	handler := newMockHandler()
+	mux := http.NewServeMux()
+	path, h := agentv1connect.NewAgentRegistryHandler(handler)
+	mux.Handle(path, h)
+	mux.HandleFunc("/api/v1/policies/bundle", func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(policy.BundleResponse{
+			Version:  "v1",
+			Policies: []policy.PolicyFile{{Filename: "caps.cedar", Content: capabilityCedar}},
+		})
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	client, err := dome.NewClient(dome.WithAPIKey("test-key"), dome.WithAPIURL(server.URL))
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+	parent, err := client.Start(context.Background(), dome.StartOptions{
+		Name:         "parent",
+		Capabilities: []string{"llm:chat", "mcp:call"},
+	})
+	if err != nil {
+		t.Fatalf("Start error: %v", err)
+	}
+	ctx := context.Background()
+
+	if _, err := client.SpawnChild(ctx, dome.ChildOptions{Name: "escalating", Capabilities: []string{"mcp:call", "admin"}}); err == nil {
+		t.Fatal("expected error for a capability the parent lacks")
+	}
+
+	child, err := client.SpawnChild(ctx, dome.ChildOptions{Name: "researcher", Capabilities: []string{"mcp:call"}})
+	if err != nil {
+		t.Fatalf("SpawnChild error: %v", err)
+	}
+	sibling, err := client.SpawnChild(ctx, dome.ChildOptions{Name: "helper"})
+	if err != nil {
+		t.Fatalf("SpawnChild error: %v", err)
+	}
+
+	chat := dome.CheckRequest{Action: "llm:chat", Resource: "openai/gpt-4"}
+	if d, _ := client.Check(ctx, chat); !d.Allowed {
+		t.Errorf("parent llm:chat denied: %s", d.Reason)
+	}
+	if d, _ := child.Check(ctx, chat); d.Allowed {
+		t.Error("child llm:chat allowed, want denied")
+	}
+	if d, _ := child.Check(ctx, dome.CheckRequest{Action: "mcp:call", Resource: "hr/get"}); !d.Allowed {
+		t.Errorf("child mcp:call denied: %s", d.Reason)
+	}
+
+	waitFor(t, func() bool { return handler.heartbeatCount(child.ID()) > 0 })
+
+	if err := client.Close(); err != nil {
+		t.Fatalf("Close error: %v", err)
+	}
+	for _, id := range []string{child.ID(), sibling.ID()} {
+		if s := handler.agentStatus(id); s != apiv1.AgentStatus_AGENT_STATUS_REVOKED {
+			t.Errorf("agent %s status = %v, want revoked", id, s)
+		}
+	}
+	if s := handler.agentStatus(parent.ID); s == apiv1.AgentStatus_AGENT_STATUS_REVOKED {
+		t.Error("parent revoked on Close")
+	}
+}
+
+// unfilteredLister is a registry that ignores the parent filter of
+// ListAgents.
+type unfilteredLister struct {
+	*mockHandler
+}
+
+func (h unfilteredLister) ListAgents(ctx context.Context, req *connect.Request[apiv1.ListAgentsRequest]) (*connect.Response[apiv1.ListAgentsResponse], error) {
+	req.Msg.ParentId = nil
+	return h.mockHandler.ListAgents(ctx, req)
+}
+
+func TestSpawnChild_DoesNotAdoptForeignAgent(t *testing.T) {
+	handler := newMockHandler()
+	mux := http.NewServeMux()
+	path, h := agentv1connect.NewAgentRegistryHandler(unfilteredLister{handler})
+	mux.Handle(path, h)
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	other, err := dome.NewClient(dome.WithAPIKey("test-key"), dome.WithAPIURL(server.URL), dome.WithoutHeartbeat(), dome.WithoutPolicy())
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	t.Cleanup(func() { _ = other.Close() })
+	if _, err := other.Start(context.Background(), dome.StartOptions{Name: "other-parent"}); err != nil {
+		t.Fatalf("Start error: %v", err)
+	}
+	theirs, err := other.SpawnChild(context.Background(), dome.ChildOptions{Name: "researcher"})
+	if err != nil {
+		t.Fatalf("SpawnChild error: %v", err)
+	}
+
+	client, err := dome.NewClient(dome.WithAPIKey("test-key"), dome.WithAPIURL(server.URL), dome.WithoutHeartbeat(), dome.WithoutPolicy())
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	if _, err := client.Start(context.Background(), dome.StartOptions{Name: "parent"}); err != nil {
+		t.Fatalf("Start error: %v", err)
+	}
+	if _, err := client.SpawnChild(context.Background(), dome.ChildOptions{Name: "researcher"}); err == nil {
+		t.Fatal("expected error spawning a child named like another parent's child")
+	}
+	_ = client.Close()
+	if s := handler.agentStatus(theirs.ID()); s == apiv1.AgentStatus_AGENT_STATUS_REVOKED {
+		t.Error("another parent's child revoked")
+	}
+}
+
+func TestSpawnChild_OwnStatusAndParentCapabilities(t *testing.T) {
+	handler := newMockHandler()
+	mux := http.NewServeMux()
+	path, h := agentv1connect.NewAgentRegistryHandler(handler)
+	mux.Handle(path, h)
+	mux.HandleFunc("/api/v1/policies/bundle", func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(policy.BundleResponse{
+			Version:  "v1",
+			Policies: []policy.PolicyFile{{Filename: "caps.cedar", Content: capabilityCedar}},
+		})
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	// Without heartbeats the child's status is still refreshed.
+	client, err := dome.NewClient(
+		dome.WithAPIKey("test-key"),
+		dome.WithAPIURL(server.URL),
+		dome.WithHeartbeatInterval(20*time.Millisecond),
+		dome.WithoutHeartbeat(),
+	)
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+	ctx := context.Background()
+	if _, err := client.Start(ctx, dome.StartOptions{Name: "parent", Capabilities: []string{"llm:chat", "mcp:call"}}); err != nil {
+		t.Fatalf("Start error: %v", err)
+	}
+	child, err := client.SpawnChild(ctx, dome.ChildOptions{Name: "researcher", Capabilities: []string{"mcp:call"}})
+	if err != nil {
+		t.Fatalf("SpawnChild error: %v", err)
+	}
+
+	call := dome.CheckRequest{Action: "mcp:call", Resource: "hr/get"}
+	if d, _ := child.Check(ctx, call); !d.Allowed {
+		t.Fatalf("child mcp:call denied: %s", d.Reason)
+	}
+
+	handler.setAgentStatus(child.ID(), apiv1.AgentStatus_AGENT_STATUS_SUSPENDED)
+	waitFor(t, func() bool {
+		d, _ := child.Check(ctx, call)
+		return !d.Allowed && d.Reason == "agent is suspended"
+	})
+	if d, _ := client.Check(ctx, call); !d.Allowed {
+		t.Errorf("parent denied while its child is suspended: %s", d.Reason)
+	}
+
+	handler.setAgentStatus(child.ID(), apiv1.AgentStatus_AGENT_STATUS_ACTIVE)
+	waitFor(t, func() bool {
+		d, _ := child.Check(ctx, call)
+		return d.Allowed
+	})
+
+	// A capability the parent gives up is no longer usable by the child.
+	if _, err := client.Update(ctx, dome.UpdateOptions{Capabilities: []string{"llm:chat"}}); err != nil {
+		t.Fatalf("Update error: %v", err)
+	}
+	if d, _ := child.Check(ctx, call); d.Allowed {
+		t.Error("child mcp:call allowed after the parent dropped mcp:call")
+	}
+}
@@ -10,7 +10,9 @@ import (
 	"sync"
 
 	"github.com/Dome-Systems/sdk-dome-go/internal/api/agentv1connect"
+	"github.com/Dome-Systems/sdk-dome-go/internal/identity"
 	"github.com/Dome-Systems/sdk-dome-go/internal/policy"
+	"github.com/Dome-Systems/sdk-dome-go/internal/secrets"
 	"github.com/Dome-Systems/sdk-dome-go/internal/tokenexchange"
 	"github.com/Dome-Systems/sdk-dome-go/internal/vault"
 )
@@ -30,10 +32,53 @@ type Client struct {
 	cancel   func()
 	stopped  chan struct{}
 
+	// Registration completion, for WaitRegistered.
+	info           *AgentInfo
+	registered     chan struct{}
+	registeredOnce sync.Once
+	closed         chan struct{}
+	closeOnce      sync.Once
+
 	// Policy evaluation.
-	policyEngine *policy.Engine
-	policySyncer *policy.Syncer
-	agentCtx     policy.AgentContext // cached agent context for Cedar evaluation
+	policyEngine  *policy.Engine
+	policySyncer  *policy.Syncer
+	agentCtx      policy.AgentContext // cached agent context for Cedar evaluation
+	quotaCounters quotaCounters
+
+	// Metric providers sampled on every heartbeat.
+	metrics metricSet
+
+	// identityToken returns an identity token scoped to audience for
+	// outbound calls to other agents. It is nil when authenticating with
+	// an API key.
+	identityToken func(audience string) (string, error)
+
+	// Inbound caller verification.
+	verifierOnce sync.Once
+	verifier     *identity.Verifier
+	callers      callerCache
+
+	// Credential access.
+	vaultAuth   *vault.Transport // set when authenticating via Vault
+	sourceOnce  sync.Once
+	source      secrets.Source
+	sourceErr   error
+	credentials credentialCache
+
+	// Lifecycle state. It has its own lock because the heartbeat goroutine
+	// changes it while Close holds mu.
+	stateMu          sync.Mutex
+	state            State
+	stateWatchers    map[int]func(StateChange)
+	nextStateWatcher int
+	stateChanges     []StateChange // not yet delivered to watchers
+	notifying        bool          // a goroutine is delivering stateChanges
+
+	// Child agents spawned by SpawnChild, revoked on Close.
+	children childSet
+
+	// Agents hosted through Client.Agent.
+	hosted hostedSet
 
 	// Auth events queued before Start() sets the agent ID.
 	pendingAuthEvents []string
@@ -58,6 +103,9 @@ func NewClient(opts ...Option) (*Client, error) {
 		config:       cfg,
 		logger:       cfg.logger,
 		policyEngine: policy.NewEngine(),
+		state:        StateInitialized,
+		registered:   make(chan struct{}),
+		closed:       make(chan struct{}),
 	}
 
 	// Auth event callback — queues events until Start() sets the agent ID.
@@ -92,11 +140,13 @@ func NewClient(opts ...Option) (*Client, error) {
 		}
 		if creds != nil && creds.APIURL != "" && creds.AuthMethod == "approle" {
 			// Token exchange via Dome API: agent never talks to Vault.
-			transport = tokenexchange.NewTransport(http.DefaultTransport, tokenexchange.Config{
+			exchange := tokenexchange.NewTransport(http.DefaultTransport, tokenexchange.Config{
 				APIURL:   creds.APIURL,
 				RoleID:   creds.RoleID,
 				SecretID: creds.SecretID,
 			}, authCallback)
+			c.identityToken = tokenexchange.NewAudienceTokens(http.DefaultTransport, creds.APIURL, exchange.Token).Token
+			transport = exchange
 		} else if creds != nil && creds.VaultAddr != "" && creds.OIDCRoleName != "" {
 			// Legacy Vault-based auth: direct Vault connectivity required.
 			vaultCfg := vault.AuthConfig{
@@ -116,7 +166,10 @@ func NewClient(opts ...Option) (*Client, error) {
 				vaultCfg.AppRoleID = creds.RoleID
 				vaultCfg.AppSecretID = creds.SecretID
 			}
-			transport = vault.NewTransport(http.DefaultTransport, vaultCfg, authCallback)
+			vaultAuth := vault.NewTransport(http.DefaultTransport, vaultCfg, authCallback)
+			c.vaultAuth = vaultAuth
+			c.identityToken = tokenexchange.NewAudienceTokens(http.DefaultTransport, cfg.apiURL, vaultAuth.IdentityToken).Token
+			transport = vaultAuth
 		} else if creds != nil {
 			// Credential blob present but no Vault OIDC — use raw token as bearer.
 			transport = &bearerTransport{base: http.DefaultTransport, token: credToken}
@@ -141,32 +194,51 @@ func NewClient(opts ...Option) (*Client, error) {
 	httpClient := &http.Client{Transport: transport}
 	c.rpc = agentv1connect.NewAgentRegistryClient(httpClient, cfg.apiURL)
 	c.httpClient = httpClient
+	if c.config.approver == nil {
+		c.config.approver = newControlPlaneApprover(httpClient, cfg.apiURL)
+	}
 
 	return c, nil
 }
 
-// Close stops the background heartbeat goroutine, policy syncer, and releases
-// resources. It is safe to call Close multiple times.
+// Close revokes child agents, closes hosted agents, revokes the leases of
+// cached credentials, stops the background heartbeat and status refresh,
+// policy syncer and credential lease renewal, and releases resources. It is safe
+// to call Close multiple times.
 func (c *Client) Close() error {
+	c.transition(StateStopping, "client closing", nil)
+	c.closeOnce.Do(func() { close(c.closed) })
+	for _, ch := range c.children.all() {
+		_ = ch.Close()
+	}
+	c.hosted.stop()
+	c.revokeLeases(context.Background(), c.credentials.stop()...)
+
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	cancel, stopped, agentID := c.cancel, c.stopped, c.agentID
+	c.cancel = nil
+	c.mu.Unlock()
+
+	// Stop the heartbeat or background registration goroutine before the
+	// policy syncer, which background registration may start. Do not hold
+	// mu while waiting: the goroutine takes it.
+	if cancel != nil {
+		// Emit agent.stopped before canceling the heartbeat context.
+		if agentID != "" {
+			c.reportEventForAgent(context.Background(), agentID, "agent.stopped")
+		}
+		cancel()
+		<-stopped
+	}
 
-	// Stop policy syncer.
+	c.mu.Lock()
 	if c.policySyncer != nil {
 		c.policySyncer.Stop()
 		c.policySyncer = nil
 	}
+	c.mu.Unlock()
 
-	if c.cancel != nil {
-		// Emit agent.stopped before canceling the heartbeat context.
-		// Use c.agentID directly since we already hold c.mu.
-		if c.agentID != "" {
-			c.reportEventForAgent(context.Background(), c.agentID, "agent.stopped")
-		}
-		c.cancel()
-		<-c.stopped
-		c.cancel = nil
-	}
+	c.transition(StateStopped, "client closed", nil)
 	return nil
 }
 
@@ -179,6 +251,9 @@ func (c *Client) startPolicySyncer() {
 	// The fetcher sends X-Tenant-ID header — the server extracts it from the
 	// auth token, so we can use a placeholder. The HTTP client already carries
 	// the auth transport.
+	if c.policySyncer != nil {
+		c.policySyncer.Stop()
+	}
 	fetcher := policy.NewFetcher(c.httpClient, c.config.apiURL, c.tenantID)
 	c.policySyncer = policy.NewSyncer(fetcher, c.policyEngine, c.config.policyRefresh, func(msg string, args ...any) {
 		c.logger.Debug(msg, args...)
@@ -186,6 +261,16 @@ func (c *Client) startPolicySyncer() {
 	c.policySyncer.Start()
 }
 
+// ensurePolicySyncer starts the policy syncer unless it is running.
+func (c *Client) ensurePolicySyncer() {
+	c.mu.Lock()
+	running := c.policySyncer != nil
+	c.mu.Unlock()
+	if !running {
+		c.startPolicySyncer()
+	}
+}
+
 // AgentID returns the registered agent's ID, or empty if not yet registered.
 func (c *Client) AgentID() string {
 	c.mu.Lock()
@@ -2,32 +2,56 @@ package dome_test
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"slices"
+	"sort"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
 
 	"connectrpc.com/connect"
+	"google.golang.org/protobuf/types/known/timestamppb"
 
 	dome "github.com/Dome-Systems/sdk-dome-go"
 	apiv1 "github.com/Dome-Systems/sdk-dome-go/internal/api"
 	"github.com/Dome-Systems/sdk-dome-go/internal/api/agentv1connect"
+	"github.com/Dome-Systems/sdk-dome-go/internal/policy"
 )
 
 // mockHandler implements the AgentRegistryHandler for testing.
 type mockHandler struct {
 	agentv1connect.UnimplementedAgentRegistryHandler
-	agents map[string]*apiv1.Agent
-	nextID int
+	mu         sync.Mutex
+	agents     map[string]*apiv1.Agent
+	events     []*apiv1.ReportEventRequest
+	heartbeats map[string]int
+	metrics    map[string][]map[string]float64 // heartbeat metrics by agent
+	// heartbeatWindow, if set, is the deadline sent with each heartbeat.
+	heartbeatWindow time.Duration
+	watch           chan *apiv1.AgentEvent // events for WatchAgents; nil ends the stream
+	updates         []*apiv1.UpdateAgentRequest
+	nextID          int
+	// descriptions holds agent descriptions, which Agent does not carry.
+	descriptions map[string]string
 }
 
 func newMockHandler() *mockHandler {
-	return &mockHandler{agents: make(map[string]*apiv1.Agent)}
+	return &mockHandler{
+		agents:       make(map[string]*apiv1.Agent),
+		heartbeats:   make(map[string]int),
+		metrics:      make(map[string][]map[string]float64),
+		watch:        make(chan *apiv1.AgentEvent, 16),
+		descriptions: make(map[string]string),
+	}
 }
 
 func (h *mockHandler) RegisterAgent(_ context.Context, req *connect.Request[apiv1.RegisterAgentRequest]) (*connect.Response[apiv1.RegisterAgentResponse], error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
 	msg := req.Msg
 
 	// Check for duplicate name.
@@ -44,8 +68,11 @@ func (h *mockHandler) RegisterAgent(_ context.Context, req *connect.Request[apiv
 		Status:       apiv1.AgentStatus_AGENT_STATUS_ACTIVE,
 		Capabilities: msg.GetCapabilities(),
 		Metadata:     msg.GetMetadata(),
+		ParentId:     msg.ParentId,
+		Runtime:      msg.GetRuntime(),
 	}
 	h.agents[agent.Id] = agent
+	h.descriptions[agent.Id] = msg.GetDescription()
 
 	return connect.NewResponse(&apiv1.RegisterAgentResponse{
 		Agent: agent,
@@ -53,25 +80,167 @@ func (h *mockHandler) RegisterAgent(_ context.Context, req *connect.Request[apiv
 	}), nil
 }
 
-func (h *mockHandler) ListAgents(_ context.Context, _ *connect.Request[apiv1.ListAgentsRequest]) (*connect.Response[apiv1.ListAgentsResponse], error) {
+func (h *mockHandler) GetAgent(_ context.Context, req *connect.Request[apiv1.GetAgentRequest]) (*connect.Response[apiv1.GetAgentResponse], error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	a, ok := h.agents[req.Msg.GetId()]
+	if !ok {
+		return nil, connect.NewError(connect.CodeNotFound, nil)
+	}
+	return connect.NewResponse(&apiv1.GetAgentResponse{Agent: a}), nil
+}
+
+func (h *mockHandler) ListAgents(_ context.Context, req *connect.Request[apiv1.ListAgentsRequest]) (*connect.Response[apiv1.ListAgentsResponse], error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
 	var agents []*apiv1.Agent
 	for _, a := range h.agents {
+		if req.Msg.Status != nil && a.GetStatus() != req.Msg.GetStatus() {
+			continue
+		}
+		if req.Msg.ParentId != nil && a.GetParentId() != req.Msg.GetParentId() {
+			continue
+		}
 		agents = append(agents, a)
 	}
+	sort.Slice(agents, func(i, j int) bool { return agents[i].GetId() < agents[j].GetId() })
+	total := len(agents)
+	agents = agents[min(int(req.Msg.GetOffset()), total):]
+	if limit := int(req.Msg.GetLimit()); limit > 0 && limit < len(agents) {
+		agents = agents[:limit]
+	}
 	return connect.NewResponse(&apiv1.ListAgentsResponse{
 		Agents: agents,
-		Total:  int32(len(agents)),
+		Total:  int32(total),
 	}), nil
 }
 
-func (h *mockHandler) Heartbeat(_ context.Context, _ *connect.Request[apiv1.HeartbeatRequest]) (*connect.Response[apiv1.HeartbeatResponse], error) {
-	return connect.NewResponse(&apiv1.HeartbeatResponse{}), nil
+func (h *mockHandler) UpdateAgent(_ context.Context, req *connect.Request[apiv1.UpdateAgentRequest]) (*connect.Response[apiv1.UpdateAgentResponse], error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	a, ok := h.agents[req.Msg.GetId()]
+	if !ok {
+		return nil, connect.NewError(connect.CodeNotFound, nil)
+	}
+	h.updates = append(h.updates, req.Msg)
+	if req.Msg.Name != nil {
+		a.Name = req.Msg.GetName()
+	}
+	if req.Msg.Capabilities != nil {
+		a.Capabilities = req.Msg.GetCapabilities()
+	}
+	if req.Msg.Metadata != nil {
+		a.Metadata = req.Msg.GetMetadata()
+	}
+	if req.Msg.Description != nil {
+		h.descriptions[a.Id] = req.Msg.GetDescription()
+	}
+	return connect.NewResponse(&apiv1.UpdateAgentResponse{Agent: a}), nil
+}
+
+// WatchAgents streams the events sent on h.watch until the client goes away.
+func (h *mockHandler) WatchAgents(ctx context.Context, _ *connect.Request[apiv1.WatchAgentsRequest], stream *connect.ServerStream[apiv1.AgentEvent]) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case e := <-h.watch:
+			if e == nil {
+				return connect.NewError(connect.CodeUnavailable, nil)
+			}
+			if err := stream.Send(e); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (h *mockHandler) RevokeAgent(_ context.Context, req *connect.Request[apiv1.RevokeAgentRequest]) (*connect.Response[apiv1.RevokeAgentResponse], error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	a, ok := h.agents[req.Msg.GetId()]
+	if !ok {
+		return nil, connect.NewError(connect.CodeNotFound, nil)
+	}
+	revoked := []*apiv1.Agent{a}
+	if req.Msg.GetCascadeToDescendants() {
+		for i := 0; i < len(revoked); i++ {
+			for _, child := range h.agents {
+				if child.GetParentId() == revoked[i].GetId() {
+					revoked = append(revoked, child)
+				}
+			}
+		}
+	}
+	for _, r := range revoked {
+		r.Status = apiv1.AgentStatus_AGENT_STATUS_REVOKED
+	}
+	return connect.NewResponse(&apiv1.RevokeAgentResponse{RevokedCount: int32(len(revoked))}), nil
+}
+
+func (h *mockHandler) Heartbeat(_ context.Context, req *connect.Request[apiv1.HeartbeatRequest]) (*connect.Response[apiv1.HeartbeatResponse], error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.heartbeats[req.Msg.GetAgentId()]++
+	h.metrics[req.Msg.GetAgentId()] = append(h.metrics[req.Msg.GetAgentId()], req.Msg.GetMetrics())
+	switch h.agents[req.Msg.GetAgentId()].GetStatus() {
+	case apiv1.AgentStatus_AGENT_STATUS_SUSPENDED, apiv1.AgentStatus_AGENT_STATUS_REVOKED:
+		return nil, connect.NewError(connect.CodeFailedPrecondition, nil)
+	}
+	resp := &apiv1.HeartbeatResponse{}
+	if h.heartbeatWindow > 0 {
+		resp.NextHeartbeatDeadline = timestamppb.New(time.Now().Add(h.heartbeatWindow))
+	}
+	return connect.NewResponse(resp), nil
+}
+
+// heartbeatMetrics returns the metrics sent with each heartbeat of the
+// agent with the given ID.
+func (h *mockHandler) heartbeatMetrics(id string) []map[string]float64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return slices.Clone(h.metrics[id])
+}
+
+// setAgentStatus changes the status of the agent with the given ID.
+func (h *mockHandler) setAgentStatus(id string, status apiv1.AgentStatus) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.agents[id].Status = status
+}
+
+// agentStatus returns the status of the agent with the given ID.
+func (h *mockHandler) agentStatus(id string) apiv1.AgentStatus {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.agents[id].GetStatus()
+}
+
+// heartbeatCount returns the number of heartbeats received for an agent.
+func (h *mockHandler) heartbeatCount(id string) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.heartbeats[id]
 }
 
-func (h *mockHandler) ReportEvent(_ context.Context, _ *connect.Request[apiv1.ReportEventRequest]) (*connect.Response[apiv1.ReportEventResponse], error) {
+func (h *mockHandler) ReportEvent(_ context.Context, req *connect.Request[apiv1.ReportEventRequest]) (*connect.Response[apiv1.ReportEventResponse], error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.events = append(h.events, req.Msg)
 	return connect.NewResponse(&apiv1.ReportEventResponse{}), nil
 }
 
+// eventTypes returns the types of the events reported so far.
+func (h *mockHandler) eventTypes() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	types := make([]string, len(h.events))
+	for i, e := range h.events {
+		types[i] = e.GetEventType()
+	}
+	return types
+}
+
 // testServer creates a test HTTP server backed by a mock handler.
 func testServer(t *testing.T) string {
 	t.Helper()
@@ -357,6 +526,105 @@ func TestRegister_GracefulDegradation_EventualSuccess(t *testing.T) {
 	t.Fatal("background registration did not succeed within timeout")
 }
 
+func TestRegister_GracefulDegradation_BackgroundParity(t *testing.T) {
+	handler := newMockHandler()
+	mux := http.NewServeMux()
+	mux.Handle(agentv1connect.NewAgentRegistryHandler(handler))
+	mux.HandleFunc("/api/v1/policies/bundle", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(policy.BundleResponse{
+			Version:  "v1",
+			Policies: []policy.PolicyFile{{Filename: "llm.cedar", Content: permitLLMCedar}},
+		})
+	})
+	// Fail only the registration attempted by Start.
+	var failed atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/dome.agent.v1.AgentRegistry/RegisterAgent" && failed.CompareAndSwap(false, true) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	notified := make(chan *dome.AgentInfo, 1)
+	client, err := dome.NewClient(
+		dome.WithAPIKey("test-key"),
+		dome.WithAPIURL(server.URL),
+		dome.WithGracefulDegradation(),
+		dome.WithoutHeartbeat(),
+		dome.WithOnRegistered(func(info *dome.AgentInfo) { notified <- info }),
+	)
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	if _, err := client.Start(context.Background(), dome.StartOptions{
+		Name:         "background-agent",
+		Capabilities: []string{"llm:chat"},
+	}); err != nil {
+		t.Fatalf("Start error: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	info, err := client.WaitRegistered(ctx)
+	if err != nil {
+		t.Fatalf("WaitRegistered error: %v", err)
+	}
+	if info.ID == "" || info.ID != client.AgentID() {
+		t.Errorf("WaitRegistered ID = %q, AgentID = %q", info.ID, client.AgentID())
+	}
+	select {
+	case got := <-notified:
+		if got.ID != info.ID {
+			t.Errorf("OnRegistered ID = %q, want %q", got.ID, info.ID)
+		}
+	case <-ctx.Done():
+		t.Fatal("OnRegistered not called")
+	}
+	if got := client.State(); got != dome.StateActive {
+		t.Errorf("State = %s, want active", got)
+	}
+	if !slices.Contains(handler.eventTypes(), "agent.started") {
+		t.Errorf("events = %v, want agent.started", handler.eventTypes())
+	}
+
+	// The policy syncer runs: once the bundle loads, unlisted actions are
+	// denied while the agent's capability is permitted.
+	for {
+		d, err := client.Check(ctx, dome.CheckRequest{Action: "tool:call", Resource: "shell"})
+		if err != nil {
+			t.Fatalf("Check error: %v", err)
+		}
+		if !d.Allowed {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			t.Fatal("policy bundle never loaded after background registration")
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+	d, err := client.Check(ctx, dome.CheckRequest{Action: "llm:chat", Resource: "openai/gpt-4"})
+	if err != nil || !d.Allowed {
+		t.Errorf("Check llm:chat = %+v, %v; want allowed", d, err)
+	}
+}
+
+func TestWaitRegistered_ClientClosed(t *testing.T) {
+	client, err := dome.NewClient(dome.WithAPIKey("test-key"), dome.WithAPIURL("http://127.0.0.1:1"))
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	_ = client.Close()
+	if _, err := client.WaitRegistered(context.Background()); err == nil {
+		t.Error("WaitRegistered after Close returned nil error")
+	}
+}
+
 func TestRegister_WithoutGracefulDegradation_ReturnsError(t *testing.T) {
 	// Without graceful degradation, unreachable API should return error.
 	client, err := dome.NewClient(
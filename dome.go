@@ -101,6 +101,21 @@ func Middleware(next http.Handler) http.Handler {
 	})
 }
 
+// MiddlewareWithOptions is like Middleware but allows customizing how
+// requests map to policy checks and how denied requests are answered. See
+// Client.MiddlewareWithOptions.
+func MiddlewareWithOptions(next http.Handler, opts MiddlewareOptions) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := getGlobalClient()
+		if err != nil {
+			// If no client is initialized, pass through.
+			next.ServeHTTP(w, r)
+			return
+		}
+		c.MiddlewareWithOptions(next, opts).ServeHTTP(w, r)
+	})
+}
+
 // Shutdown gracefully stops the global client, stopping the heartbeat goroutine
 // and releasing resources. It is safe to call Shutdown multiple times.
 func Shutdown(_ context.Context) error {
@@ -123,3 +138,23 @@ func getGlobalClient() (*Client, error) {
 	}
 	return globalClient, nil
 }
+
+// Transport wraps base with egress policy enforcement using the global
+// client. See Client.Transport. Requests pass through unchecked until
+// dome.Init has been called.
+func Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		c, err := getGlobalClient()
+		if err != nil {
+			return base.RoundTrip(r)
+		}
+		return c.Transport(base).RoundTrip(r)
+	})
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
@@ -0,0 +1,89 @@
+// Package domegrpc provides gRPC server interceptors that enforce Dome
+// policy on every RPC. It is a separate package so that programs which do
+// not use grpc-go do not link it.
+//
+//	server := grpc.NewServer(
+//	    grpc.UnaryInterceptor(domegrpc.UnaryServerInterceptor(client, dome.RPCOptions{
+//	        ResourceField: "document_id",
+//	    })),
+//	    grpc.StreamInterceptor(domegrpc.StreamServerInterceptor(client, dome.RPCOptions{})),
+//	)
+package domegrpc
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	dome "github.com/Dome-Systems/sdk-dome-go"
+)
+
+// UnaryServerInterceptor returns a grpc.UnaryServerInterceptor that checks
+// each call with client.EnforceRPC. Denied calls fail with
+// codes.PermissionDenied (codes.ResourceExhausted when a quota is
+// exhausted) and a google.rpc.ErrorInfo detail.
+func UnaryServerInterceptor(client *dome.Client, opts dome.RPCOptions) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if err := check(ctx, client, info.FullMethod, req, opts); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor returns a grpc.StreamServerInterceptor. When
+// opts.ResourceField is empty the stream open is checked; otherwise each
+// received message is checked against its own resource.
+func StreamServerInterceptor(client *dome.Client, opts dome.RPCOptions) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if opts.ResourceField == "" {
+			if err := check(ss.Context(), client, info.FullMethod, nil, opts); err != nil {
+				return err
+			}
+			return handler(srv, ss)
+		}
+		return handler(srv, &checkedServerStream{
+			ServerStream: ss,
+			client:       client,
+			procedure:    info.FullMethod,
+			opts:         opts,
+		})
+	}
+}
+
+// check evaluates policy for one RPC and converts a denial to a gRPC status
+// error. Check errors are logged with the client's logger and fail open,
+// matching dome.Client.Middleware.
+func check(ctx context.Context, client *dome.Client, procedure string, msg any, opts dome.RPCOptions) error {
+	denial := client.EnforceRPC(ctx, procedure, msg, opts)
+	if denial == nil {
+		return nil
+	}
+
+	code := codes.PermissionDenied
+	if denial.QuotaExceeded {
+		code = codes.ResourceExhausted
+	}
+	st := status.New(code, denial.Message)
+	if withDetails, detailErr := st.WithDetails(denial.Info); detailErr == nil {
+		st = withDetails
+	}
+	return st.Err()
+}
+
+// checkedServerStream checks each message received on a stream.
+type checkedServerStream struct {
+	grpc.ServerStream
+	client    *dome.Client
+	procedure string
+	opts      dome.RPCOptions
+}
+
+func (s *checkedServerStream) RecvMsg(m any) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	return check(s.Context(), s.client, s.procedure, m, s.opts)
+}
@@ -0,0 +1,165 @@
+package domegrpc_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"connectrpc.com/connect"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	dome "github.com/Dome-Systems/sdk-dome-go"
+	"github.com/Dome-Systems/sdk-dome-go/domegrpc"
+	apiv1 "github.com/Dome-Systems/sdk-dome-go/internal/api"
+	"github.com/Dome-Systems/sdk-dome-go/internal/api/agentv1connect"
+	"github.com/Dome-Systems/sdk-dome-go/internal/policy"
+)
+
+const getAgentProcedure = "/dome.agent.v1.AgentRegistry/GetAgent"
+
+const testCedar = `
+@id("get-public-agent")
+permit(
+    principal is Dome::Agent,
+    action == Dome::Action::"dome.agent.v1.AgentRegistry/GetAgent",
+    resource == Dome::Resource::"agent-public"
+);
+`
+
+// registry accepts agent registrations so a client can start.
+type registry struct {
+	agentv1connect.UnimplementedAgentRegistryHandler
+}
+
+func (registry) RegisterAgent(_ context.Context, req *connect.Request[apiv1.RegisterAgentRequest]) (*connect.Response[apiv1.RegisterAgentResponse], error) {
+	return connect.NewResponse(&apiv1.RegisterAgentResponse{
+		Agent: &apiv1.Agent{
+			Id:     "agent-1",
+			Name:   req.Msg.GetName(),
+			Status: apiv1.AgentStatus_AGENT_STATUS_ACTIVE,
+		},
+	}), nil
+}
+
+func startedClient(t *testing.T) *dome.Client {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	path, h := agentv1connect.NewAgentRegistryHandler(registry{})
+	mux.Handle(path, h)
+	mux.HandleFunc("/api/v1/policies/bundle", func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(policy.BundleResponse{
+			Version:  "v1",
+			Policies: []policy.PolicyFile{{Filename: "rpc.cedar", Content: testCedar}},
+		})
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	client, err := dome.NewClient(
+		dome.WithAPIKey("test-key"),
+		dome.WithAPIURL(server.URL),
+		dome.WithoutHeartbeat(),
+	)
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+
+	if _, err := client.Start(context.Background(), dome.StartOptions{Name: "grpc-agent"}); err != nil {
+		t.Fatalf("Start error: %v", err)
+	}
+	return client
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	client := startedClient(t)
+	intercept := domegrpc.UnaryServerInterceptor(client, dome.RPCOptions{ResourceField: "id"})
+	info := &grpc.UnaryServerInfo{FullMethod: getAgentProcedure}
+
+	called := 0
+	handler := func(context.Context, any) (any, error) {
+		called++
+		return "ok", nil
+	}
+
+	resp, err := intercept(context.Background(), &apiv1.GetAgentRequest{Id: "agent-public"}, info, handler)
+	if err != nil || resp != "ok" {
+		t.Fatalf("permitted call = %v, %v", resp, err)
+	}
+
+	_, err = intercept(context.Background(), &apiv1.GetAgentRequest{Id: "agent-secret"}, info, handler)
+	st := status.Convert(err)
+	if st.Code() != codes.PermissionDenied {
+		t.Fatalf("code = %v, want PermissionDenied", st.Code())
+	}
+	if called != 1 {
+		t.Errorf("handler called %d times, want 1", called)
+	}
+
+	details := st.Details()
+	if len(details) != 1 {
+		t.Fatalf("details = %v, want one ErrorInfo", details)
+	}
+	if ei, ok := details[0].(*errdetails.ErrorInfo); !ok || ei.GetReason() != "POLICY_DENIED" {
+		t.Errorf("detail = %v", details[0])
+	}
+}
+
+// fakeStream delivers queued messages to RecvMsg.
+type fakeStream struct {
+	grpc.ServerStream
+	msgs []*apiv1.GetAgentRequest
+}
+
+func (s *fakeStream) Context() context.Context { return context.Background() }
+
+func (s *fakeStream) RecvMsg(m any) error {
+	next := s.msgs[0]
+	s.msgs = s.msgs[1:]
+	m.(*apiv1.GetAgentRequest).Id = next.GetId()
+	return nil
+}
+
+func TestStreamServerInterceptor_PerMessage(t *testing.T) {
+	client := startedClient(t)
+	intercept := domegrpc.StreamServerInterceptor(client, dome.RPCOptions{ResourceField: "id"})
+	info := &grpc.StreamServerInfo{FullMethod: getAgentProcedure}
+
+	stream := &fakeStream{msgs: []*apiv1.GetAgentRequest{{Id: "agent-public"}, {Id: "agent-secret"}}}
+	err := intercept(nil, stream, info, func(_ any, ss grpc.ServerStream) error {
+		for {
+			var req apiv1.GetAgentRequest
+			if err := ss.RecvMsg(&req); err != nil {
+				return err
+			}
+		}
+	})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Errorf("code = %v, want PermissionDenied on second message", status.Code(err))
+	}
+}
+
+func TestStreamServerInterceptor_Open(t *testing.T) {
+	client := startedClient(t)
+	intercept := domegrpc.StreamServerInterceptor(client, dome.RPCOptions{})
+	info := &grpc.StreamServerInfo{FullMethod: "/dome.agent.v1.AgentRegistry/WatchAgents"}
+
+	err := intercept(nil, &fakeStream{}, info, func(any, grpc.ServerStream) error {
+		t.Error("handler should not run for a denied stream")
+		return nil
+	})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Errorf("code = %v, want PermissionDenied", status.Code(err))
+	}
+
+	skip := domegrpc.StreamServerInterceptor(client, dome.RPCOptions{SkipProcedures: []string{info.FullMethod}})
+	if err := skip(nil, &fakeStream{}, info, func(any, grpc.ServerStream) error { return nil }); err != nil {
+		t.Errorf("skipped stream error: %v", err)
+	}
+}
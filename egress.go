@@ -0,0 +1,195 @@
+package dome
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ErrEgressDenied is matched by errors.Is for every outbound request blocked
+// by policy. Use errors.As with *EgressDeniedError for the decision.
+var ErrEgressDenied = errors.New("dome: egress denied by policy")
+
+// EgressDeniedError is returned by the Transport round tripper when policy
+// denies an outbound request. http.Client wraps it in a *url.Error, which
+// errors.As unwraps.
+type EgressDeniedError struct {
+	Method   string
+	URL      string
+	Decision *Decision
+}
+
+func (e *EgressDeniedError) Error() string {
+	return fmt.Sprintf("dome: egress denied: %s %s: %s", e.Method, e.URL, e.Decision.Reason)
+}
+
+// Unwrap returns ErrEgressDenied.
+func (e *EgressDeniedError) Unwrap() error { return ErrEgressDenied }
+
+// EgressDecision records the policy outcome for one outbound request.
+type EgressDecision struct {
+	Time     time.Time
+	Method   string
+	URL      string
+	Action   string
+	Resource string
+	// Decision is nil when the check failed and the request was allowed
+	// through (fail-open).
+	Decision *Decision
+	// Err is the check error, if any.
+	Err error
+}
+
+// Allowed reports whether the request was sent.
+func (d EgressDecision) Allowed() bool {
+	return d.Decision == nil || d.Decision.Allowed
+}
+
+// TransportOptions configures Client.TransportWithOptions.
+type TransportOptions struct {
+	// Mapper derives the CheckRequest for each outbound request. Defaults
+	// to EgressMapper.
+	Mapper RequestMapper
+
+	// SkipHosts lists hosts (as in URL.Host, including any port) that are
+	// reached without a policy check. A leading "*." matches any subdomain.
+	SkipHosts []string
+
+	// OnDecision, if set, is called synchronously with every egress
+	// decision, including those for allowed requests.
+	OnDecision func(EgressDecision)
+}
+
+// EgressMapper is the default outbound RequestMapper. The action is
+// "http:" followed by the lower-cased method (e.g. "http:get") and the
+// resource is the host and path, e.g. "api.github.com/repos/acme/app". The
+// resource type is "http"; the method, scheme and host are added to the
+// context as "http.method", "http.scheme" and "http.host".
+func EgressMapper(r *http.Request) CheckRequest {
+	return CheckRequest{
+		Action:       "http:" + strings.ToLower(r.Method),
+		Resource:     r.URL.Host + r.URL.EscapedPath(),
+		ResourceType: "http",
+		Context: map[string]string{
+			"http.method": r.Method,
+			"http.scheme": r.URL.Scheme,
+			"http.host":   r.URL.Host,
+		},
+	}
+}
+
+// Transport returns an http.RoundTripper that checks every outbound request
+// against policy before passing it to base (http.DefaultTransport if nil).
+// Denied requests are not sent; RoundTrip returns an *EgressDeniedError.
+// Denials are logged and reported to the control plane as "egress.denied"
+// events.
+//
+//	httpClient := &http.Client{Transport: client.Transport(nil)}
+//
+// If the check itself fails, the request is sent (fail-open for v0.4.0).
+func (c *Client) Transport(base http.RoundTripper) http.RoundTripper {
+	return c.TransportWithOptions(base, TransportOptions{})
+}
+
+// TransportWithOptions is like Transport but allows customizing how
+// requests map to policy checks and observing every decision.
+func (c *Client) TransportWithOptions(base http.RoundTripper, opts TransportOptions) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if opts.Mapper == nil {
+		opts.Mapper = EgressMapper
+	}
+	return &egressTransport{client: c, base: base, opts: opts}
+}
+
+type egressTransport struct {
+	client *Client
+	base   http.RoundTripper
+	opts   TransportOptions
+}
+
+func (t *egressTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if skipHost(t.opts.SkipHosts, r.URL.Host) {
+		return t.base.RoundTrip(r)
+	}
+
+	req := t.opts.Mapper(r)
+	url := redactURL(r)
+	decision, err := t.client.Check(r.Context(), req)
+	t.record(EgressDecision{
+		Time:     time.Now(),
+		Method:   r.Method,
+		URL:      url,
+		Action:   req.Action,
+		Resource: req.Resource,
+		Decision: decision,
+		Err:      err,
+	})
+	if err != nil {
+		t.client.logger.Error("dome: egress policy check error", "url", url, "error", err)
+		// Fail-open on error.
+		return t.base.RoundTrip(r)
+	}
+
+	if !decision.Allowed {
+		// A RoundTripper must close the request body, even on error.
+		if r.Body != nil {
+			_ = r.Body.Close()
+		}
+		return nil, &EgressDeniedError{Method: r.Method, URL: url, Decision: decision}
+	}
+	return t.base.RoundTrip(r)
+}
+
+// record logs an egress decision, reports denials to the control plane and
+// invokes the OnDecision hook.
+func (t *egressTransport) record(d EgressDecision) {
+	if d.Decision != nil && !d.Decision.Allowed {
+		t.client.logger.Warn("dome: egress denied",
+			"method", d.Method,
+			"url", d.URL,
+			"reason", d.Decision.Reason,
+		)
+		go t.client.reportEventData(context.Background(), t.client.AgentID(), "egress.denied", map[string]any{
+			"method":         d.Method,
+			"url":            d.URL,
+			"action":         d.Action,
+			"resource":       d.Resource,
+			"reason":         d.Decision.Reason,
+			"policy_version": d.Decision.PolicyVersion,
+		})
+	} else {
+		t.client.logger.Debug("dome: egress allowed", "method", d.Method, "url", d.URL)
+	}
+
+	if t.opts.OnDecision != nil {
+		t.opts.OnDecision(d)
+	}
+}
+
+// redactURL returns the request URL without user info or query string,
+// which commonly carry credentials.
+func redactURL(r *http.Request) string {
+	u := *r.URL
+	u.User = nil
+	u.RawQuery = ""
+	u.Fragment = ""
+	return u.String()
+}
+
+// skipHost reports whether host matches an entry in skip.
+func skipHost(skip []string, host string) bool {
+	for _, s := range skip {
+		if s == host {
+			return true
+		}
+		if suffix, ok := strings.CutPrefix(s, "*"); ok && strings.HasPrefix(suffix, ".") && strings.HasSuffix(host, suffix) {
+			return true
+		}
+	}
+	return false
+}
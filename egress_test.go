@@ -0,0 +1,96 @@
+package dome_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	dome "github.com/Dome-Systems/sdk-dome-go"
+	"github.com/Dome-Systems/sdk-dome-go/internal/policy"
+)
+
+const egressCedar = `
+@id("egress-read-only")
+permit(
+    principal is Dome::Agent,
+    action == Dome::Action::"http:get",
+    resource
+);
+`
+
+func TestTransport(t *testing.T) {
+	serverURL := testServerWithBundle(t, policy.BundleResponse{
+		Version:  "v1",
+		Policies: []policy.PolicyFile{{Filename: "egress.cedar", Content: egressCedar}},
+	})
+	client := startedClient(t, serverURL)
+
+	var received atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		received.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(upstream.Close)
+
+	var decisions []dome.EgressDecision
+	httpClient := &http.Client{Transport: client.TransportWithOptions(nil, dome.TransportOptions{
+		OnDecision: func(d dome.EgressDecision) { decisions = append(decisions, d) },
+	})}
+
+	resp, err := httpClient.Get(upstream.URL + "/repos?token=secret")
+	if err != nil {
+		t.Fatalf("GET error: %v", err)
+	}
+	_ = resp.Body.Close()
+
+	_, err = httpClient.Post(upstream.URL+"/repos", "application/json", strings.NewReader("{}"))
+	var denied *dome.EgressDeniedError
+	if !errors.As(err, &denied) || !errors.Is(err, dome.ErrEgressDenied) {
+		t.Fatalf("POST error = %v, want *EgressDeniedError", err)
+	}
+	if denied.Method != http.MethodPost || denied.Decision.Allowed {
+		t.Errorf("denied = %+v", denied)
+	}
+	if n := received.Load(); n != 1 {
+		t.Errorf("upstream received %d requests, want 1", n)
+	}
+
+	if len(decisions) != 2 {
+		t.Fatalf("recorded %d decisions, want 2", len(decisions))
+	}
+	host := strings.TrimPrefix(upstream.URL, "http://")
+	if d := decisions[0]; !d.Allowed() || d.Action != "http:get" || d.Resource != host+"/repos" {
+		t.Errorf("GET decision = %+v", d)
+	}
+	if strings.Contains(decisions[0].URL, "secret") {
+		t.Errorf("recorded URL %q leaks the query string", decisions[0].URL)
+	}
+	if d := decisions[1]; d.Allowed() || d.Action != "http:post" {
+		t.Errorf("POST decision = %+v", d)
+	}
+}
+
+func TestTransport_SkipHosts(t *testing.T) {
+	serverURL := testServerWithBundle(t, policy.BundleResponse{
+		Version:  "v1",
+		Policies: []policy.PolicyFile{{Filename: "egress.cedar", Content: egressCedar}},
+	})
+	client := startedClient(t, serverURL)
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(upstream.Close)
+
+	httpClient := &http.Client{Transport: client.TransportWithOptions(nil, dome.TransportOptions{
+		SkipHosts: []string{strings.TrimPrefix(upstream.URL, "http://")},
+	})}
+	resp, err := httpClient.Post(upstream.URL, "text/plain", strings.NewReader("x"))
+	if err != nil {
+		t.Fatalf("POST to skipped host error: %v", err)
+	}
+	_ = resp.Body.Close()
+}
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"connectrpc.com/connect"
+	"google.golang.org/protobuf/types/known/structpb"
 	"google.golang.org/protobuf/types/known/timestamppb"
 
 	apiv1 "github.com/Dome-Systems/sdk-dome-go/internal/api"
@@ -20,6 +21,13 @@ func (c *Client) reportEvent(ctx context.Context, eventType string) {
 // reportEventForAgent sends an event for a specific agent ID.
 // Use this when the caller already holds c.mu (e.g., from Close).
 func (c *Client) reportEventForAgent(ctx context.Context, agentID, eventType string) {
+	c.reportEventData(ctx, agentID, eventType, nil)
+}
+
+// reportEventData sends an event with a structured payload. Values in data
+// must be representable as google.protobuf.Value (strings, numbers, bools,
+// nil, []any and map[string]any).
+func (c *Client) reportEventData(ctx context.Context, agentID, eventType string, data map[string]any) {
 	if agentID == "" {
 		return
 	}
@@ -29,6 +37,14 @@ func (c *Client) reportEventForAgent(ctx context.Context, agentID, eventType str
 		EventType: eventType,
 		Timestamp: timestamppb.New(time.Now()),
 	}
+	if len(data) > 0 {
+		s, err := structpb.NewStruct(data)
+		if err != nil {
+			c.logger.Debug("failed to encode event data", "event_type", eventType, "error", err)
+		} else {
+			req.Data = s
+		}
+	}
 
 	_, err := c.rpc.ReportEvent(ctx, connect.NewRequest(req))
 	if err != nil {
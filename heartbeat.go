@@ -2,6 +2,8 @@ package dome
 
 import (
 	"context"
+	"math/rand"
+	"sync"
 	"time"
 
 	"connectrpc.com/connect"
@@ -9,10 +11,20 @@ import (
 	apiv1 "github.com/Dome-Systems/sdk-dome-go/internal/api"
 )
 
-const maxHeartbeatInterval = 5 * time.Minute
+const (
+	maxHeartbeatInterval = 5 * time.Minute
 
-// startHeartbeat launches a background goroutine that sends periodic heartbeats.
-func (c *Client) startHeartbeat(agentID string) {
+	// heartbeatDeadlineFraction is the share of the server's heartbeat
+	// window after which the next heartbeat is sent.
+	heartbeatDeadlineFraction = 2.0 / 3
+
+	// minHeartbeatRetry is the shortest wait before retrying a failed
+	// heartbeat, unless the configured interval is shorter.
+	minHeartbeatRetry = time.Second
+)
+
+// startMonitor launches the background goroutine that runs monitorAgent.
+func (c *Client) startMonitor(agentID string) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
@@ -28,25 +40,44 @@ func (c *Client) startHeartbeat(agentID string) {
 
 	go func() {
 		defer close(c.stopped)
-		c.runHeartbeat(ctx, agentID)
+		c.monitorAgent(ctx, agentID)
+	}()
+}
+
+// monitorAgent keeps the client's own agent in touch with the control plane
+// until ctx is canceled: it sends heartbeats, unless WithoutHeartbeat was
+// used, and refreshes the agent's status. This is the pure logic, like
+// runHeartbeat.
+func (c *Client) monitorAgent(ctx context.Context, agentID string) {
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		c.runStatusRefresh(ctx, agentID)
 	}()
+	if !c.config.disableHeartbeat {
+		c.runHeartbeat(ctx, agentID, true)
+	}
+	wg.Wait()
 }
 
-// runHeartbeat sends heartbeats at the configured interval until the context is
-// canceled. On consecutive failures, the interval backs off exponentially up to
-// maxHeartbeatInterval. On success, the interval resets to the configured value.
+// runHeartbeat sends heartbeats until the context is canceled, scheduled by
+// a heartbeatSchedule: at the configured interval or sooner if the server's
+// deadline demands it, backing off exponentially on consecutive failures
+// without letting the deadline pass unretried. For the client's own agent
+// (self), a failure after the deadline moves the client to StateStale.
 //
 // This is the pure logic — it does not manage c.cancel/c.stopped. Callers are
-// responsible for goroutine lifecycle.
-func (c *Client) runHeartbeat(ctx context.Context, agentID string) {
-	// Send initial heartbeat immediately.
-	c.sendHeartbeat(ctx, agentID)
+// responsible for goroutine lifecycle. self is set for the client's own
+// agent, whose control plane status the heartbeat tracks.
+func (c *Client) runHeartbeat(ctx context.Context, agentID string, self bool) {
+	schedule := heartbeatSchedule{base: c.config.heartbeatInterval}
 
-	baseInterval := c.config.heartbeatInterval
-	currentInterval := baseInterval
-	consecutiveFailures := 0
+	// Send initial heartbeat immediately.
+	deadline, ok := c.sendHeartbeat(ctx, agentID, self)
+	schedule.record(ok, deadline, time.Now())
 
-	timer := time.NewTimer(currentInterval)
+	timer := time.NewTimer(schedule.next(time.Now()))
 	defer timer.Stop()
 
 	for {
@@ -54,26 +85,105 @@ func (c *Client) runHeartbeat(ctx context.Context, agentID string) {
 		case <-ctx.Done():
 			return
 		case <-timer.C:
-			if c.sendHeartbeat(ctx, agentID) {
-				consecutiveFailures = 0
-				currentInterval = baseInterval
-			} else {
-				consecutiveFailures++
-				currentInterval = backoff(baseInterval, maxHeartbeatInterval, consecutiveFailures)
+			deadline, ok := c.sendHeartbeat(ctx, agentID, self)
+			now := time.Now()
+			schedule.record(ok, deadline, now)
+			if self && !ok && schedule.missed(now) {
+				c.markStale(schedule.deadline)
 			}
-			timer.Reset(currentInterval)
+			timer.Reset(schedule.next(now))
+		}
+	}
+}
+
+// heartbeatSchedule decides when the next heartbeat is due.
+type heartbeatSchedule struct {
+	base     time.Duration
+	failures int
+	// deadline is the server's deadline from the last successful
+	// heartbeat, and window how long it was from then; zero if the server
+	// sent none.
+	deadline time.Time
+	window   time.Duration
+}
+
+// record notes the outcome of a heartbeat sent at now.
+func (s *heartbeatSchedule) record(ok bool, deadline, now time.Time) {
+	if !ok {
+		s.failures++
+		return
+	}
+	s.failures = 0
+	// A success without a deadline ends the previous one: the server no
+	// longer expects heartbeats by then.
+	s.deadline = deadline
+	s.window = 0
+	if !deadline.IsZero() {
+		s.window = deadline.Sub(now)
+	}
+}
+
+// missed reports whether the server's deadline has passed.
+func (s *heartbeatSchedule) missed(now time.Time) bool {
+	return !s.deadline.IsZero() && now.After(s.deadline)
+}
+
+// next returns the wait before the next heartbeat. After a success it is
+// the configured interval, shortened to heartbeatDeadlineFraction of the
+// server's window, less up to 10% jitter. After failures it is the
+// exponential backoff, capped at half the time left before the deadline or,
+// once the deadline has passed, at the server's window.
+func (s *heartbeatSchedule) next(now time.Time) time.Duration {
+	if s.failures == 0 {
+		interval := s.base
+		if s.window > 0 {
+			interval = min(interval, time.Duration(float64(s.window)*heartbeatDeadlineFraction))
 		}
+		return interval - time.Duration(float64(interval)*0.1*rand.Float64())
 	}
+
+	wait := backoff(s.base, maxHeartbeatInterval, s.failures)
+	if remaining := s.deadline.Sub(now); !s.deadline.IsZero() && remaining > 0 {
+		wait = min(wait, remaining/2)
+	} else if s.window > 0 {
+		wait = min(wait, s.window)
+	}
+	return max(wait, min(s.base, minHeartbeatRetry))
 }
 
-// sendHeartbeat sends a single heartbeat RPC. Returns true on success.
-func (c *Client) sendHeartbeat(ctx context.Context, agentID string) bool {
-	_, err := c.rpc.Heartbeat(ctx, connect.NewRequest(&apiv1.HeartbeatRequest{
+// sendHeartbeat sends a single heartbeat RPC. It returns the server's next
+// heartbeat deadline, if any, and whether the heartbeat succeeded.
+//
+// For the client's own agent (self), the heartbeat carries the registered
+// metric providers' samples, and failures move the client to
+// StateDegraded and the next success back to StateActive. A heartbeat
+// rejected as if the agent were revoked or suspended triggers a status
+// refresh at once rather than at the next runStatusRefresh tick.
+func (c *Client) sendHeartbeat(ctx context.Context, agentID string, self bool) (time.Time, bool) {
+	metrics, commit := c.heartbeatMetrics(self)
+	resp, err := c.rpc.Heartbeat(ctx, connect.NewRequest(&apiv1.HeartbeatRequest{
 		AgentId: agentID,
+		Metrics: metrics,
 	}))
 	if err != nil {
 		c.logger.Warn("heartbeat failed", "agent_id", agentID, "error", err)
-		return false
+	} else {
+		commit()
+	}
+	var deadline time.Time
+	if err == nil {
+		deadline = timeFromProto(resp.Msg.GetNextHeartbeatDeadline())
+	}
+	if !self {
+		return deadline, err == nil
+	}
+	if agentInactive(err) {
+		c.refreshAgentStatus(ctx, agentID)
+	}
+	if err != nil {
+		c.degrade("heartbeat failed", err)
+		return deadline, false
 	}
-	return true
+	c.restore("heartbeat succeeded")
+	return deadline, true
 }
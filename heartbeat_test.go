@@ -180,3 +180,67 @@ func TestHeartbeat_DisabledWithOption(t *testing.T) {
 		t.Fatalf("Close error: %v", err)
 	}
 }
+
+func TestHeartbeat_FollowsServerDeadline(t *testing.T) {
+	handler, url := registryServer(t)
+	handler.heartbeatWindow = 150 * time.Millisecond
+
+	client, err := dome.NewClient(
+		dome.WithAPIKey("test-key"),
+		dome.WithAPIURL(url),
+		dome.WithHeartbeatInterval(time.Hour),
+	)
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	info, err := client.Start(context.Background(), dome.StartOptions{Name: "deadline-agent"})
+	if err != nil {
+		t.Fatalf("Start error: %v", err)
+	}
+	// The hour-long interval is shortened to fit the server's window.
+	waitFor(t, func() bool { return handler.heartbeatCount(info.ID) >= 4 })
+}
+
+func TestHeartbeat_StaleAfterMissedDeadline(t *testing.T) {
+	handler := newMockHandler()
+	handler.heartbeatWindow = 200 * time.Millisecond
+	var failing atomic.Bool
+	mux := http.NewServeMux()
+	mux.Handle(agentv1connect.NewAgentRegistryHandler(handler))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/dome.agent.v1.AgentRegistry/Heartbeat" && failing.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := dome.NewClient(
+		dome.WithAPIKey("test-key"),
+		dome.WithAPIURL(server.URL),
+		dome.WithHeartbeatInterval(50*time.Millisecond),
+	)
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+	states := recordStates(client)
+
+	info, err := client.Start(context.Background(), dome.StartOptions{Name: "stale-agent"})
+	if err != nil {
+		t.Fatalf("Start error: %v", err)
+	}
+	waitFor(t, func() bool { return handler.heartbeatCount(info.ID) > 0 })
+	failing.Store(true)
+	states.waitFor(t, dome.StateDegraded)
+	sc := states.waitFor(t, dome.StateStale)
+	if sc.From != dome.StateDegraded {
+		t.Errorf("stale transition from %s, want degraded", sc.From)
+	}
+
+	failing.Store(false)
+	states.waitFor(t, dome.StateActive)
+}
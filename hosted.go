@@ -0,0 +1,344 @@
+package dome
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Dome-Systems/sdk-dome-go/internal/policy"
+)
+
+// hostedConcurrency bounds the heartbeat and status RPCs in flight for
+// hosted agents.
+const hostedConcurrency = 8
+
+// HostedAgent is one of many logical agents hosted by a single Client. Each
+// has its own registration, agent context and Check, while sharing the
+// client's authenticated transport and policy bundle. One loop per client,
+// rather than one goroutine per agent, heartbeats the hosted agents and
+// refreshes their status. The RPC count is not reduced: each started hosted
+// agent still gets its own Heartbeat RPC per interval, and its own status
+// refresh.
+type HostedAgent struct {
+	client *Client
+	name   string
+
+	mu       sync.Mutex
+	info     *AgentInfo
+	agentCtx policy.AgentContext
+	status   AgentStatus // control plane status, as last observed
+	closed   bool
+}
+
+// hostedSet tracks the hosted agents of a client and their shared loop.
+type hostedSet struct {
+	mu      sync.Mutex
+	agents  map[string]*HostedAgent
+	cancel  func()
+	stopped chan struct{}
+	closed  bool // set by stop; the loop is not restarted
+}
+
+// Agent returns the handle of the hosted agent with the given name,
+// creating it if needed. The agent is not registered until its Start is
+// called.
+//
+//	for _, workflow := range workflows {
+//		agent := client.Agent("workflow-" + workflow.ID)
+//		if _, err := agent.Start(ctx, dome.StartOptions{Capabilities: workflow.Capabilities}); err != nil {
+//			return err
+//		}
+//	}
+func (c *Client) Agent(name string) *HostedAgent {
+	c.hosted.mu.Lock()
+	defer c.hosted.mu.Unlock()
+	if h, ok := c.hosted.agents[name]; ok {
+		return h
+	}
+	if c.hosted.agents == nil {
+		c.hosted.agents = make(map[string]*HostedAgent)
+	}
+	h := &HostedAgent{client: c, name: name}
+	c.hosted.agents[name] = h
+	return h
+}
+
+// Start registers the hosted agent like Client.Start and adds it to the
+// client's loop, which heartbeats it (unless WithoutHeartbeat was used) and
+// refreshes its status. opts.Name defaults to the handle's name and must
+// match it if set. Start fails once the hosted agent or the client is
+// closed.
+func (h *HostedAgent) Start(ctx context.Context, opts StartOptions) (*AgentInfo, error) {
+	if opts.Name == "" {
+		opts.Name = h.name
+	}
+	if opts.Name != h.name {
+		return nil, errorf("start hosted agent %s: name %q does not match", h.name, opts.Name)
+	}
+	if h.isClosed() {
+		return nil, errorf("start hosted agent %s: closed", h.name)
+	}
+	select {
+	case <-h.client.closed:
+		return nil, errorf("start hosted agent %s: client closed", h.name)
+	default:
+	}
+
+	c := h.client
+	info, err := c.doRegister(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	h.mu.Lock()
+	h.info = info
+	h.agentCtx = policy.AgentContext{
+		ID:           info.ID,
+		Capabilities: info.Capabilities,
+	}
+	h.status = agentFromInfo(info).Status
+	h.mu.Unlock()
+
+	if !c.config.disablePolicy {
+		c.ensurePolicySyncer()
+	}
+	if !c.hosted.startLoop(c) {
+		return nil, errorf("start hosted agent %s: client closed", h.name)
+	}
+	c.logger.Info("dome: hosted agent started", "agent_id", info.ID, "agent_name", h.name)
+	c.reportEventForAgent(ctx, info.ID, "agent.started")
+	if !c.config.disableHeartbeat {
+		if _, ok := c.sendHeartbeat(ctx, info.ID, false); !ok {
+			h.refreshStatus(ctx)
+		}
+	}
+	return info, nil
+}
+
+// Name returns the hosted agent's name.
+func (h *HostedAgent) Name() string { return h.name }
+
+// ID returns the hosted agent's ID, or empty if it has not been started.
+func (h *HostedAgent) ID() string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.agentCtx.ID
+}
+
+// Info returns the hosted agent's registration, or nil if it has not been
+// started.
+func (h *HostedAgent) Info() *AgentInfo {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.info
+}
+
+// Check evaluates a policy decision with the hosted agent as the Cedar
+// principal. It behaves like Client.Check, but denies everything while the
+// hosted agent, rather than the client's own agent, is revoked or
+// suspended. The hosted agent's status is refreshed at the heartbeat
+// interval, whenever its heartbeat fails, and on every heartbeat while it
+// is blocked.
+func (h *HostedAgent) Check(ctx context.Context, req CheckRequest) (*Decision, error) {
+	h.mu.Lock()
+	agentCtx := h.agentCtx
+	h.mu.Unlock()
+	return h.client.checkAs(ctx, agentCtx, h.blockedStatus(), req)
+}
+
+// blockedStatus returns the hosted agent's status if it is revoked or
+// suspended, or AgentStatusUnknown otherwise.
+func (h *HostedAgent) blockedStatus() AgentStatus {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.status == AgentStatusRevoked || h.status == AgentStatusSuspended {
+		return h.status
+	}
+	return AgentStatusUnknown
+}
+
+// refreshStatus fetches the hosted agent's status from the control plane.
+func (h *HostedAgent) refreshStatus(ctx context.Context) {
+	agentID := h.ID()
+	agent, ok := h.client.fetchAgentStatus(ctx, agentID)
+	if !ok {
+		return
+	}
+	h.mu.Lock()
+	from := h.status
+	h.status = agent.Status
+	h.mu.Unlock()
+	if from != agent.Status {
+		h.client.logger.Info("dome: hosted agent status changed", "agent_id", agentID, "agent_name", h.name, "from", from, "to", agent.Status)
+	}
+}
+
+// Close stops heartbeating for the hosted agent, reports it stopped and
+// removes it from the client; a later Client.Agent call with the same name
+// returns a new handle. The agent stays registered. It is safe to call
+// Close multiple times.
+func (h *HostedAgent) Close() error {
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		return nil
+	}
+	h.closed = true
+	agentID := h.agentCtx.ID
+	h.mu.Unlock()
+
+	h.client.hosted.remove(h)
+	if agentID != "" {
+		h.client.reportEventForAgent(context.Background(), agentID, "agent.stopped")
+	}
+	return nil
+}
+
+func (h *HostedAgent) isClosed() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.closed
+}
+
+func (s *hostedSet) remove(h *HostedAgent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.agents[h.name] == h {
+		delete(s.agents, h.name)
+	}
+}
+
+// all returns the hosted agents.
+func (s *hostedSet) all() []*HostedAgent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]*HostedAgent, 0, len(s.agents))
+	for _, h := range s.agents {
+		out = append(out, h)
+	}
+	return out
+}
+
+// startLoop starts the shared loop if it is not running. It reports false
+// once the set is stopped.
+func (s *hostedSet) startLoop(c *Client) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false
+	}
+	if s.cancel != nil {
+		return true
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.stopped = make(chan struct{})
+	go func() {
+		defer close(s.stopped)
+		c.monitorHosted(ctx)
+	}()
+	return true
+}
+
+// stop closes every hosted agent and stops the loop for good.
+func (s *hostedSet) stop() {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+	for _, h := range s.all() {
+		_ = h.Close()
+	}
+	s.mu.Lock()
+	cancel, stopped := s.cancel, s.stopped
+	s.cancel = nil
+	s.mu.Unlock()
+	if cancel != nil {
+		cancel()
+		<-stopped
+	}
+}
+
+// monitorHosted keeps every started hosted agent in touch with the control
+// plane until ctx is canceled, like monitorAgent: it refreshes their status
+// at the heartbeat interval and, unless WithoutHeartbeat was used, sends
+// their heartbeats, scheduled like runHeartbeat by the earliest server
+// deadline of each round. A round counts as failed when every heartbeat in
+// it fails.
+func (c *Client) monitorHosted(ctx context.Context) {
+	refresh := time.NewTicker(c.config.heartbeatInterval)
+	defer refresh.Stop()
+
+	schedule := heartbeatSchedule{base: c.config.heartbeatInterval}
+	timer := time.NewTimer(schedule.next(time.Now()))
+	defer timer.Stop()
+	heartbeat := timer.C
+	if c.config.disableHeartbeat {
+		timer.Stop()
+		heartbeat = nil
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-refresh.C:
+			c.forEachHosted(func(h *HostedAgent, _ string) { h.refreshStatus(ctx) })
+		case <-heartbeat:
+			deadline, ok := c.heartbeatHosted(ctx)
+			now := time.Now()
+			schedule.record(ok, deadline, now)
+			timer.Reset(schedule.next(now))
+		}
+	}
+}
+
+// forEachHosted calls fn for each started hosted agent, with at most
+// hostedConcurrency calls running at once, and waits for them.
+func (c *Client) forEachHosted(fn func(h *HostedAgent, agentID string)) {
+	var (
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, hostedConcurrency)
+	)
+	for _, h := range c.hosted.all() {
+		agentID := h.ID()
+		if agentID == "" {
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			fn(h, agentID)
+		}()
+	}
+	wg.Wait()
+}
+
+// heartbeatHosted sends one heartbeat for each started hosted agent. It
+// returns the earliest server deadline and whether any heartbeat (or, with
+// no agents started, the round) succeeded.
+func (c *Client) heartbeatHosted(ctx context.Context) (deadline time.Time, ok bool) {
+	var (
+		mu      sync.Mutex
+		sent    int
+		succeed int
+	)
+	c.forEachHosted(func(h *HostedAgent, agentID string) {
+		d, ok := c.sendHeartbeat(ctx, agentID, false)
+		if !ok || h.blockedStatus() != AgentStatusUnknown {
+			// A revoked or suspended agent's heartbeats are rejected; a
+			// refresh tells which, or that it is back.
+			h.refreshStatus(ctx)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		sent++
+		if ok {
+			succeed++
+		}
+		if !d.IsZero() && (deadline.IsZero() || d.Before(deadline)) {
+			deadline = d
+		}
+	})
+	return deadline, sent == 0 || succeed > 0
+}
@@ -0,0 +1,189 @@
+package dome_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	dome "github.com/Dome-Systems/sdk-dome-go"
+	apiv1 "github.com/Dome-Systems/sdk-dome-go/internal/api"
+	"github.com/Dome-Systems/sdk-dome-go/internal/api/agentv1connect"
+	"github.com/Dome-Systems/sdk-dome-go/internal/policy"
+)
+
+func TestHostedAgents(t *testing.T) {
+	handler := newMockHandler()
+	mux := http.NewServeMux()
+	mux.Handle(agentv1connect.NewAgentRegistryHandler(handler))
+	mux.HandleFunc("/api/v1/policies/bundle", func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(policy.BundleResponse{
+			Version:  "v1",
+			Policies: []policy.PolicyFile{{Filename: "caps.cedar", Content: capabilityCedar}},
+		})
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	client, err := dome.NewClient(
+		dome.WithAPIKey("test-key"),
+		dome.WithAPIURL(server.URL),
+		dome.WithHeartbeatInterval(20*time.Millisecond),
+	)
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+	ctx := context.Background()
+
+	billing := client.Agent("billing")
+	if client.Agent("billing") != billing {
+		t.Error("Agent returned a new handle for an existing name")
+	}
+	if _, err := billing.Start(ctx, dome.StartOptions{Capabilities: []string{"invoice:read"}}); err != nil {
+		t.Fatalf("Start billing error: %v", err)
+	}
+	support := client.Agent("support")
+	if _, err := support.Start(ctx, dome.StartOptions{Capabilities: []string{"ticket:read"}}); err != nil {
+		t.Fatalf("Start support error: %v", err)
+	}
+	if billing.ID() == "" || billing.ID() == support.ID() {
+		t.Fatalf("IDs = %q, %q, want distinct registrations", billing.ID(), support.ID())
+	}
+	if _, err := client.Agent("other").Start(ctx, dome.StartOptions{Name: "mismatch"}); err == nil {
+		t.Error("Start with a mismatched name returned nil error")
+	}
+
+	invoice := dome.CheckRequest{Action: "invoice:read", Resource: "inv-1"}
+	if d, _ := billing.Check(ctx, invoice); !d.Allowed {
+		t.Errorf("billing invoice:read denied: %s", d.Reason)
+	}
+	if d, _ := support.Check(ctx, invoice); d.Allowed {
+		t.Error("support invoice:read allowed, want denied")
+	}
+
+	// One shared loop heartbeats every hosted agent.
+	waitFor(t, func() bool {
+		return handler.heartbeatCount(billing.ID()) > 2 && handler.heartbeatCount(support.ID()) > 2
+	})
+
+	if err := support.Close(); err != nil {
+		t.Fatalf("Close error: %v", err)
+	}
+	if client.Agent("support") == support {
+		t.Error("Agent returned a closed handle")
+	}
+	// Let a round already in flight finish.
+	time.Sleep(50 * time.Millisecond)
+	count := handler.heartbeatCount(support.ID())
+	time.Sleep(100 * time.Millisecond)
+	if got := handler.heartbeatCount(support.ID()); got != count {
+		t.Errorf("closed agent heartbeats went from %d to %d", count, got)
+	}
+}
+
+func TestHostedAgents_OwnStatus(t *testing.T) {
+	handler := newMockHandler()
+	mux := http.NewServeMux()
+	mux.Handle(agentv1connect.NewAgentRegistryHandler(handler))
+	mux.HandleFunc("/api/v1/policies/bundle", func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(policy.BundleResponse{
+			Version:  "v1",
+			Policies: []policy.PolicyFile{{Filename: "caps.cedar", Content: capabilityCedar}},
+		})
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	client, err := dome.NewClient(
+		dome.WithAPIKey("test-key"),
+		dome.WithAPIURL(server.URL),
+		dome.WithHeartbeatInterval(20*time.Millisecond),
+	)
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+	ctx := context.Background()
+
+	billing := client.Agent("billing")
+	if _, err := billing.Start(ctx, dome.StartOptions{Capabilities: []string{"invoice:read"}}); err != nil {
+		t.Fatalf("Start billing error: %v", err)
+	}
+	support := client.Agent("support")
+	if _, err := support.Start(ctx, dome.StartOptions{Capabilities: []string{"invoice:read"}}); err != nil {
+		t.Fatalf("Start support error: %v", err)
+	}
+	invoice := dome.CheckRequest{Action: "invoice:read", Resource: "inv-1"}
+	waitFor(t, func() bool {
+		d, _ := billing.Check(ctx, invoice)
+		return d.Allowed
+	})
+
+	handler.setAgentStatus(billing.ID(), apiv1.AgentStatus_AGENT_STATUS_SUSPENDED)
+	waitFor(t, func() bool {
+		d, _ := billing.Check(ctx, invoice)
+		return !d.Allowed && d.Reason == "agent is suspended"
+	})
+	if d, _ := support.Check(ctx, invoice); !d.Allowed {
+		t.Errorf("support denied while billing is suspended: %s", d.Reason)
+	}
+
+	handler.setAgentStatus(billing.ID(), apiv1.AgentStatus_AGENT_STATUS_ACTIVE)
+	waitFor(t, func() bool {
+		d, _ := billing.Check(ctx, invoice)
+		return d.Allowed
+	})
+}
+
+func TestHostedAgents_StatusWithoutHeartbeat(t *testing.T) {
+	handler := newMockHandler()
+	mux := http.NewServeMux()
+	mux.Handle(agentv1connect.NewAgentRegistryHandler(handler))
+	mux.HandleFunc("/api/v1/policies/bundle", func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(policy.BundleResponse{
+			Version:  "v1",
+			Policies: []policy.PolicyFile{{Filename: "caps.cedar", Content: capabilityCedar}},
+		})
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	client, err := dome.NewClient(
+		dome.WithAPIKey("test-key"),
+		dome.WithAPIURL(server.URL),
+		dome.WithHeartbeatInterval(20*time.Millisecond),
+		dome.WithoutHeartbeat(),
+	)
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	ctx := context.Background()
+
+	billing := client.Agent("billing")
+	if _, err := billing.Start(ctx, dome.StartOptions{Capabilities: []string{"invoice:read"}}); err != nil {
+		t.Fatalf("Start error: %v", err)
+	}
+	invoice := dome.CheckRequest{Action: "invoice:read", Resource: "inv-1"}
+	waitFor(t, func() bool {
+		d, _ := billing.Check(ctx, invoice)
+		return d.Allowed
+	})
+
+	handler.setAgentStatus(billing.ID(), apiv1.AgentStatus_AGENT_STATUS_REVOKED)
+	waitFor(t, func() bool {
+		d, _ := billing.Check(ctx, invoice)
+		return !d.Allowed && d.Reason == "agent is revoked"
+	})
+	if n := handler.heartbeatCount(billing.ID()); n != 0 {
+		t.Errorf("heartbeats = %d, want none", n)
+	}
+
+	// Once the client is closed, hosted agents cannot be started again.
+	_ = client.Close()
+	if _, err := client.Agent("billing").Start(ctx, dome.StartOptions{}); err == nil {
+		t.Error("Start after Close returned nil error")
+	}
+}
@@ -0,0 +1,186 @@
+// Package approval submits human-in-the-loop approval requests to the Dome
+// control plane and waits for their outcome. This is an internal package —
+// SDK consumers use Client.RequestApproval.
+package approval
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Approval states reported by the control plane.
+const (
+	StatusPending  = "pending"
+	StatusApproved = "approved"
+	StatusRejected = "rejected"
+	StatusExpired  = "expired"
+)
+
+// DefaultPollInterval is the pause between status polls when the server
+// answers a long poll immediately.
+const DefaultPollInterval = 2 * time.Second
+
+// longPollWait is how long the server may hold a status request open
+// waiting for a decision.
+const longPollWait = 30 * time.Second
+
+// Request matches the body of POST /api/v1/approvals.
+type Request struct {
+	AgentID       string            `json:"agent_id"`
+	Action        string            `json:"action"`
+	Resource      string            `json:"resource"`
+	ResourceType  string            `json:"resource_type,omitempty"`
+	Context       map[string]string `json:"context,omitempty"`
+	Reason        string            `json:"reason,omitempty"`
+	Approvers     []string          `json:"approvers,omitempty"`
+	PolicyVersion string            `json:"policy_version,omitempty"`
+}
+
+// Status matches the response of POST /api/v1/approvals and
+// GET /api/v1/approvals/{id}.
+type Status struct {
+	ID        string `json:"id"`
+	Status    string `json:"status"`
+	DecidedBy string `json:"decided_by,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// Final reports whether the approval has been decided.
+func (s *Status) Final() bool {
+	return s.Status != "" && s.Status != StatusPending
+}
+
+// Client talks to the control plane approval API.
+type Client struct {
+	httpClient   *http.Client
+	baseURL      string
+	pollInterval time.Duration
+}
+
+// NewClient creates an approval client for the control plane at baseURL.
+// The HTTP client must carry the agent's authentication.
+func NewClient(httpClient *http.Client, baseURL string, pollInterval time.Duration) *Client {
+	if pollInterval <= 0 {
+		pollInterval = DefaultPollInterval
+	}
+	return &Client{
+		httpClient:   httpClient,
+		baseURL:      strings.TrimRight(baseURL, "/"),
+		pollInterval: pollInterval,
+	}
+}
+
+// Submit creates an approval request.
+func (c *Client) Submit(ctx context.Context, req Request) (*Status, error) {
+	var st Status
+	if err := c.do(ctx, http.MethodPost, c.baseURL+"/api/v1/approvals", req, &st); err != nil {
+		return nil, fmt.Errorf("submit approval: %w", err)
+	}
+	if st.ID == "" {
+		return nil, fmt.Errorf("submit approval: response has no id")
+	}
+	return &st, nil
+}
+
+// Wait polls the approval until it is decided or ctx is done. Each poll
+// asks the server to hold the request open until a decision is made, so a
+// decision is usually observed as soon as it happens. Client errors such
+// as an unknown approval ID or a rejected credential end the wait; other
+// errors are retried.
+func (c *Client) Wait(ctx context.Context, id string) (*Status, error) {
+	u := c.baseURL + "/api/v1/approvals/" + url.PathEscape(id) + "?wait=" + longPollWait.String()
+	for {
+		var st Status
+		err := c.do(ctx, http.MethodGet, u, nil, &st)
+		if err == nil && st.Final() {
+			return &st, nil
+		}
+		if permanent(err) {
+			return nil, fmt.Errorf("wait for approval: %w", err)
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		// Transient errors are retried at the poll interval.
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(c.pollInterval):
+		}
+	}
+}
+
+// Cancel withdraws a pending approval so approvers are no longer asked to
+// decide it.
+func (c *Client) Cancel(ctx context.Context, id string) error {
+	if err := c.do(ctx, http.MethodPost, c.baseURL+"/api/v1/approvals/"+url.PathEscape(id)+"/cancel", nil, nil); err != nil {
+		return fmt.Errorf("cancel approval: %w", err)
+	}
+	return nil
+}
+
+// do sends a JSON request and decodes the JSON response into out, if set.
+func (c *Client) do(ctx context.Context, method, u string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, reader)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return &StatusError{Code: resp.StatusCode, Message: strings.TrimSpace(string(msg))}
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
+
+// StatusError reports a non-2xx response from the approval API.
+type StatusError struct {
+	Code    int
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status %d: %s", e.Code, e.Message)
+}
+
+// permanent reports whether err is a client error that retrying will not
+// fix. Request timeouts and rate limiting are retried.
+func permanent(err error) bool {
+	var se *StatusError
+	if !errors.As(err, &se) {
+		return false
+	}
+	return se.Code >= 400 && se.Code < 500 &&
+		se.Code != http.StatusRequestTimeout && se.Code != http.StatusTooManyRequests
+}
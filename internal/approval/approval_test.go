@@ -0,0 +1,104 @@
+package approval
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClient_SubmitAndWait(t *testing.T) {
+	var polls atomic.Int32
+	var submitted Request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/api/v1/approvals":
+			_ = json.NewDecoder(r.Body).Decode(&submitted)
+			w.WriteHeader(http.StatusCreated)
+			_ = json.NewEncoder(w).Encode(Status{ID: "apr-1", Status: StatusPending})
+		case r.Method == http.MethodGet && r.URL.Path == "/api/v1/approvals/apr-1":
+			if r.URL.Query().Get("wait") == "" {
+				t.Error("status poll without wait parameter")
+			}
+			st := Status{ID: "apr-1", Status: StatusPending}
+			if polls.Add(1) >= 3 {
+				st = Status{ID: "apr-1", Status: StatusApproved, DecidedBy: "alice", Reason: "looks fine"}
+			}
+			_ = json.NewEncoder(w).Encode(st)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.Client(), srv.URL, time.Millisecond)
+	st, err := c.Submit(context.Background(), Request{AgentID: "a1", Action: "payments:transfer", Resource: "acct-1", Approvers: []string{"finance"}})
+	if err != nil {
+		t.Fatalf("Submit error: %v", err)
+	}
+	if submitted.Action != "payments:transfer" || len(submitted.Approvers) != 1 {
+		t.Errorf("submitted = %+v", submitted)
+	}
+
+	final, err := c.Wait(context.Background(), st.ID)
+	if err != nil {
+		t.Fatalf("Wait error: %v", err)
+	}
+	if final.Status != StatusApproved || final.DecidedBy != "alice" || polls.Load() != 3 {
+		t.Errorf("final = %+v after %d polls", final, polls.Load())
+	}
+}
+
+func TestClient_WaitDeadline(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(Status{ID: "apr-1", Status: StatusPending})
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := NewClient(srv.Client(), srv.URL, 10*time.Millisecond).Wait(ctx, "apr-1")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error = %v, want DeadlineExceeded", err)
+	}
+}
+
+func TestClient_WaitStopsOnClientError(t *testing.T) {
+	for _, tc := range []struct {
+		code  int
+		retry bool
+	}{
+		{http.StatusNotFound, false},
+		{http.StatusUnauthorized, false},
+		{http.StatusForbidden, false},
+		{http.StatusTooManyRequests, true},
+		{http.StatusRequestTimeout, true},
+		{http.StatusBadGateway, true},
+	} {
+		var polls atomic.Int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			if polls.Add(1) == 1 {
+				w.WriteHeader(tc.code)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(Status{ID: "apr-1", Status: StatusRejected})
+		}))
+
+		st, err := NewClient(srv.Client(), srv.URL, time.Millisecond).Wait(context.Background(), "apr-1")
+		srv.Close()
+		if tc.retry {
+			if err != nil || st.Status != StatusRejected || polls.Load() != 2 {
+				t.Errorf("status %d: got %+v, %v after %d polls, want retried", tc.code, st, err, polls.Load())
+			}
+			continue
+		}
+		var se *StatusError
+		if !errors.As(err, &se) || se.Code != tc.code || polls.Load() != 1 {
+			t.Errorf("status %d: error = %v after %d polls, want StatusError without retry", tc.code, err, polls.Load())
+		}
+	}
+}
@@ -0,0 +1,256 @@
+// Package identity verifies Dome identity tokens (JWTs signed by the control
+// plane) for inbound requests. This is an internal package — SDK consumers
+// use the middleware and interceptor options instead.
+package identity
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultJWKSRefresh is how long a fetched key set is trusted before it
+	// is refetched.
+	DefaultJWKSRefresh = time.Hour
+
+	// minRefetchInterval limits refetches triggered by unknown key IDs, so a
+	// stream of forged tokens cannot hammer the JWKS endpoint.
+	minRefetchInterval = 30 * time.Second
+
+	// fetchTimeout bounds a single JWKS fetch. The fetch is shared by every
+	// caller waiting on it, so it does not inherit any one caller's
+	// cancellation.
+	fetchTimeout = 10 * time.Second
+)
+
+// ErrUnknownKey is returned when a token's key ID is not in the key set,
+// even after a refetch.
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// KeySet fetches and caches a JSON Web Key Set. Keys are refetched after the
+// refresh interval, or early when a token references an unknown key ID
+// (which is how signing key rotation shows up).
+type KeySet struct {
+	httpClient *http.Client
+	url        string
+	refresh    time.Duration
+
+	mu          sync.Mutex
+	keys        map[string]crypto.PublicKey
+	fetchedAt   time.Time
+	attemptedAt time.Time        // start of the last fetch, successful or not
+	fetchErr    error            // result of the last fetch
+	inflight    chan struct{}    // closed when the running fetch finishes
+	nowFunc     func() time.Time // for testing
+}
+
+// NewKeySet creates a key set backed by the JWKS document at url.
+func NewKeySet(httpClient *http.Client, url string, refresh time.Duration) *KeySet {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	if refresh <= 0 {
+		refresh = DefaultJWKSRefresh
+	}
+	return &KeySet{
+		httpClient: httpClient,
+		url:        url,
+		refresh:    refresh,
+		nowFunc:    time.Now,
+	}
+}
+
+// Key returns the public key with the given key ID. Concurrent callers that
+// need a refetch share a single request, made without holding the lock.
+func (s *KeySet) Key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	s.mu.Lock()
+	now := s.nowFunc()
+	key, ok := s.keys[kid]
+	stale := s.keys == nil || now.Sub(s.fetchedAt) >= s.refresh
+	if ok && !stale {
+		s.mu.Unlock()
+		return key, nil
+	}
+	if s.inflight == nil {
+		// Unknown kid (the signing key may have rotated) or a stale set.
+		// Failed fetches count too, so an unreachable endpoint is not
+		// retried on every request.
+		if !s.attemptedAt.IsZero() && now.Sub(s.attemptedAt) < minRefetchInterval {
+			defer s.mu.Unlock()
+			return s.lookup(kid)
+		}
+		s.inflight = make(chan struct{})
+		s.attemptedAt = now
+		go s.refetch(ctx, s.inflight)
+	}
+	done := s.inflight
+	s.mu.Unlock()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lookup(kid)
+}
+
+// lookup returns the cached key, falling back to the last fetch error.
+// Cached keys keep being served while the endpoint is temporarily down.
+// The caller must hold s.mu.
+func (s *KeySet) lookup(kid string) (crypto.PublicKey, error) {
+	if key, ok := s.keys[kid]; ok {
+		return key, nil
+	}
+	if s.fetchErr != nil {
+		return nil, s.fetchErr
+	}
+	return nil, ErrUnknownKey
+}
+
+// refetch runs a fetch on behalf of every caller waiting on done.
+func (s *KeySet) refetch(ctx context.Context, done chan struct{}) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), fetchTimeout)
+	defer cancel()
+	keys, err := s.fetch(ctx)
+
+	s.mu.Lock()
+	if err == nil {
+		s.keys = keys
+		s.fetchedAt = s.attemptedAt
+	}
+	s.fetchErr = err
+	s.inflight = nil
+	s.mu.Unlock()
+	close(done)
+}
+
+// fetch downloads and parses the key set.
+func (s *KeySet) fetch(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create JWKS request: %w", err)
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch JWKS: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d from JWKS endpoint", resp.StatusCode)
+	}
+
+	var doc struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("decode JWKS: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(doc.Keys))
+	for _, k := range doc.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		pub, err := k.publicKey()
+		if err != nil {
+			// Skip keys we cannot use rather than failing the whole set.
+			continue
+		}
+		keys[k.Kid] = pub
+	}
+	return keys, nil
+}
+
+// jwk is a single JSON Web Key (RFC 7517).
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("RSA exponent too large")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("EC point not on curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return nil, fmt.Errorf("invalid base64url integer")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
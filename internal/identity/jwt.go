@@ -0,0 +1,227 @@
+package identity
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// clockSkew is the leeway applied to exp and nbf checks.
+const clockSkew = 30 * time.Second
+
+// Claims holds the verified claims of a Dome identity token.
+type Claims struct {
+	Subject   string
+	Issuer    string
+	Audience  []string
+	ExpiresAt time.Time
+	IssuedAt  time.Time
+	// AgentID is the "agent_id" claim, falling back to "sub".
+	AgentID string
+	// TenantID is the "tenant_id" claim.
+	TenantID string
+	// Raw holds every claim in the token payload.
+	Raw map[string]any
+}
+
+// Verifier checks JWT signatures against a KeySet and validates the
+// standard time, issuer and audience claims.
+type Verifier struct {
+	keys    *KeySet
+	issuer  string
+	nowFunc func() time.Time // for testing
+}
+
+// NewVerifier creates a verifier. The issuer is checked only when
+// non-empty.
+func NewVerifier(keys *KeySet, issuer string) *Verifier {
+	return &Verifier{
+		keys:    keys,
+		issuer:  issuer,
+		nowFunc: time.Now,
+	}
+}
+
+// Verify parses and verifies a compact JWS token issued for audience. The
+// audience is required: a token accepted for any audience could be replayed
+// by whoever it was sent to.
+func (v *Verifier) Verify(ctx context.Context, token, audience string) (*Claims, error) {
+	if audience == "" {
+		return nil, errors.New("no audience to verify against")
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("decode header: %w", err)
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("decode signature: %w", err)
+	}
+
+	key, err := v.keys.Key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifySignature(header.Alg, key, parts[0]+"."+parts[1], sig); err != nil {
+		return nil, err
+	}
+
+	var raw map[string]any
+	if err := decodeSegment(parts[1], &raw); err != nil {
+		return nil, fmt.Errorf("decode claims: %w", err)
+	}
+	claims := claimsFromRaw(raw)
+
+	now := v.nowFunc()
+	if claims.ExpiresAt.IsZero() {
+		return nil, errors.New("token has no expiry")
+	}
+	if now.After(claims.ExpiresAt.Add(clockSkew)) {
+		return nil, errors.New("token expired")
+	}
+	if nbf, ok := numericDate(raw["nbf"]); ok && now.Add(clockSkew).Before(nbf) {
+		return nil, errors.New("token not yet valid")
+	}
+	if v.issuer != "" && claims.Issuer != v.issuer {
+		return nil, fmt.Errorf("unexpected issuer %q", claims.Issuer)
+	}
+	if !contains(claims.Audience, audience) {
+		return nil, fmt.Errorf("token not issued for audience %q", audience)
+	}
+	if claims.AgentID == "" {
+		return nil, errors.New("token has no agent identity")
+	}
+
+	return claims, nil
+}
+
+// verifySignature checks sig over signingInput with key using alg.
+func verifySignature(alg string, key crypto.PublicKey, signingInput string, sig []byte) error {
+	var h hash.Hash
+	var hashID crypto.Hash
+	switch alg {
+	case "RS256", "PS256", "ES256":
+		h, hashID = sha256.New(), crypto.SHA256
+	case "RS384", "PS384", "ES384":
+		h, hashID = sha512.New384(), crypto.SHA384
+	case "RS512", "PS512", "ES512":
+		h, hashID = sha512.New(), crypto.SHA512
+	case "EdDSA":
+		pub, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return fmt.Errorf("key type does not match alg %s", alg)
+		}
+		if !ed25519.Verify(pub, []byte(signingInput), sig) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	default:
+		// Notably rejects "none" and HMAC algorithms.
+		return fmt.Errorf("unsupported alg %q", alg)
+	}
+	h.Write([]byte(signingInput))
+	digest := h.Sum(nil)
+
+	switch alg[:2] {
+	case "RS", "PS":
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("key type does not match alg %s", alg)
+		}
+		var err error
+		if alg[0] == 'R' {
+			err = rsa.VerifyPKCS1v15(pub, hashID, digest, sig)
+		} else {
+			err = rsa.VerifyPSS(pub, hashID, digest, sig, nil)
+		}
+		if err != nil {
+			return errors.New("invalid signature")
+		}
+	case "ES":
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("key type does not match alg %s", alg)
+		}
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size {
+			return errors.New("invalid signature")
+		}
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		if !ecdsa.Verify(pub, digest, r, s) {
+			return errors.New("invalid signature")
+		}
+	}
+	return nil
+}
+
+func decodeSegment(seg string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+func claimsFromRaw(raw map[string]any) *Claims {
+	c := &Claims{Raw: raw}
+	c.Subject, _ = raw["sub"].(string)
+	c.Issuer, _ = raw["iss"].(string)
+	c.TenantID, _ = raw["tenant_id"].(string)
+	c.AgentID, _ = raw["agent_id"].(string)
+	if c.AgentID == "" {
+		c.AgentID = c.Subject
+	}
+	c.ExpiresAt, _ = numericDate(raw["exp"])
+	c.IssuedAt, _ = numericDate(raw["iat"])
+
+	switch aud := raw["aud"].(type) {
+	case string:
+		c.Audience = []string{aud}
+	case []any:
+		for _, a := range aud {
+			if s, ok := a.(string); ok {
+				c.Audience = append(c.Audience, s)
+			}
+		}
+	}
+	return c
+}
+
+func numericDate(v any) (time.Time, bool) {
+	f, ok := v.(float64)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(f), 0), true
+}
+
+func contains(values []string, want string) bool {
+	for _, v := range values {
+		if v == want {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,305 @@
+package identity
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testIssuer serves a JWKS document and signs tokens with its keys.
+type testIssuer struct {
+	mu      sync.Mutex
+	rsaKeys map[string]*rsa.PrivateKey
+	ecKeys  map[string]*ecdsa.PrivateKey
+	fetches atomic.Int32
+	server  *httptest.Server
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	t.Helper()
+	iss := &testIssuer{
+		rsaKeys: map[string]*rsa.PrivateKey{},
+		ecKeys:  map[string]*ecdsa.PrivateKey{},
+	}
+	iss.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		iss.fetches.Add(1)
+		iss.mu.Lock()
+		defer iss.mu.Unlock()
+		var keys []map[string]string
+		for kid, k := range iss.rsaKeys {
+			keys = append(keys, map[string]string{
+				"kty": "RSA", "kid": kid, "use": "sig",
+				"n": b64(k.N.Bytes()), "e": b64(big.NewInt(int64(k.E)).Bytes()),
+			})
+		}
+		for kid, k := range iss.ecKeys {
+			keys = append(keys, map[string]string{
+				"kty": "EC", "kid": kid, "crv": "P-256",
+				"x": b64(k.X.FillBytes(make([]byte, 32))), "y": b64(k.Y.FillBytes(make([]byte, 32))),
+			})
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": keys})
+	}))
+	t.Cleanup(iss.server.Close)
+	return iss
+}
+
+func (iss *testIssuer) addRSA(t *testing.T, kid string) {
+	t.Helper()
+	k, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iss.mu.Lock()
+	iss.rsaKeys[kid] = k
+	iss.mu.Unlock()
+}
+
+func (iss *testIssuer) addEC(t *testing.T, kid string) {
+	t.Helper()
+	k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iss.mu.Lock()
+	iss.ecKeys[kid] = k
+	iss.mu.Unlock()
+}
+
+func (iss *testIssuer) sign(t *testing.T, kid string, claims map[string]any) string {
+	t.Helper()
+	iss.mu.Lock()
+	defer iss.mu.Unlock()
+
+	alg := "RS256"
+	if _, ok := iss.ecKeys[kid]; ok {
+		alg = "ES256"
+	}
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	input := b64(header) + "." + b64(payload)
+	digest := sha256.Sum256([]byte(input))
+
+	var sig []byte
+	if k, ok := iss.rsaKeys[kid]; ok {
+		var err error
+		sig, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+	} else {
+		r, s, err := ecdsa.Sign(rand.Reader, iss.ecKeys[kid], digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	}
+	return input + "." + b64(sig)
+}
+
+func b64(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+
+func validClaims() map[string]any {
+	return map[string]any{
+		"sub":       "agent-1",
+		"tenant_id": "tenant-1",
+		"iss":       "dome",
+		"aud":       "dome-agents",
+		"exp":       time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+func TestVerifier_Verify(t *testing.T) {
+	iss := newTestIssuer(t)
+	iss.addRSA(t, "rsa-1")
+	iss.addEC(t, "ec-1")
+
+	v := NewVerifier(NewKeySet(nil, iss.server.URL, time.Hour), "dome")
+
+	for _, kid := range []string{"rsa-1", "ec-1"} {
+		claims, err := v.Verify(context.Background(), iss.sign(t, kid, validClaims()), "dome-agents")
+		if err != nil {
+			t.Fatalf("%s: Verify error: %v", kid, err)
+		}
+		if claims.AgentID != "agent-1" || claims.TenantID != "tenant-1" {
+			t.Errorf("%s: claims = %+v", kid, claims)
+		}
+	}
+	if n := iss.fetches.Load(); n != 1 {
+		t.Errorf("JWKS fetches = %d, want 1 (cached)", n)
+	}
+}
+
+func TestVerifier_Rejects(t *testing.T) {
+	iss := newTestIssuer(t)
+	iss.addRSA(t, "rsa-1")
+	v := NewVerifier(NewKeySet(nil, iss.server.URL, time.Hour), "dome")
+
+	with := func(k string, val any) map[string]any {
+		c := validClaims()
+		if val == nil {
+			delete(c, k)
+		} else {
+			c[k] = val
+		}
+		return c
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		want  string
+	}{
+		{"malformed", "not-a-jwt", "malformed"},
+		{"expired", iss.sign(t, "rsa-1", with("exp", time.Now().Add(-time.Hour).Unix())), "expired"},
+		{"no expiry", iss.sign(t, "rsa-1", with("exp", nil)), "no expiry"},
+		{"not yet valid", iss.sign(t, "rsa-1", with("nbf", time.Now().Add(time.Hour).Unix())), "not yet valid"},
+		{"wrong issuer", iss.sign(t, "rsa-1", with("iss", "evil")), "issuer"},
+		{"wrong audience", iss.sign(t, "rsa-1", with("aud", []string{"other"})), "audience"},
+		{"no audience", iss.sign(t, "rsa-1", with("aud", nil)), "audience"},
+		{"no identity", iss.sign(t, "rsa-1", with("sub", nil)), "no agent identity"},
+		{"unsigned", b64([]byte(`{"alg":"none","kid":"rsa-1"}`)) + "." + b64([]byte(`{}`)) + ".", "unsupported alg"},
+	}
+
+	tampered := iss.sign(t, "rsa-1", validClaims())
+	parts := strings.Split(tampered, ".")
+	forged, _ := json.Marshal(with("sub", "agent-admin"))
+	tests = append(tests, struct {
+		name  string
+		token string
+		want  string
+	}{"tampered", parts[0] + "." + b64(forged) + "." + parts[2], "invalid signature"})
+
+	// A verifier never accepts a token for any audience.
+	if _, err := v.Verify(context.Background(), iss.sign(t, "rsa-1", validClaims()), ""); err == nil {
+		t.Error("expected error verifying without an audience")
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := v.Verify(context.Background(), tt.token, "dome-agents")
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeySet_Rotation(t *testing.T) {
+	iss := newTestIssuer(t)
+	iss.addRSA(t, "old")
+
+	now := time.Now()
+	keys := NewKeySet(nil, iss.server.URL, time.Hour)
+	keys.nowFunc = func() time.Time { return now }
+	v := NewVerifier(keys, "")
+
+	if _, err := v.Verify(context.Background(), iss.sign(t, "old", validClaims()), "dome-agents"); err != nil {
+		t.Fatalf("Verify old key: %v", err)
+	}
+
+	// The issuer rotates to a new key.
+	iss.addRSA(t, "new")
+	token := iss.sign(t, "new", validClaims())
+
+	// Within the refetch guard interval the unknown key is rejected.
+	if _, err := v.Verify(context.Background(), token, "dome-agents"); err != ErrUnknownKey {
+		t.Fatalf("error = %v, want ErrUnknownKey", err)
+	}
+
+	// After the guard interval an unknown kid triggers a refetch.
+	now = now.Add(minRefetchInterval)
+	if _, err := v.Verify(context.Background(), token, "dome-agents"); err != nil {
+		t.Fatalf("Verify new key after rotation: %v", err)
+	}
+	if n := iss.fetches.Load(); n != 2 {
+		t.Errorf("JWKS fetches = %d, want 2", n)
+	}
+}
+
+func TestKeySet_ThrottlesFailedFetches(t *testing.T) {
+	var fetches atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fetches.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(srv.Close)
+
+	now := time.Now()
+	keys := NewKeySet(nil, srv.URL, time.Hour)
+	keys.nowFunc = func() time.Time { return now }
+
+	for range 3 {
+		if _, err := keys.Key(context.Background(), "k1"); err == nil || !strings.Contains(err.Error(), "503") {
+			t.Fatalf("error = %v, want unexpected status 503", err)
+		}
+	}
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("JWKS fetches within guard interval = %d, want 1", n)
+	}
+
+	now = now.Add(minRefetchInterval)
+	_, _ = keys.Key(context.Background(), "k1")
+	if n := fetches.Load(); n != 2 {
+		t.Errorf("JWKS fetches after guard interval = %d, want 2", n)
+	}
+}
+
+func TestKeySet_SharesInflightFetch(t *testing.T) {
+	iss := newTestIssuer(t)
+	iss.addRSA(t, "k1")
+	release := make(chan struct{})
+	var fetches atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		<-release
+		iss.server.Config.Handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	keys := NewKeySet(nil, srv.URL, time.Hour)
+
+	// A caller that gives up does not cancel the fetch for the others.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := keys.Key(ctx, "k1"); err != context.Canceled {
+		t.Fatalf("canceled caller error = %v, want context.Canceled", err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 5)
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := keys.Key(context.Background(), "k1")
+			errs <- err
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("Key: %v", err)
+		}
+	}
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("JWKS fetches = %d, want 1", n)
+	}
+}
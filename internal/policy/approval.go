@@ -0,0 +1,47 @@
+package policy
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/cedar-policy/cedar-go"
+)
+
+// ApprovalAnnotation is the Cedar policy annotation that makes a permit
+// policy conditional on human approval, e.g. @approval("finance") to route
+// the request to the finance approvers, or @approval("") for any approver.
+const ApprovalAnnotation = "approval"
+
+// approvalFor returns the first determining policy of an allowed request
+// that requires human approval, or "" if none does, together with the
+// approver groups named by the determining policies. Callers must hold e.mu.
+func (e *Engine) approvalFor(diagnostic cedar.Diagnostic) (cedar.PolicyID, []string) {
+	var required cedar.PolicyID
+	var approvers []string
+	for _, reason := range diagnostic.Reasons {
+		p := e.policySet.Get(reason.PolicyID)
+		if p == nil {
+			continue
+		}
+		value, ok := p.Annotations()[ApprovalAnnotation]
+		if !ok {
+			continue
+		}
+		if required == "" {
+			required = reason.PolicyID
+		}
+		if value != "" && !slices.Contains(approvers, string(value)) {
+			approvers = append(approvers, string(value))
+		}
+	}
+	return required, approvers
+}
+
+// validateApproval rejects @approval on forbid policies, where it would
+// have no effect.
+func validateApproval(id cedar.PolicyID, p *cedar.Policy) error {
+	if _, ok := p.Annotations()[ApprovalAnnotation]; ok && p.Effect() != cedar.Permit {
+		return fmt.Errorf("policy %s: @approval is only valid on permit policies", id)
+	}
+	return nil
+}
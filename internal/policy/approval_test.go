@@ -0,0 +1,57 @@
+package policy
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+const approvalCedar = `
+@id("wire-transfer")
+@approval("finance")
+permit(
+    principal is Dome::Agent,
+    action == Dome::Action::"payments:transfer",
+    resource
+);
+
+@id("read")
+permit(
+    principal is Dome::Agent,
+    action == Dome::Action::"payments:read",
+    resource
+);
+`
+
+func TestEngine_Evaluate_Approval(t *testing.T) {
+	e := NewEngine()
+	if err := e.LoadBundle(map[string]string{"payments.cedar": approvalCedar}, "v1"); err != nil {
+		t.Fatal(err)
+	}
+
+	d := e.Evaluate(AgentContext{ID: "agent-1"}, CheckInput{Action: "payments:transfer", Resource: "acct-1"})
+	if !d.Allow || !d.RequiresApproval {
+		t.Fatalf("decision = %+v, want allow requiring approval", d)
+	}
+	if !slices.Equal(d.Approvers, []string{"finance"}) {
+		t.Errorf("Approvers = %v, want [finance]", d.Approvers)
+	}
+	if !strings.HasPrefix(d.Reason, "approval required by policy: ") {
+		t.Errorf("Reason = %q", d.Reason)
+	}
+
+	d = e.Evaluate(AgentContext{ID: "agent-1"}, CheckInput{Action: "payments:read", Resource: "acct-1"})
+	if !d.Allow || d.RequiresApproval {
+		t.Errorf("decision = %+v, want allow without approval", d)
+	}
+}
+
+func TestEngine_LoadBundle_ApprovalOnForbid(t *testing.T) {
+	e := NewEngine()
+	err := e.LoadBundle(map[string]string{
+		"bad.cedar": `@approval("") forbid(principal, action, resource);`,
+	}, "v1")
+	if err == nil {
+		t.Fatal("expected error for @approval on a forbid policy")
+	}
+}
@@ -13,6 +13,14 @@ type Decision struct {
 	Allow         bool
 	Reason        string
 	PolicyVersion string
+	// Quotas lists the rate limits that apply to an allowed request, from
+	// bundle quota rules and @quota annotations on the determining policies.
+	Quotas []Quota
+	// RequiresApproval is set on an allowed request when a determining
+	// policy carries an @approval annotation.
+	RequiresApproval bool
+	// Approvers lists the approver groups named by those annotations.
+	Approvers []string
 }
 
 // AgentContext holds agent attributes for policy evaluation.
@@ -32,6 +40,32 @@ type CheckInput struct {
 	ResourceType       string // "mcp", "llm", "credential", or empty
 	RequiredCapability string
 	Context            map[string]string
+	// ContextAttributes are typed context values, converted with ToValue.
+	// They take precedence over Context entries with the same key.
+	ContextAttributes map[string]any
+
+	// ResourceAttributes are added to the resource entity alongside the
+	// SDK-provided "path" and "type" attributes, which are reserved.
+	ResourceAttributes map[string]any
+	// ResourceParents places the resource in entity hierarchies.
+	ResourceParents []EntityRef
+	// Entities are additional entities merged into the entity map.
+	Entities []EntityInput
+}
+
+// Validate reports whether the input's attributes and entities can be
+// converted to Cedar values.
+func (in CheckInput) Validate() error {
+	if _, err := buildContext(in); err != nil {
+		return err
+	}
+	_, err := buildEntities(
+		cedar.NewEntityUID(EntityTypeAgent, ""),
+		mapResource(in),
+		AgentContext{},
+		in,
+	)
+	return err
 }
 
 // Engine evaluates Cedar policies locally.
@@ -39,6 +73,7 @@ type Engine struct {
 	mu            sync.RWMutex
 	policySet     *cedar.PolicySet
 	policyVersion string
+	quotaRules    []QuotaRule
 }
 
 // NewEngine creates a new Cedar policy engine with no policies loaded.
@@ -51,27 +86,123 @@ func NewEngine() *Engine {
 // LoadBundle replaces the current policy set with policies parsed from raw
 // Cedar source files. Each entry maps filename to content.
 func (e *Engine) LoadBundle(policies map[string]string, version string) error {
+	return e.LoadBundleWithTemplates(policies, nil, nil, version)
+}
+
+// LoadBundleWithTemplates is like LoadBundle but also instantiates
+// template-linked policies. Each link binds the ?principal and ?resource
+// slots of a template and is added to the policy set under the link's ID.
+func (e *Engine) LoadBundleWithTemplates(policies map[string]string, templates []PolicyTemplate, links []TemplateLink, version string) error {
+	newPolicySet, err := compilePolicySet(policies, templates, links)
+	if err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	e.policySet = newPolicySet
+	e.policyVersion = version
+	e.mu.Unlock()
+
+	return nil
+}
+
+// LoadBundleResponse installs the policies, template links and quota rules
+// of a fetched bundle. All of them are validated first and swapped in
+// together, so a concurrent Evaluate sees either the old bundle or the new
+// one, never quotas of one with policies of the other.
+func (e *Engine) LoadBundleResponse(b *BundleResponse) error {
+	policies := make(map[string]string, len(b.Policies))
+	for _, p := range b.Policies {
+		policies[p.Filename] = p.Content
+	}
+	newPolicySet, err := compilePolicySet(policies, b.Templates, b.TemplateLinks)
+	if err != nil {
+		return fmt.Errorf("load bundle: %w", err)
+	}
+	if err := validateQuotaRules(b.Quotas); err != nil {
+		return fmt.Errorf("load quotas: %w", err)
+	}
+
+	e.mu.Lock()
+	e.policySet = newPolicySet
+	e.policyVersion = b.Version
+	e.quotaRules = b.Quotas
+	e.mu.Unlock()
+
+	return nil
+}
+
+// compilePolicySet parses and validates policies and instantiates the
+// template links.
+func compilePolicySet(policies map[string]string, templates []PolicyTemplate, links []TemplateLink) (*cedar.PolicySet, error) {
 	newPolicySet := cedar.NewPolicySet()
 
 	for filename, content := range policies {
 		parsed, err := cedar.NewPolicySetFromBytes(filename, []byte(content))
 		if err != nil {
-			return fmt.Errorf("parse %s: %w", filename, err)
+			return nil, fmt.Errorf("parse %s: %w", filename, err)
 		}
 		for name, p := range parsed.All() {
+			if err := validateAnnotations(name, p); err != nil {
+				return nil, fmt.Errorf("parse %s: %w", filename, err)
+			}
 			uniqueName := cedar.PolicyID(fmt.Sprintf("%s:%s", filename, name))
 			newPolicySet.Add(uniqueName, p)
 		}
 	}
 
+	templateByID := make(map[string]PolicyTemplate, len(templates))
+	for _, tmpl := range templates {
+		if err := validateTemplate(tmpl); err != nil {
+			return nil, err
+		}
+		templateByID[tmpl.ID] = tmpl
+	}
+
+	for _, link := range links {
+		tmpl, ok := templateByID[link.TemplateID]
+		if !ok {
+			return nil, fmt.Errorf("link %s: unknown template %q", link.ID, link.TemplateID)
+		}
+		p, err := linkTemplate(tmpl, link)
+		if err != nil {
+			return nil, err
+		}
+		id := cedar.PolicyID(link.ID)
+		if err := validateAnnotations(id, p); err != nil {
+			return nil, fmt.Errorf("link %s: %w", link.ID, err)
+		}
+		if !newPolicySet.Add(id, p) {
+			return nil, fmt.Errorf("link %s: duplicate policy id", link.ID)
+		}
+	}
+
+	return newPolicySet, nil
+}
+
+// LoadQuotas replaces the bundle-declared quota rules. Rules are validated
+// before any are installed.
+func (e *Engine) LoadQuotas(rules []QuotaRule) error {
+	if err := validateQuotaRules(rules); err != nil {
+		return err
+	}
+
 	e.mu.Lock()
-	e.policySet = newPolicySet
-	e.policyVersion = version
+	e.quotaRules = rules
 	e.mu.Unlock()
 
 	return nil
 }
 
+func validateQuotaRules(rules []QuotaRule) error {
+	for _, r := range rules {
+		if _, err := r.compile(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Evaluate runs Cedar policy evaluation for the given agent and request.
 func (e *Engine) Evaluate(agent AgentContext, input CheckInput) *Decision {
 	e.mu.RLock()
@@ -82,44 +213,77 @@ func (e *Engine) Evaluate(agent AgentContext, input CheckInput) *Decision {
 	action := cedar.NewEntityUID(EntityTypeAction, cedar.String(input.Action))
 	resource := mapResource(input)
 
-	contextMap := cedar.RecordMap{}
-	if input.RequiredCapability != "" {
-		contextMap[cedar.String("required_capability")] = cedar.String(input.RequiredCapability)
-	}
-	for k, v := range input.Context {
-		contextMap[cedar.String(k)] = cedar.String(v)
+	context, err := buildContext(input)
+	if err != nil {
+		return &Decision{
+			Allow:         false,
+			Reason:        fmt.Sprintf("invalid request context: %v", err),
+			PolicyVersion: e.policyVersion,
+		}
 	}
 
 	req := cedar.Request{
 		Principal: principal,
 		Action:    action,
 		Resource:  resource,
-		Context:   cedar.NewRecord(contextMap),
-	}
-
-	// Build entities.
-	entities := cedar.EntityMap{}
-
-	agentEntity := cedar.Entity{
-		UID:        principal,
-		Attributes: buildAgentAttributes(agent),
+		Context:   context,
 	}
-	entities[principal] = agentEntity
 
-	resourceEntity := cedar.Entity{
-		UID:        resource,
-		Attributes: buildResourceAttributes(input),
+	// Build entities. Invalid caller-supplied entities fail closed.
+	entities, err := buildEntities(principal, resource, agent, input)
+	if err != nil {
+		return &Decision{
+			Allow:         false,
+			Reason:        fmt.Sprintf("invalid request entities: %v", err),
+			PolicyVersion: e.policyVersion,
+		}
 	}
-	entities[resource] = resourceEntity
 
 	// Evaluate.
 	decision, diagnostic := cedar.Authorize(e.policySet, entities, req)
 
-	return &Decision{
+	d := &Decision{
 		Allow:         decision == cedar.Allow,
 		Reason:        extractReason(decision, diagnostic),
 		PolicyVersion: e.policyVersion,
 	}
+	if d.Allow {
+		d.Quotas = e.quotasFor(input, diagnostic)
+		if id, approvers := e.approvalFor(diagnostic); id != "" {
+			d.RequiresApproval = true
+			d.Approvers = approvers
+			d.Reason = fmt.Sprintf("approval required by policy: %s", id)
+		}
+	}
+	return d
+}
+
+// quotasFor collects the quotas that apply to an allowed request. Callers
+// must hold e.mu.
+func (e *Engine) quotasFor(input CheckInput, diagnostic cedar.Diagnostic) []Quota {
+	var quotas []Quota
+	for _, r := range e.quotaRules {
+		if !r.matches(input) {
+			continue
+		}
+		if q, err := r.compile(); err == nil {
+			quotas = append(quotas, q)
+		}
+	}
+	for _, reason := range diagnostic.Reasons {
+		p := e.policySet.Get(reason.PolicyID)
+		if p == nil {
+			continue
+		}
+		value, ok := p.Annotations()[QuotaAnnotation]
+		if !ok {
+			continue
+		}
+		if q, err := ParseQuota(string(reason.PolicyID), string(value)); err == nil {
+			quotas = append(quotas, q)
+		}
+	}
+	return quotas
 }
 
 // PolicyCount returns the number of loaded policies.
@@ -138,6 +302,16 @@ func (e *Engine) HasPolicies() bool {
 	return e.PolicyCount() > 0
 }
 
+// validateAnnotations checks the SDK-interpreted annotations on a policy.
+func validateAnnotations(id cedar.PolicyID, p *cedar.Policy) error {
+	if value, ok := p.Annotations()[QuotaAnnotation]; ok {
+		if _, err := ParseQuota(string(id), string(value)); err != nil {
+			return err
+		}
+	}
+	return validateApproval(id, p)
+}
+
 func mapResource(input CheckInput) cedar.EntityUID {
 	resType := input.ResourceType
 	if resType == "" {
@@ -183,16 +357,6 @@ func buildAgentAttributes(agent AgentContext) cedar.Record {
 	return cedar.NewRecord(attrs)
 }
 
-func buildResourceAttributes(input CheckInput) cedar.Record {
-	attrs := cedar.RecordMap{
-		cedar.String("path"): cedar.String(input.Resource),
-	}
-	if input.ResourceType != "" {
-		attrs[cedar.String("type")] = cedar.String(input.ResourceType)
-	}
-	return cedar.NewRecord(attrs)
-}
-
 func toStringSet(values []string) cedar.Value {
 	if len(values) == 0 {
 		return cedar.NewSet()
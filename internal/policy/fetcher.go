@@ -14,6 +14,13 @@ type BundleResponse struct {
 	Version  string       `json:"version"`
 	Hash     string       `json:"hash"`
 	Policies []PolicyFile `json:"policies"`
+	Quotas   []QuotaRule  `json:"quotas,omitempty"`
+
+	// Templates and TemplateLinks carry template-linked policies, so the
+	// server can send one template plus per-agent slot bindings instead of
+	// rendering near-identical static policies.
+	Templates     []PolicyTemplate `json:"templates,omitempty"`
+	TemplateLinks []TemplateLink   `json:"template_links,omitempty"`
 }
 
 // PolicyFile represents a single Cedar policy file in a bundle.
@@ -172,13 +179,8 @@ func (s *Syncer) syncOnce() error {
 		return nil
 	}
 
-	policies := make(map[string]string, len(result.Bundle.Policies))
-	for _, p := range result.Bundle.Policies {
-		policies[p.Filename] = p.Content
-	}
-
-	if err := s.engine.LoadBundle(policies, result.Bundle.Version); err != nil {
-		return fmt.Errorf("load bundle: %w", err)
+	if err := s.engine.LoadBundleResponse(result.Bundle); err != nil {
+		return err
 	}
 
 	s.logger("policy bundle updated",
@@ -0,0 +1,168 @@
+package policy
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// QuotaAnnotation is the Cedar policy annotation that attaches a rate limit
+// to a permit policy, e.g. @quota("60/1m") for 60 requests per minute.
+const QuotaAnnotation = "quota"
+
+// QuotaRule declares a rate limit in a policy bundle. Rules are matched
+// against the action and resource of each check.
+type QuotaRule struct {
+	// ID uniquely identifies the rule within the bundle.
+	ID string `json:"id"`
+	// Action restricts the rule to one action. Empty or "*" matches any.
+	Action string `json:"action,omitempty"`
+	// Resource restricts the rule to one resource. Empty or "*" matches any;
+	// a trailing "*" matches by prefix (e.g. "openai/*").
+	Resource string `json:"resource,omitempty"`
+	// Limit is the number of requests allowed per Window.
+	Limit int `json:"limit"`
+	// Window is a Go duration string (e.g. "1m", "1h").
+	Window string `json:"window"`
+	// Burst optionally allows short bursts above the steady rate. Defaults
+	// to Limit.
+	Burst int `json:"burst,omitempty"`
+}
+
+// Quota is a resolved rate limit that applies to a single check.
+type Quota struct {
+	ID     string
+	Limit  int
+	Window time.Duration
+	Burst  int
+}
+
+// String renders the quota in annotation form, e.g. "60/1m0s".
+func (q Quota) String() string {
+	return fmt.Sprintf("%d/%s", q.Limit, q.Window)
+}
+
+// ParseQuota parses an annotation value of the form "<limit>/<window>",
+// e.g. "60/1m" or "1000/24h".
+func ParseQuota(id, value string) (Quota, error) {
+	limitStr, windowStr, ok := strings.Cut(strings.TrimSpace(value), "/")
+	if !ok {
+		return Quota{}, fmt.Errorf("quota %q: want <limit>/<window>", value)
+	}
+	limit, err := strconv.Atoi(strings.TrimSpace(limitStr))
+	if err != nil || limit <= 0 {
+		return Quota{}, fmt.Errorf("quota %q: limit must be a positive integer", value)
+	}
+	window, err := time.ParseDuration(strings.TrimSpace(windowStr))
+	if err != nil || window <= 0 {
+		return Quota{}, fmt.Errorf("quota %q: window must be a positive duration", value)
+	}
+	return Quota{ID: id, Limit: limit, Window: window, Burst: limit}, nil
+}
+
+// compile validates the rule and converts it to a Quota.
+func (r QuotaRule) compile() (Quota, error) {
+	if r.ID == "" {
+		return Quota{}, fmt.Errorf("quota rule missing id")
+	}
+	if r.Limit <= 0 {
+		return Quota{}, fmt.Errorf("quota rule %s: limit must be positive", r.ID)
+	}
+	window, err := time.ParseDuration(r.Window)
+	if err != nil || window <= 0 {
+		return Quota{}, fmt.Errorf("quota rule %s: invalid window %q", r.ID, r.Window)
+	}
+	burst := r.Burst
+	if burst <= 0 {
+		burst = r.Limit
+	}
+	return Quota{ID: r.ID, Limit: r.Limit, Window: window, Burst: burst}, nil
+}
+
+// matches reports whether the rule applies to the given input.
+func (r QuotaRule) matches(input CheckInput) bool {
+	return matchPattern(r.Action, input.Action) && matchPattern(r.Resource, input.Resource)
+}
+
+func matchPattern(pattern, value string) bool {
+	switch {
+	case pattern == "" || pattern == "*":
+		return true
+	case strings.HasSuffix(pattern, "*"):
+		return strings.HasPrefix(value, strings.TrimSuffix(pattern, "*"))
+	default:
+		return pattern == value
+	}
+}
+
+// Limiter is an in-process token bucket rate limiter keyed by string.
+type Limiter struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	nowFunc func() time.Time // for testing
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewLimiter creates an empty token bucket limiter.
+func NewLimiter() *Limiter {
+	return &Limiter{
+		buckets: make(map[string]*bucket),
+		nowFunc: time.Now,
+	}
+}
+
+// Take consumes one token from the bucket identified by key. Buckets start
+// full and refill at q.Limit tokens per q.Window up to q.Burst. Returns
+// false if the bucket is empty.
+func (l *Limiter) Take(key string, q Quota) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	capacity := float64(q.Burst)
+	if capacity <= 0 {
+		capacity = float64(q.Limit)
+	}
+	now := l.nowFunc()
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: capacity, last: now}
+		l.buckets[key] = b
+	}
+
+	rate := float64(q.Limit) / float64(q.Window)
+	b.tokens += float64(now.Sub(b.last)) * rate
+	if b.tokens > capacity {
+		b.tokens = capacity
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// Refund returns one token taken by Take to the bucket identified by key,
+// up to the bucket's capacity.
+func (l *Limiter) Refund(key string, q Quota) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.buckets[key]
+	if !ok {
+		return
+	}
+	capacity := float64(q.Burst)
+	if capacity <= 0 {
+		capacity = float64(q.Limit)
+	}
+	b.tokens = min(b.tokens+1, capacity)
+}
@@ -0,0 +1,191 @@
+package policy
+
+import (
+	"testing"
+	"time"
+)
+
+const quotaCedar = `
+@id("llm-chat")
+@quota("2/1m")
+permit(
+    principal is Dome::Agent,
+    action == Dome::Action::"llm:chat",
+    resource
+);
+`
+
+func TestParseQuota(t *testing.T) {
+	q, err := ParseQuota("p", "60/1m")
+	if err != nil {
+		t.Fatalf("ParseQuota error: %v", err)
+	}
+	if q.Limit != 60 || q.Window != time.Minute || q.Burst != 60 {
+		t.Errorf("ParseQuota = %+v, want 60 per 1m with burst 60", q)
+	}
+
+	for _, bad := range []string{"60", "0/1m", "x/1m", "10/forever", "10/-1s"} {
+		if _, err := ParseQuota("p", bad); err == nil {
+			t.Errorf("ParseQuota(%q) expected error", bad)
+		}
+	}
+}
+
+func TestLimiter_Take(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := NewLimiter()
+	l.nowFunc = func() time.Time { return now }
+
+	q := Quota{ID: "q", Limit: 2, Window: time.Minute, Burst: 2}
+	if !l.Take("k", q) || !l.Take("k", q) {
+		t.Fatal("expected first two takes to succeed")
+	}
+	if l.Take("k", q) {
+		t.Fatal("expected third take to fail")
+	}
+
+	// A different key has its own bucket.
+	if !l.Take("other", q) {
+		t.Error("expected independent bucket for other key")
+	}
+
+	// Half a window refills one token.
+	now = now.Add(30 * time.Second)
+	if !l.Take("k", q) {
+		t.Error("expected take to succeed after refill")
+	}
+	if l.Take("k", q) {
+		t.Error("expected bucket to be empty again")
+	}
+}
+
+func TestLimiter_Refund(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := NewLimiter()
+	l.nowFunc = func() time.Time { return now }
+
+	q := Quota{ID: "q", Limit: 1, Window: time.Minute, Burst: 1}
+	l.Refund("k", q) // unknown key: no bucket is created
+	if !l.Take("k", q) {
+		t.Fatal("expected first take to succeed")
+	}
+	l.Refund("k", q)
+	l.Refund("k", q) // capped at capacity
+	if !l.Take("k", q) {
+		t.Fatal("expected take after refund to succeed")
+	}
+	if l.Take("k", q) {
+		t.Error("expected refunds to be capped at the bucket capacity")
+	}
+}
+
+func TestEngine_LoadBundleResponse_Atomic(t *testing.T) {
+	e := NewEngine()
+	if err := e.LoadBundleResponse(&BundleResponse{
+		Version:  "v1",
+		Policies: []PolicyFile{{Filename: "quota.cedar", Content: quotaCedar}},
+		Quotas:   []QuotaRule{{ID: "q", Limit: 1, Window: "1m"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A bad quota rule leaves both the policies and the quotas in place.
+	if err := e.LoadBundleResponse(&BundleResponse{
+		Version: "v2",
+		Quotas:  []QuotaRule{{ID: "q", Limit: 1, Window: "soon"}},
+	}); err == nil {
+		t.Fatal("expected invalid quota error")
+	}
+	// So does a bad policy.
+	if err := e.LoadBundleResponse(&BundleResponse{
+		Version:  "v3",
+		Policies: []PolicyFile{{Filename: "bad.cedar", Content: "permit("}},
+	}); err == nil {
+		t.Fatal("expected parse error")
+	}
+
+	d := e.Evaluate(AgentContext{ID: "agent-1"}, CheckInput{Action: ActionLLMChat, Resource: "openai/gpt-4"})
+	if !d.Allow || d.PolicyVersion != "v1" {
+		t.Fatalf("expected v1 bundle to stay installed, got %+v", d)
+	}
+	if len(e.quotaRules) != 1 || e.quotaRules[0].Window != "1m" {
+		t.Errorf("quota rules = %+v, want v1 rules", e.quotaRules)
+	}
+}
+
+func TestEngine_Evaluate_QuotaAnnotation(t *testing.T) {
+	e := NewEngine()
+	if err := e.LoadBundle(map[string]string{"quota.cedar": quotaCedar}, "v1"); err != nil {
+		t.Fatal(err)
+	}
+
+	d := e.Evaluate(AgentContext{ID: "agent-1"}, CheckInput{
+		Action:   ActionLLMChat,
+		Resource: "openai/gpt-4",
+	})
+	if !d.Allow {
+		t.Fatalf("expected allow, got deny: %s", d.Reason)
+	}
+	if len(d.Quotas) != 1 {
+		t.Fatalf("Quotas = %v, want one quota", d.Quotas)
+	}
+	if d.Quotas[0].Limit != 2 || d.Quotas[0].Window != time.Minute {
+		t.Errorf("Quota = %+v, want 2 per 1m", d.Quotas[0])
+	}
+}
+
+func TestEngine_LoadBundle_InvalidQuotaAnnotation(t *testing.T) {
+	e := NewEngine()
+	err := e.LoadBundle(map[string]string{
+		"bad.cedar": `@quota("lots") permit(principal, action, resource);`,
+	}, "v1")
+	if err == nil {
+		t.Fatal("expected error for invalid @quota annotation")
+	}
+}
+
+func TestEngine_Evaluate_QuotaRules(t *testing.T) {
+	e := NewEngine()
+	if err := e.LoadBundle(map[string]string{"base.cedar": baseCedar}, "v1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.LoadQuotas([]QuotaRule{
+		{ID: "openai", Action: ActionLLMChat, Resource: "openai/*", Limit: 10, Window: "1m"},
+		{ID: "mcp", Action: ActionMCPCall, Limit: 5, Window: "1s"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	agent := AgentContext{ID: "agent-1", Capabilities: []string{ActionLLMChat}}
+	d := e.Evaluate(agent, CheckInput{
+		Action:             ActionLLMChat,
+		Resource:           "openai/gpt-4",
+		RequiredCapability: ActionLLMChat,
+	})
+	if !d.Allow {
+		t.Fatalf("expected allow, got deny: %s", d.Reason)
+	}
+	if len(d.Quotas) != 1 || d.Quotas[0].ID != "openai" {
+		t.Errorf("Quotas = %v, want [openai]", d.Quotas)
+	}
+
+	// Denied decisions carry no quotas.
+	d = e.Evaluate(AgentContext{ID: "agent-2"}, CheckInput{
+		Action:             ActionLLMChat,
+		Resource:           "openai/gpt-4",
+		RequiredCapability: ActionLLMChat,
+	})
+	if d.Allow || len(d.Quotas) != 0 {
+		t.Errorf("expected deny without quotas, got allow=%v quotas=%v", d.Allow, d.Quotas)
+	}
+}
+
+func TestEngine_LoadQuotas_Invalid(t *testing.T) {
+	e := NewEngine()
+	if err := e.LoadQuotas([]QuotaRule{{ID: "q", Limit: 1, Window: "soon"}}); err == nil {
+		t.Error("expected error for invalid window")
+	}
+	if err := e.LoadQuotas([]QuotaRule{{Limit: 1, Window: "1m"}}); err == nil {
+		t.Error("expected error for missing id")
+	}
+}
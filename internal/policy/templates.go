@@ -0,0 +1,177 @@
+package policy
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cedar-policy/cedar-go"
+)
+
+// Template slot names supported by Cedar.
+const (
+	SlotPrincipal = "?principal"
+	SlotResource  = "?resource"
+)
+
+// PolicyTemplate is a Cedar policy containing ?principal and/or ?resource
+// slots. Templates are never evaluated directly; each TemplateLink
+// instantiates one policy from a template.
+type PolicyTemplate struct {
+	ID      string `json:"id"`
+	Content string `json:"content"`
+}
+
+// TemplateLink instantiates a template by binding its slots to entities.
+type TemplateLink struct {
+	// ID becomes the policy ID of the linked policy.
+	ID string `json:"id"`
+	// TemplateID references a PolicyTemplate in the same bundle.
+	TemplateID string `json:"template_id"`
+	// Values binds slot names ("?principal", "?resource") to entities.
+	Values map[string]EntityRef `json:"values"`
+}
+
+// EntityRef identifies a Cedar entity by type and ID.
+type EntityRef struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+// UID converts the reference to a Cedar entity UID.
+func (r EntityRef) UID() cedar.EntityUID {
+	return cedar.NewEntityUID(cedar.EntityType(r.Type), cedar.String(r.ID))
+}
+
+// linkTemplate renders the template with its slots bound and parses the
+// result as a single policy.
+func linkTemplate(tmpl PolicyTemplate, link TemplateLink) (*cedar.Policy, error) {
+	for slot, ref := range link.Values {
+		if slot != SlotPrincipal && slot != SlotResource {
+			return nil, fmt.Errorf("link %s: unknown slot %q", link.ID, slot)
+		}
+		if ref.Type == "" {
+			return nil, fmt.Errorf("link %s: slot %s missing entity type", link.ID, slot)
+		}
+	}
+
+	source, err := bindSlots(tmpl.Content, link.Values)
+	if err != nil {
+		return nil, fmt.Errorf("link %s: %w", link.ID, err)
+	}
+
+	parsed, err := cedar.NewPolicySetFromBytes(tmpl.ID, []byte(source))
+	if err != nil {
+		return nil, fmt.Errorf("link %s: parse template %s: %w", link.ID, tmpl.ID, err)
+	}
+
+	var policy *cedar.Policy
+	count := 0
+	for _, p := range parsed.All() {
+		policy = p
+		count++
+	}
+	if count != 1 {
+		return nil, fmt.Errorf("template %s: must contain exactly one policy, found %d", tmpl.ID, count)
+	}
+	return policy, nil
+}
+
+// validateTemplate checks that a template uses at least one slot and parses
+// once its slots are bound.
+func validateTemplate(tmpl PolicyTemplate) error {
+	if tmpl.ID == "" {
+		return fmt.Errorf("template missing id")
+	}
+
+	placeholder := EntityRef{Type: "Dome::Template", ID: "slot"}
+	values := map[string]EntityRef{}
+	for _, slot := range []string{SlotPrincipal, SlotResource} {
+		if hasSlot(tmpl.Content, slot) {
+			values[slot] = placeholder
+		}
+	}
+	if len(values) == 0 {
+		return fmt.Errorf("template %s: no ?principal or ?resource slot", tmpl.ID)
+	}
+
+	_, err := linkTemplate(tmpl, TemplateLink{ID: tmpl.ID, TemplateID: tmpl.ID, Values: values})
+	return err
+}
+
+// hasSlot reports whether the Cedar source references slot outside string
+// literals and comments.
+func hasSlot(source, slot string) bool {
+	found := false
+	_, _ = scanSlots(source, func(s string) (string, error) {
+		if s == slot {
+			found = true
+		}
+		return s, nil
+	})
+	return found
+}
+
+// bindSlots replaces each slot reference in source with the Cedar literal of
+// its bound entity. Every slot used by the source must be bound.
+func bindSlots(source string, values map[string]EntityRef) (string, error) {
+	return scanSlots(source, func(slot string) (string, error) {
+		ref, ok := values[slot]
+		if !ok {
+			return "", fmt.Errorf("slot %s is not bound", slot)
+		}
+		return string(ref.UID().MarshalCedar()), nil
+	})
+}
+
+// scanSlots walks Cedar source and calls replace for each ?slot token found
+// outside string literals and comments, substituting its return value.
+func scanSlots(source string, replace func(slot string) (string, error)) (string, error) {
+	var out strings.Builder
+	out.Grow(len(source))
+
+	for i := 0; i < len(source); {
+		ch := source[i]
+		switch {
+		case ch == '"':
+			// Copy the string literal verbatim, honoring escapes.
+			j := i + 1
+			for j < len(source) && source[j] != '"' {
+				if source[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j < len(source) {
+				j++
+			}
+			out.WriteString(source[i:j])
+			i = j
+		case ch == '/' && i+1 < len(source) && source[i+1] == '/':
+			j := strings.IndexByte(source[i:], '\n')
+			if j < 0 {
+				j = len(source) - i
+			}
+			out.WriteString(source[i : i+j])
+			i += j
+		case ch == '?':
+			j := i + 1
+			for j < len(source) && isIdentChar(source[j]) {
+				j++
+			}
+			rendered, err := replace(source[i:j])
+			if err != nil {
+				return "", err
+			}
+			out.WriteString(rendered)
+			i = j
+		default:
+			out.WriteByte(ch)
+			i++
+		}
+	}
+	return out.String(), nil
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9')
+}
@@ -0,0 +1,137 @@
+package policy
+
+import (
+	"strings"
+	"testing"
+)
+
+const toolGrantTemplate = `
+@id("tool-grant")
+permit(
+    principal == ?principal,
+    action == Dome::Action::"mcp:call",
+    resource == ?resource
+) when {
+    // "?resource" inside comments and strings is left alone.
+    context.note != "?principal"
+};
+`
+
+func TestEngine_LoadBundleWithTemplates(t *testing.T) {
+	e := NewEngine()
+	err := e.LoadBundleWithTemplates(nil,
+		[]PolicyTemplate{{ID: "tool-grant", Content: toolGrantTemplate}},
+		[]TemplateLink{
+			{
+				ID:         "grant-agent-1-salary",
+				TemplateID: "tool-grant",
+				Values: map[string]EntityRef{
+					SlotPrincipal: {Type: "Dome::Agent", ID: "agent-1"},
+					SlotResource:  {Type: "Dome::MCPTool", ID: "hr-mcp/get_salary"},
+				},
+			},
+			{
+				ID:         "grant-agent-2-search",
+				TemplateID: "tool-grant",
+				Values: map[string]EntityRef{
+					SlotPrincipal: {Type: "Dome::Agent", ID: "agent-2"},
+					SlotResource:  {Type: "Dome::MCPTool", ID: "hr-mcp/search"},
+				},
+			},
+		}, "v1")
+	if err != nil {
+		t.Fatalf("LoadBundleWithTemplates error: %v", err)
+	}
+	if e.PolicyCount() != 2 {
+		t.Errorf("PolicyCount = %d, want 2 (templates are not policies)", e.PolicyCount())
+	}
+
+	tests := []struct {
+		agent    string
+		resource string
+		want     bool
+	}{
+		{"agent-1", "hr-mcp/get_salary", true},
+		{"agent-1", "hr-mcp/search", false},
+		{"agent-2", "hr-mcp/search", true},
+		{"agent-3", "hr-mcp/search", false},
+	}
+	for _, tt := range tests {
+		d := e.Evaluate(AgentContext{ID: tt.agent}, CheckInput{
+			Action:   ActionMCPCall,
+			Resource: tt.resource,
+			Context:  map[string]string{"note": ""},
+		})
+		if d.Allow != tt.want {
+			t.Errorf("%s on %s: allow = %v, want %v (%s)", tt.agent, tt.resource, d.Allow, tt.want, d.Reason)
+		}
+	}
+
+	d := e.Evaluate(AgentContext{ID: "agent-1"}, CheckInput{
+		Action:   ActionMCPCall,
+		Resource: "hr-mcp/get_salary",
+		Context:  map[string]string{"note": ""},
+	})
+	if !strings.Contains(d.Reason, "grant-agent-1-salary") {
+		t.Errorf("Reason = %q, want link ID", d.Reason)
+	}
+}
+
+func TestEngine_LoadBundleWithTemplates_Errors(t *testing.T) {
+	tmpl := PolicyTemplate{ID: "tool-grant", Content: toolGrantTemplate}
+	agent := EntityRef{Type: "Dome::Agent", ID: "agent-1"}
+	tool := EntityRef{Type: "Dome::MCPTool", ID: "t"}
+
+	tests := []struct {
+		name      string
+		templates []PolicyTemplate
+		links     []TemplateLink
+		wantErr   string
+	}{
+		{
+			name:      "template without slots",
+			templates: []PolicyTemplate{{ID: "static", Content: `permit(principal, action, resource);`}},
+			wantErr:   "no ?principal or ?resource slot",
+		},
+		{
+			name:      "unknown template",
+			templates: []PolicyTemplate{tmpl},
+			links:     []TemplateLink{{ID: "l", TemplateID: "missing"}},
+			wantErr:   "unknown template",
+		},
+		{
+			name:      "unbound slot",
+			templates: []PolicyTemplate{tmpl},
+			links: []TemplateLink{{ID: "l", TemplateID: "tool-grant", Values: map[string]EntityRef{
+				SlotPrincipal: agent,
+			}}},
+			wantErr: "?resource is not bound",
+		},
+		{
+			name:      "unknown slot",
+			templates: []PolicyTemplate{tmpl},
+			links: []TemplateLink{{ID: "l", TemplateID: "tool-grant", Values: map[string]EntityRef{
+				SlotPrincipal: agent, SlotResource: tool, "?context": tool,
+			}}},
+			wantErr: "unknown slot",
+		},
+		{
+			name:      "duplicate link id",
+			templates: []PolicyTemplate{tmpl},
+			links: []TemplateLink{
+				{ID: "l", TemplateID: "tool-grant", Values: map[string]EntityRef{SlotPrincipal: agent, SlotResource: tool}},
+				{ID: "l", TemplateID: "tool-grant", Values: map[string]EntityRef{SlotPrincipal: agent, SlotResource: tool}},
+			},
+			wantErr: "duplicate policy id",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewEngine().LoadBundleWithTemplates(nil, tt.templates, tt.links, "v1")
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
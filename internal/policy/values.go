@@ -0,0 +1,251 @@
+package policy
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/cedar-policy/cedar-go"
+)
+
+// EntityInput is an additional entity supplied with a check, e.g. the owner
+// of a document or the MCP server hosting a tool.
+type EntityInput struct {
+	UID        EntityRef
+	Attributes map[string]any
+	Parents    []EntityRef
+}
+
+// Reserved resource attributes set by the SDK from CheckInput.
+var reservedResourceAttrs = map[string]bool{"path": true, "type": true}
+
+// ToValue converts a Go value to a Cedar value. Supported types are string,
+// bool, signed and unsigned integers (Long), float64/float32 (always
+// decimal, even when integral, so an attribute keeps one Cedar type across
+// requests), time.Time (datetime), time.Duration (duration), EntityRef
+// (entity reference), slices (set) and string-keyed maps (record).
+func ToValue(v any) (cedar.Value, error) {
+	switch x := v.(type) {
+	case string:
+		return cedar.String(x), nil
+	case bool:
+		return cedar.Boolean(x), nil
+	case int:
+		return cedar.Long(x), nil
+	case int8:
+		return cedar.Long(x), nil
+	case int16:
+		return cedar.Long(x), nil
+	case int32:
+		return cedar.Long(x), nil
+	case int64:
+		return cedar.Long(x), nil
+	case uint8:
+		return cedar.Long(x), nil
+	case uint16:
+		return cedar.Long(x), nil
+	case uint32:
+		return cedar.Long(x), nil
+	case uint:
+		if uint64(x) > math.MaxInt64 {
+			return nil, fmt.Errorf("integer %d overflows Cedar long", x)
+		}
+		return cedar.Long(x), nil
+	case uint64:
+		if x > math.MaxInt64 {
+			return nil, fmt.Errorf("integer %d overflows Cedar long", x)
+		}
+		return cedar.Long(x), nil
+	case float32:
+		return floatValue(float64(x))
+	case float64:
+		return floatValue(x)
+	case time.Time:
+		return cedar.NewDatetime(x), nil
+	case time.Duration:
+		return cedar.NewDuration(x), nil
+	case EntityRef:
+		if x.Type == "" {
+			return nil, fmt.Errorf("entity reference %q missing type", x.ID)
+		}
+		return x.UID(), nil
+	case []string:
+		items := make([]cedar.Value, len(x))
+		for i, s := range x {
+			items[i] = cedar.String(s)
+		}
+		return cedar.NewSet(items...), nil
+	case []EntityRef:
+		items := make([]cedar.Value, len(x))
+		for i, ref := range x {
+			val, err := ToValue(ref)
+			if err != nil {
+				return nil, err
+			}
+			items[i] = val
+		}
+		return cedar.NewSet(items...), nil
+	case []any:
+		items := make([]cedar.Value, len(x))
+		for i, item := range x {
+			val, err := ToValue(item)
+			if err != nil {
+				return nil, fmt.Errorf("[%d]: %w", i, err)
+			}
+			items[i] = val
+		}
+		return cedar.NewSet(items...), nil
+	case map[string]string:
+		rec := make(cedar.RecordMap, len(x))
+		for k, s := range x {
+			rec[cedar.String(k)] = cedar.String(s)
+		}
+		return cedar.NewRecord(rec), nil
+	case map[string]any:
+		rec, err := toRecord(x)
+		if err != nil {
+			return nil, err
+		}
+		return rec, nil
+	case nil:
+		return nil, fmt.Errorf("nil values are not supported")
+	default:
+		return nil, fmt.Errorf("unsupported attribute type %T", v)
+	}
+}
+
+func floatValue(f float64) (cedar.Value, error) {
+	d, err := cedar.NewDecimalFromFloat(f)
+	if err != nil {
+		return nil, fmt.Errorf("float %v: %w", f, err)
+	}
+	return d, nil
+}
+
+func toRecord(attrs map[string]any) (cedar.Record, error) {
+	rec := make(cedar.RecordMap, len(attrs))
+	for _, k := range sortedKeys(attrs) {
+		val, err := ToValue(attrs[k])
+		if err != nil {
+			return cedar.Record{}, fmt.Errorf("attribute %q: %w", k, err)
+		}
+		rec[cedar.String(k)] = val
+	}
+	return cedar.NewRecord(rec), nil
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func toUIDSet(refs []EntityRef) (cedar.EntityUIDSet, error) {
+	uids := make([]cedar.EntityUID, 0, len(refs))
+	for _, ref := range refs {
+		if ref.Type == "" {
+			return cedar.EntityUIDSet{}, fmt.Errorf("parent %q missing type", ref.ID)
+		}
+		uids = append(uids, ref.UID())
+	}
+	return cedar.NewEntityUIDSet(uids...), nil
+}
+
+// buildContext assembles the Cedar context record for a request.
+func buildContext(input CheckInput) (cedar.Record, error) {
+	contextMap := cedar.RecordMap{}
+	if input.RequiredCapability != "" {
+		contextMap[cedar.String("required_capability")] = cedar.String(input.RequiredCapability)
+	}
+	for k, v := range input.Context {
+		contextMap[cedar.String(k)] = cedar.String(v)
+	}
+	for _, k := range sortedKeys(input.ContextAttributes) {
+		v, err := ToValue(input.ContextAttributes[k])
+		if err != nil {
+			return cedar.Record{}, fmt.Errorf("context %q: %w", k, err)
+		}
+		contextMap[cedar.String(k)] = v
+	}
+	return cedar.NewRecord(contextMap), nil
+}
+
+// buildEntities assembles the Cedar entity map for a request: the agent,
+// the resource with its attributes and parents, and any additional entities.
+// Additional entities may extend the resource but never the principal, whose
+// attributes come only from the agent's registration.
+func buildEntities(principal, resource cedar.EntityUID, agent AgentContext, input CheckInput) (cedar.EntityMap, error) {
+	entities := cedar.EntityMap{}
+
+	entities[principal] = cedar.Entity{
+		UID:        principal,
+		Attributes: buildAgentAttributes(agent),
+	}
+
+	resourceAttrs := cedar.RecordMap{}
+	var resourceParents []EntityRef
+	for _, k := range sortedKeys(input.ResourceAttributes) {
+		if reservedResourceAttrs[k] {
+			return nil, fmt.Errorf("resource attribute %q is reserved", k)
+		}
+		val, err := ToValue(input.ResourceAttributes[k])
+		if err != nil {
+			return nil, fmt.Errorf("resource attribute %q: %w", k, err)
+		}
+		resourceAttrs[cedar.String(k)] = val
+	}
+	resourceParents = append(resourceParents, input.ResourceParents...)
+
+	for _, e := range input.Entities {
+		if e.UID.Type == "" {
+			return nil, fmt.Errorf("entity %q missing type", e.UID.ID)
+		}
+		uid := e.UID.UID()
+		if uid == principal {
+			return nil, fmt.Errorf("entity %s: cannot override the principal", uid)
+		}
+		attrs, err := toRecord(e.Attributes)
+		if err != nil {
+			return nil, fmt.Errorf("entity %s: %w", uid, err)
+		}
+		if uid == resource {
+			for k, v := range attrs.All() {
+				if reservedResourceAttrs[string(k)] {
+					return nil, fmt.Errorf("entity %s: attribute %q is reserved", uid, k)
+				}
+				resourceAttrs[k] = v
+			}
+			resourceParents = append(resourceParents, e.Parents...)
+			continue
+		}
+		parents, err := toUIDSet(e.Parents)
+		if err != nil {
+			return nil, fmt.Errorf("entity %s: %w", uid, err)
+		}
+		entities[uid] = cedar.Entity{
+			UID:        uid,
+			Parents:    parents,
+			Attributes: attrs,
+		}
+	}
+
+	resourceAttrs[cedar.String("path")] = cedar.String(input.Resource)
+	if input.ResourceType != "" {
+		resourceAttrs[cedar.String("type")] = cedar.String(input.ResourceType)
+	}
+	parents, err := toUIDSet(resourceParents)
+	if err != nil {
+		return nil, fmt.Errorf("resource: %w", err)
+	}
+	entities[resource] = cedar.Entity{
+		UID:        resource,
+		Parents:    parents,
+		Attributes: cedar.NewRecord(resourceAttrs),
+	}
+
+	return entities, nil
+}
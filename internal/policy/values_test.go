@@ -0,0 +1,179 @@
+package policy
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cedar-policy/cedar-go"
+)
+
+const documentCedar = `
+@id("confidential-owner-only")
+permit(
+    principal is Dome::Agent,
+    action == Dome::Action::"read",
+    resource
+) when {
+    resource.classification == "confidential" &&
+    resource.owner.team == "hr" &&
+    principal.capabilities.contains("hr")
+};
+
+@id("public-read")
+permit(
+    principal is Dome::Agent,
+    action == Dome::Action::"read",
+    resource in Dome::Folder::"public"
+);
+`
+
+func TestEngine_Evaluate_ResourceAttributesAndEntities(t *testing.T) {
+	e := NewEngine()
+	if err := e.LoadBundle(map[string]string{"docs.cedar": documentCedar}, "v1"); err != nil {
+		t.Fatal(err)
+	}
+
+	owner := EntityRef{Type: "Dome::User", ID: "alice"}
+	input := CheckInput{
+		Action:   "read",
+		Resource: "docs/salaries.xlsx",
+		ResourceAttributes: map[string]any{
+			"classification": "confidential",
+			"owner":          owner,
+		},
+		Entities: []EntityInput{
+			{UID: owner, Attributes: map[string]any{"team": "hr"}},
+		},
+	}
+
+	d := e.Evaluate(AgentContext{ID: "agent-1", Capabilities: []string{"hr"}}, input)
+	if !d.Allow {
+		t.Errorf("expected allow, got deny: %s", d.Reason)
+	}
+
+	d = e.Evaluate(AgentContext{ID: "agent-2"}, input)
+	if d.Allow {
+		t.Error("expected deny for agent without hr capability")
+	}
+
+	// Parents place the resource in a hierarchy.
+	d = e.Evaluate(AgentContext{ID: "agent-2"}, CheckInput{
+		Action:          "read",
+		Resource:        "docs/handbook.pdf",
+		ResourceParents: []EntityRef{{Type: "Dome::Folder", ID: "public"}},
+	})
+	if !d.Allow {
+		t.Errorf("expected allow for resource in public folder, got deny: %s", d.Reason)
+	}
+}
+
+func TestEngine_Evaluate_ContextAttributes(t *testing.T) {
+	e := NewEngine()
+	err := e.LoadBundle(map[string]string{"llm.cedar": `
+@id("small-completions")
+permit(principal, action == Dome::Action::"llm:chat", resource)
+when { context["llm.max_tokens"] <= 1000 && !context["llm.stream"] };
+`}, "v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(attrs map[string]any) *Decision {
+		return e.Evaluate(AgentContext{ID: "agent-1"}, CheckInput{
+			Action:            "llm:chat",
+			Resource:          "openai/gpt-4o",
+			Context:           map[string]string{"llm.max_tokens": "ignored"},
+			ContextAttributes: attrs,
+		})
+	}
+
+	if d := check(map[string]any{"llm.max_tokens": 500, "llm.stream": false}); !d.Allow {
+		t.Errorf("expected allow, got deny: %s", d.Reason)
+	}
+	if d := check(map[string]any{"llm.max_tokens": 4096, "llm.stream": false}); d.Allow {
+		t.Error("expected deny above max_tokens limit")
+	}
+	if d := check(map[string]any{"llm.max_tokens": struct{}{}}); d.Allow || !strings.Contains(d.Reason, "invalid request context") {
+		t.Errorf("expected fail-closed for unsupported context value, got %+v", d)
+	}
+}
+
+func TestEngine_Evaluate_InvalidEntitiesFailClosed(t *testing.T) {
+	e := NewEngine()
+	if err := e.LoadBundle(map[string]string{"base.cedar": `permit(principal, action, resource);`}, "v1"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		input CheckInput
+		want  string
+	}{
+		{
+			name:  "reserved attribute",
+			input: CheckInput{ResourceAttributes: map[string]any{"path": "x"}},
+			want:  "reserved",
+		},
+		{
+			name:  "unsupported type",
+			input: CheckInput{ResourceAttributes: map[string]any{"ch": make(chan int)}},
+			want:  "unsupported attribute type",
+		},
+		{
+			name: "principal override",
+			input: CheckInput{Entities: []EntityInput{{
+				UID:        EntityRef{Type: string(EntityTypeAgent), ID: "agent-1"},
+				Attributes: map[string]any{"capabilities": []string{"admin"}},
+			}}},
+			want: "cannot override the principal",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := e.Evaluate(AgentContext{ID: "agent-1"}, tt.input)
+			if d.Allow || !strings.Contains(d.Reason, tt.want) {
+				t.Errorf("decision = %+v, want deny containing %q", d, tt.want)
+			}
+		})
+	}
+}
+
+func TestToValue(t *testing.T) {
+	supported := []any{
+		"s", true, 1, int64(2), uint32(3), 4.0, 4.5,
+		time.Now(), time.Second,
+		EntityRef{Type: "Dome::User", ID: "alice"},
+		[]string{"a"}, []any{"a", 1}, map[string]string{"k": "v"},
+		map[string]any{"nested": map[string]any{"n": 1}},
+	}
+	for _, v := range supported {
+		if _, err := ToValue(v); err != nil {
+			t.Errorf("ToValue(%T) error: %v", v, err)
+		}
+	}
+
+	// Floats are always decimal, so an attribute does not switch between
+	// Long and decimal depending on its value.
+	for _, f := range []any{2.0, 2.5, float32(3)} {
+		v, err := ToValue(f)
+		if err != nil {
+			t.Fatalf("ToValue(%v) error: %v", f, err)
+		}
+		if _, ok := v.(cedar.Decimal); !ok {
+			t.Errorf("ToValue(%v) = %T, want cedar.Decimal", f, v)
+		}
+	}
+	if v, _ := ToValue(2); v != cedar.Long(2) {
+		t.Errorf("ToValue(2) = %#v, want Long", v)
+	}
+
+	unsupported := []any{nil, struct{}{}, uint64(1 << 63), math.Inf(1), EntityRef{ID: "no-type"}}
+	for _, v := range unsupported {
+		if _, err := ToValue(v); err == nil {
+			t.Errorf("ToValue(%#v) expected error", v)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+// Package runtimeenv detects the environment the agent process runs in:
+// a Kubernetes pod, a container or a bare process.
+package runtimeenv
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Provider names.
+const (
+	Kubernetes = "kubernetes"
+	Container  = "container"
+	Process    = "process"
+)
+
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// containerIDPattern matches a 64 hex digit container ID in a cgroup path.
+var containerIDPattern = regexp.MustCompile(`[0-9a-f]{64}`)
+
+// Env is the view of the system Detect inspects.
+type Env struct {
+	Getenv   func(string) string
+	ReadFile func(string) ([]byte, error)
+	Exists   func(string) bool
+	Hostname func() (string, error)
+	Pid      int
+	Exe      string
+}
+
+// OS returns the Env of the current process.
+func OS() Env {
+	exe, _ := os.Executable()
+	return Env{
+		Getenv:   os.Getenv,
+		ReadFile: os.ReadFile,
+		Exists: func(path string) bool {
+			_, err := os.Stat(path)
+			return err == nil
+		},
+		Hostname: os.Hostname,
+		Pid:      os.Getpid(),
+		Exe:      exe,
+	}
+}
+
+// Detect returns the provider and configuration describing env. Empty
+// values are omitted from the configuration.
+func Detect(env Env) (provider string, config map[string]string) {
+	hostname, _ := env.Hostname()
+	cgroup, _ := env.ReadFile("/proc/self/cgroup")
+	containerID := containerIDPattern.FindString(string(cgroup))
+
+	switch {
+	case env.Getenv("KUBERNETES_SERVICE_HOST") != "":
+		namespace := env.Getenv("POD_NAMESPACE")
+		if namespace == "" {
+			if b, err := env.ReadFile(serviceAccountDir + "/namespace"); err == nil {
+				namespace = strings.TrimSpace(string(b))
+			}
+		}
+		pod := env.Getenv("POD_NAME")
+		if pod == "" {
+			pod = hostname
+		}
+		return Kubernetes, compact(map[string]string{
+			"namespace":       namespace,
+			"pod":             pod,
+			"node":            env.Getenv("NODE_NAME"),
+			"service_account": env.Getenv("SERVICE_ACCOUNT"),
+			"container_id":    containerID,
+		})
+
+	case env.Exists("/.dockerenv") || env.Exists("/run/.containerenv") || containerID != "":
+		return Container, compact(map[string]string{
+			"container_id": containerID,
+			"hostname":     hostname,
+		})
+
+	default:
+		return Process, compact(map[string]string{
+			"hostname":   hostname,
+			"pid":        strconv.Itoa(env.Pid),
+			"executable": filepath.Base(env.Exe),
+		})
+	}
+}
+
+func compact(m map[string]string) map[string]string {
+	maps.DeleteFunc(m, func(_, v string) bool { return v == "" || v == "." })
+	return m
+}
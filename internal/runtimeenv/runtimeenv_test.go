@@ -0,0 +1,81 @@
+package runtimeenv
+
+import (
+	"errors"
+	"maps"
+	"testing"
+)
+
+func fakeEnv(vars, files map[string]string) Env {
+	return Env{
+		Getenv: func(k string) string { return vars[k] },
+		ReadFile: func(path string) ([]byte, error) {
+			if s, ok := files[path]; ok {
+				return []byte(s), nil
+			}
+			return nil, errors.New("not found")
+		},
+		Exists: func(path string) bool {
+			_, ok := files[path]
+			return ok
+		},
+		Hostname: func() (string, error) { return "host-1", nil },
+		Pid:      42,
+		Exe:      "/usr/local/bin/agent",
+	}
+}
+
+const cgroup = "0::/kubepods/besteffort/pod1/0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef\n"
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name         string
+		vars, files  map[string]string
+		wantProvider string
+		wantConfig   map[string]string
+	}{
+		{
+			name: "kubernetes",
+			vars: map[string]string{"KUBERNETES_SERVICE_HOST": "10.0.0.1", "NODE_NAME": "node-a"},
+			files: map[string]string{
+				"/proc/self/cgroup":              cgroup,
+				serviceAccountDir + "/namespace": "agents\n",
+			},
+			wantProvider: Kubernetes,
+			wantConfig: map[string]string{
+				"namespace":    "agents",
+				"pod":          "host-1",
+				"node":         "node-a",
+				"container_id": "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+			},
+		},
+		{
+			name:         "kubernetes downward API",
+			vars:         map[string]string{"KUBERNETES_SERVICE_HOST": "10.0.0.1", "POD_NAME": "agent-7f9", "POD_NAMESPACE": "prod"},
+			wantProvider: Kubernetes,
+			wantConfig:   map[string]string{"namespace": "prod", "pod": "agent-7f9"},
+		},
+		{
+			name:         "docker",
+			files:        map[string]string{"/.dockerenv": ""},
+			wantProvider: Container,
+			wantConfig:   map[string]string{"hostname": "host-1"},
+		},
+		{
+			name:         "process",
+			wantProvider: Process,
+			wantConfig:   map[string]string{"hostname": "host-1", "pid": "42", "executable": "agent"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider, config := Detect(fakeEnv(tt.vars, tt.files))
+			if provider != tt.wantProvider {
+				t.Errorf("provider = %q, want %q", provider, tt.wantProvider)
+			}
+			if !maps.Equal(config, tt.wantConfig) {
+				t.Errorf("config = %v, want %v", config, tt.wantConfig)
+			}
+		})
+	}
+}
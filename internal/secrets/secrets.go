@@ -0,0 +1,281 @@
+// Package secrets retrieves leased secrets for the Dome SDK, either from
+// the Dome control plane or directly from Vault. This is an internal
+// package — SDK consumers use Client.FetchCredential.
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned (wrapped) when the named secret does not exist.
+var ErrNotFound = errors.New("secret not found")
+
+// Secret is a secret value together with its lease.
+type Secret struct {
+	Data map[string]string
+	// LeaseID identifies the lease for renewal and revocation. It is empty
+	// for static secrets.
+	LeaseID string
+	// LeaseDuration is how long the secret is valid from the time it was
+	// issued or last renewed. Zero means no expiry.
+	LeaseDuration time.Duration
+	Renewable     bool
+}
+
+// Source fetches secrets and manages their leases.
+type Source interface {
+	Fetch(ctx context.Context, name string) (*Secret, error)
+	Renew(ctx context.Context, s *Secret) (*Secret, error)
+	Revoke(ctx context.Context, s *Secret) error
+}
+
+// leaseResponse is the wire format shared by the control plane credential
+// API and Vault's logical read and lease endpoints.
+type leaseResponse struct {
+	LeaseID       string         `json:"lease_id"`
+	LeaseDuration int64          `json:"lease_duration"`
+	Renewable     bool           `json:"renewable"`
+	Data          map[string]any `json:"data"`
+}
+
+func (r *leaseResponse) secret() *Secret {
+	data := r.Data
+	// KV v2 nests the secret under data.data alongside data.metadata.
+	if inner, ok := data["data"].(map[string]any); ok {
+		if _, ok := data["metadata"]; ok {
+			data = inner
+		}
+	}
+	return &Secret{
+		Data:          stringify(data),
+		LeaseID:       r.LeaseID,
+		LeaseDuration: time.Duration(r.LeaseDuration) * time.Second,
+		Renewable:     r.Renewable,
+	}
+}
+
+// stringify converts secret fields to strings; non-string values are
+// JSON-encoded.
+func stringify(data map[string]any) map[string]string {
+	out := make(map[string]string, len(data))
+	for k, v := range data {
+		if s, ok := v.(string); ok {
+			out[k] = s
+			continue
+		}
+		b, _ := json.Marshal(v)
+		out[k] = string(b)
+	}
+	return out
+}
+
+// ValidateName checks that a slash-separated secret name has no empty, "."
+// or ".." segments, which a server could resolve to a path other than the
+// name policy was checked against.
+func ValidateName(name string) error {
+	if name == "" {
+		return errors.New("secret name is required")
+	}
+	for _, s := range strings.Split(name, "/") {
+		switch s {
+		case "", ".", "..":
+			return fmt.Errorf("invalid secret name %q: empty, \".\" or \"..\" segment", name)
+		}
+	}
+	return nil
+}
+
+// escapePath validates a slash-separated secret name and escapes each
+// segment.
+func escapePath(name string) (string, error) {
+	if err := ValidateName(name); err != nil {
+		return "", err
+	}
+	segments := strings.Split(name, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return strings.Join(segments, "/"), nil
+}
+
+// do sends a JSON request and decodes a lease response. A nil out discards
+// the response body.
+func do(ctx context.Context, client *http.Client, method, url string, header http.Header, body any, out *leaseResponse) error {
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
+
+// APISource reads credentials from the Dome control plane.
+type APISource struct {
+	httpClient *http.Client
+	baseURL    string
+}
+
+// NewAPISource creates a source for the control plane at baseURL. The HTTP
+// client must carry the agent's authentication.
+func NewAPISource(httpClient *http.Client, baseURL string) *APISource {
+	return &APISource{httpClient: httpClient, baseURL: strings.TrimRight(baseURL, "/")}
+}
+
+// Fetch implements Source.
+func (s *APISource) Fetch(ctx context.Context, name string) (*Secret, error) {
+	var resp leaseResponse
+	path, err := escapePath(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := do(ctx, s.httpClient, http.MethodGet, s.baseURL+"/api/v1/credentials/"+path, nil, nil, &resp); err != nil {
+		return nil, fmt.Errorf("fetch credential %s: %w", name, err)
+	}
+	return resp.secret(), nil
+}
+
+// Renew implements Source.
+func (s *APISource) Renew(ctx context.Context, sec *Secret) (*Secret, error) {
+	var resp leaseResponse
+	body := map[string]string{"lease_id": sec.LeaseID}
+	if err := do(ctx, s.httpClient, http.MethodPost, s.baseURL+"/api/v1/credentials/leases/renew", nil, body, &resp); err != nil {
+		return nil, fmt.Errorf("renew lease: %w", err)
+	}
+	renewed := *sec
+	renewed.LeaseDuration = time.Duration(resp.LeaseDuration) * time.Second
+	renewed.Renewable = resp.Renewable
+	return &renewed, nil
+}
+
+// Revoke implements Source.
+func (s *APISource) Revoke(ctx context.Context, sec *Secret) error {
+	body := map[string]string{"lease_id": sec.LeaseID}
+	if err := do(ctx, s.httpClient, http.MethodPost, s.baseURL+"/api/v1/credentials/leases/revoke", nil, body, nil); err != nil {
+		return fmt.Errorf("revoke lease: %w", err)
+	}
+	return nil
+}
+
+// VaultSource reads secrets directly from Vault, authenticating with a
+// Vault token obtained from the SDK's Vault login.
+type VaultSource struct {
+	httpClient *http.Client
+	addr       string
+	prefix     string
+	token      func() (string, error)
+}
+
+// NewVaultSource creates a source that reads the secret <prefix>/<name>
+// from the Vault server at addr. For KV v2 the prefix includes the "data"
+// segment, e.g. "secret/data/agents". token returns a valid Vault token.
+func NewVaultSource(httpClient *http.Client, addr, prefix string, token func() (string, error)) *VaultSource {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &VaultSource{
+		httpClient: httpClient,
+		addr:       strings.TrimRight(addr, "/"),
+		prefix:     strings.Trim(prefix, "/"),
+		token:      token,
+	}
+}
+
+func (s *VaultSource) header() (http.Header, error) {
+	token, err := s.token()
+	if err != nil {
+		return nil, fmt.Errorf("vault auth: %w", err)
+	}
+	return http.Header{"X-Vault-Token": []string{token}}, nil
+}
+
+// Fetch implements Source.
+func (s *VaultSource) Fetch(ctx context.Context, name string) (*Secret, error) {
+	header, err := s.header()
+	if err != nil {
+		return nil, err
+	}
+	path, err := escapePath(name)
+	if err != nil {
+		return nil, err
+	}
+	if s.prefix != "" {
+		path = s.prefix + "/" + path
+	}
+	var resp leaseResponse
+	if err := do(ctx, s.httpClient, http.MethodGet, s.addr+"/v1/"+path, header, nil, &resp); err != nil {
+		return nil, fmt.Errorf("read vault secret %s: %w", name, err)
+	}
+	return resp.secret(), nil
+}
+
+// Renew implements Source.
+func (s *VaultSource) Renew(ctx context.Context, sec *Secret) (*Secret, error) {
+	header, err := s.header()
+	if err != nil {
+		return nil, err
+	}
+	var resp leaseResponse
+	body := map[string]string{"lease_id": sec.LeaseID}
+	if err := do(ctx, s.httpClient, http.MethodPut, s.addr+"/v1/sys/leases/renew", header, body, &resp); err != nil {
+		return nil, fmt.Errorf("renew vault lease: %w", err)
+	}
+	renewed := *sec
+	renewed.LeaseDuration = time.Duration(resp.LeaseDuration) * time.Second
+	renewed.Renewable = resp.Renewable
+	return &renewed, nil
+}
+
+// Revoke implements Source.
+func (s *VaultSource) Revoke(ctx context.Context, sec *Secret) error {
+	header, err := s.header()
+	if err != nil {
+		return err
+	}
+	body := map[string]string{"lease_id": sec.LeaseID}
+	if err := do(ctx, s.httpClient, http.MethodPut, s.addr+"/v1/sys/leases/revoke", header, body, nil); err != nil {
+		return fmt.Errorf("revoke vault lease: %w", err)
+	}
+	return nil
+}
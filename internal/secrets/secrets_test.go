@@ -0,0 +1,130 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestVaultSource_KVv2(t *testing.T) {
+	var sawToken string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sawToken = r.Header.Get("X-Vault-Token")
+		switch r.URL.Path {
+		case "/v1/secret/data/agents/github":
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"lease_duration": 0,
+				"data": map[string]any{
+					"data":     map[string]any{"token": "ghp_x", "scopes": []string{"repo"}},
+					"metadata": map[string]any{"version": 3},
+				},
+			})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	src := NewVaultSource(nil, srv.URL, "/secret/data/agents/", func() (string, error) { return "vault-token", nil })
+
+	s, err := src.Fetch(context.Background(), "github")
+	if err != nil {
+		t.Fatalf("Fetch error: %v", err)
+	}
+	if sawToken != "vault-token" {
+		t.Errorf("X-Vault-Token = %q", sawToken)
+	}
+	if s.Data["token"] != "ghp_x" || s.Data["scopes"] != `["repo"]` || len(s.Data) != 2 {
+		t.Errorf("data = %v, want unwrapped KV v2 data", s.Data)
+	}
+	if s.LeaseDuration != 0 || s.LeaseID != "" {
+		t.Errorf("lease = %q/%v, want static", s.LeaseID, s.LeaseDuration)
+	}
+
+	if _, err := src.Fetch(context.Background(), "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestVaultSource_Leases(t *testing.T) {
+	var renewed, revoked string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			LeaseID string `json:"lease_id"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		switch {
+		case r.Method == http.MethodPut && r.URL.Path == "/v1/sys/leases/renew":
+			renewed = body.LeaseID
+			_ = json.NewEncoder(w).Encode(map[string]any{"lease_id": body.LeaseID, "lease_duration": 600, "renewable": true})
+		case r.Method == http.MethodPut && r.URL.Path == "/v1/sys/leases/revoke":
+			revoked = body.LeaseID
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	src := NewVaultSource(nil, srv.URL, "database/creds", func() (string, error) { return "t", nil })
+	s := &Secret{Data: map[string]string{"username": "u"}, LeaseID: "database/creds/app/abc", LeaseDuration: time.Minute, Renewable: true}
+
+	r, err := src.Renew(context.Background(), s)
+	if err != nil {
+		t.Fatalf("Renew error: %v", err)
+	}
+	if renewed != s.LeaseID || r.LeaseDuration != 10*time.Minute || r.Data["username"] != "u" {
+		t.Errorf("renewed = %+v (lease %q)", r, renewed)
+	}
+
+	if err := src.Revoke(context.Background(), s); err != nil {
+		t.Fatalf("Revoke error: %v", err)
+	}
+	if revoked != s.LeaseID {
+		t.Errorf("revoked = %q", revoked)
+	}
+}
+
+func TestAPISource_Fetch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.EscapedPath() != "/api/v1/credentials/openai/api%20key" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"lease_id": "l1", "lease_duration": 300, "renewable": true,
+			"data": map[string]any{"value": "sk-test"},
+		})
+	}))
+	defer srv.Close()
+
+	s, err := NewAPISource(srv.Client(), srv.URL+"/").Fetch(context.Background(), "openai/api key")
+	if err != nil {
+		t.Fatalf("Fetch error: %v", err)
+	}
+	if s.Data["value"] != "sk-test" || s.LeaseID != "l1" || s.LeaseDuration != 5*time.Minute || !s.Renewable {
+		t.Errorf("secret = %+v", s)
+	}
+}
+
+func TestEscapePath_RejectsTraversal(t *testing.T) {
+	if got, err := escapePath("openai/api key"); err != nil || got != "openai/api%20key" {
+		t.Errorf("escapePath = %q, %v", got, err)
+	}
+	for _, name := range []string{"", "a/../../sys/mounts", "..", "./a", "a//b", "/a", "a/"} {
+		if _, err := escapePath(name); err == nil {
+			t.Errorf("escapePath(%q) expected error", name)
+		}
+	}
+
+	var called bool
+	srv := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { called = true }))
+	defer srv.Close()
+	if _, err := NewAPISource(srv.Client(), srv.URL).Fetch(context.Background(), "billing/../payroll/db"); err == nil || called {
+		t.Errorf("Fetch error = %v, request sent = %v", err, called)
+	}
+}
@@ -0,0 +1,106 @@
+package tokenexchange
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Token exchange (RFC 8693) parameters for audience-scoped tokens.
+const (
+	grantTypeTokenExchange = "urn:ietf:params:oauth:grant-type:token-exchange"
+	tokenTypeJWT           = "urn:ietf:params:oauth:token-type:jwt"
+)
+
+// maxAudienceTokens caps the audiences AudienceTokens caches tokens for.
+const maxAudienceTokens = 1024
+
+// AudienceTokens exchanges the agent's own token for tokens scoped to a
+// single audience, such as the agent being called, and caches them per
+// audience, up to maxAudienceTokens of them. A token scoped this way is useless to its receiver anywhere
+// else: other agents and the control plane reject it.
+type AudienceTokens struct {
+	base     http.RoundTripper
+	endpoint string
+	subject  func() (string, error)
+
+	mu      sync.Mutex
+	tokens  map[string]cachedToken
+	nowFunc func() time.Time // for testing
+}
+
+type cachedToken struct {
+	token  string
+	expiry time.Time
+}
+
+// NewAudienceTokens creates an exchanger that trades the tokens returned by
+// subject at the Dome API server at apiURL.
+func NewAudienceTokens(base http.RoundTripper, apiURL string, subject func() (string, error)) *AudienceTokens {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &AudienceTokens{
+		base:     base,
+		endpoint: strings.TrimRight(apiURL, "/") + "/api/v1/auth/token",
+		subject:  subject,
+		tokens:   make(map[string]cachedToken),
+		nowFunc:  time.Now,
+	}
+}
+
+// Token returns a token for audience, exchanging the agent's token if the
+// cached one is missing or about to expire.
+func (a *AudienceTokens) Token(audience string) (string, error) {
+	if audience == "" {
+		return "", errors.New("token audience is required")
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := a.nowFunc()
+	if c, ok := a.tokens[audience]; ok && now.Add(30*time.Second).Before(c.expiry) {
+		return c.token, nil
+	}
+
+	subject, err := a.subject()
+	if err != nil {
+		return "", err
+	}
+	token, expiresIn, err := requestToken(a.base, a.endpoint, map[string]string{
+		"grant_type":         grantTypeTokenExchange,
+		"subject_token":      subject,
+		"subject_token_type": tokenTypeJWT,
+		"audience":           audience,
+	})
+	if err != nil {
+		return "", err
+	}
+	a.evict(now)
+	a.tokens[audience] = cachedToken{token: token, expiry: now.Add(time.Duration(expiresIn) * time.Second)}
+	return token, nil
+}
+
+// evict makes room for a new token: it drops expired tokens and, if the
+// cache is still full, the one expiring first. The caller holds a.mu.
+func (a *AudienceTokens) evict(now time.Time) {
+	var (
+		first  string
+		expiry time.Time
+	)
+	for audience, c := range a.tokens {
+		if !now.Before(c.expiry) {
+			delete(a.tokens, audience)
+			continue
+		}
+		if first == "" || c.expiry.Before(expiry) {
+			first, expiry = audience, c.expiry
+		}
+	}
+	if len(a.tokens) >= maxAudienceTokens {
+		delete(a.tokens, first)
+	}
+}
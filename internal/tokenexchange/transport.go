@@ -85,6 +85,12 @@ func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
 	return t.base.RoundTrip(clone)
 }
 
+// Token returns the agent's identity JWT, exchanging credentials if the
+// cached token is missing or about to expire.
+func (t *Transport) Token() (string, error) {
+	return t.getToken()
+}
+
 // getToken returns a cached JWT or exchanges credentials for a new one.
 func (t *Transport) getToken() (string, error) {
 	t.mu.Lock()
@@ -116,19 +122,25 @@ func (t *Transport) getToken() (string, error) {
 
 // exchange calls the Dome API token exchange endpoint.
 func (t *Transport) exchange() (token string, expiresIn int, err error) {
-	body, _ := json.Marshal(map[string]string{
+	return requestToken(t.base, t.tokenEndpoint(), map[string]string{
 		"grant_type": "approle",
 		"role_id":    t.config.RoleID,
 		"secret_id":  t.config.SecretID,
 	})
+}
+
+// requestToken posts a token request to endpoint and returns the issued
+// token and its lifetime in seconds.
+func requestToken(base http.RoundTripper, endpoint string, params map[string]string) (token string, expiresIn int, err error) {
+	body, _ := json.Marshal(params)
 
-	req, err := http.NewRequest(http.MethodPost, t.tokenEndpoint(), bytes.NewReader(body))
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
 	if err != nil {
 		return "", 0, err
 	}
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := t.base.RoundTrip(req)
+	resp, err := base.RoundTrip(req)
 	if err != nil {
 		return "", 0, fmt.Errorf("token exchange request: %w", err)
 	}
@@ -2,6 +2,7 @@ package tokenexchange
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"sync/atomic"
@@ -245,3 +246,85 @@ func TestTransport_ExchangeError(t *testing.T) {
 		t.Fatal("expected error on exchange failure")
 	}
 }
+
+func TestAudienceTokens(t *testing.T) {
+	var exchangeCalls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&exchangeCalls, 1)
+		var req map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if req["grant_type"] != grantTypeTokenExchange || req["subject_token"] != "agent-jwt" || req["subject_token_type"] != tokenTypeJWT {
+			t.Errorf("unexpected exchange request: %v", req)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"access_token": "jwt-for-" + req["audience"],
+			"expires_in":   3600,
+		})
+	}))
+	defer server.Close()
+
+	tokens := NewAudienceTokens(nil, server.URL+"/", func() (string, error) { return "agent-jwt", nil })
+	for _, aud := range []string{"agent-b", "agent-c", "agent-b"} {
+		token, err := tokens.Token(aud)
+		if err != nil {
+			t.Fatalf("Token(%q) error: %v", aud, err)
+		}
+		if token != "jwt-for-"+aud {
+			t.Errorf("Token(%q) = %q", aud, token)
+		}
+	}
+	if got := atomic.LoadInt32(&exchangeCalls); got != 2 {
+		t.Errorf("exchange calls = %d, want 2 (one per audience)", got)
+	}
+
+	if _, err := tokens.Token(""); err == nil {
+		t.Error("expected error for an empty audience")
+	}
+}
+
+func TestAudienceTokens_Eviction(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"access_token": "jwt-for-" + req["audience"],
+			"expires_in":   60,
+		})
+	}))
+	defer server.Close()
+
+	now := time.Now()
+	tokens := NewAudienceTokens(nil, server.URL, func() (string, error) { return "agent-jwt", nil })
+	tokens.nowFunc = func() time.Time { return now }
+
+	for _, aud := range []string{"agent-a", "agent-b"} {
+		if _, err := tokens.Token(aud); err != nil {
+			t.Fatalf("Token(%q) error: %v", aud, err)
+		}
+	}
+
+	// Expired tokens are dropped when another is added.
+	now = now.Add(time.Minute)
+	if _, err := tokens.Token("agent-c"); err != nil {
+		t.Fatalf("Token error: %v", err)
+	}
+	if n := len(tokens.tokens); n != 1 {
+		t.Errorf("cached tokens = %d, want 1", n)
+	}
+
+	// A full cache drops the token expiring first.
+	for i := range maxAudienceTokens {
+		now = now.Add(time.Millisecond)
+		if _, err := tokens.Token(fmt.Sprintf("agent-%d", i)); err != nil {
+			t.Fatalf("Token error: %v", err)
+		}
+	}
+	if n := len(tokens.tokens); n != maxAudienceTokens {
+		t.Errorf("cached tokens = %d, want %d", n, maxAudienceTokens)
+	}
+	if _, ok := tokens.tokens["agent-c"]; ok {
+		t.Error("token expiring first was not evicted")
+	}
+}
@@ -95,6 +95,12 @@ func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
 	return t.base.RoundTrip(clone)
 }
 
+// IdentityToken returns the agent's OIDC identity token, fetching a new
+// one if the cached token is missing or about to expire.
+func (t *Transport) IdentityToken() (string, error) {
+	return t.getOIDCToken()
+}
+
 // getOIDCToken returns a cached OIDC token or fetches a new one.
 func (t *Transport) getOIDCToken() (string, error) {
 	t.Mu.Lock()
@@ -127,6 +133,21 @@ func (t *Transport) getOIDCToken() (string, error) {
 	return token, nil
 }
 
+// Addr returns the Vault server address.
+func (t *Transport) Addr() string { return t.config.VaultAddr }
+
+// VaultToken returns a valid Vault native token, logging in if the cached
+// token is absent or about to expire. It is used to read secrets directly
+// from Vault.
+func (t *Transport) VaultToken() (string, error) {
+	t.Mu.Lock()
+	defer t.Mu.Unlock()
+	if err := t.ensureVaultToken(); err != nil {
+		return "", err
+	}
+	return t.vaultToken, nil
+}
+
 // ensureVaultToken authenticates to Vault if the native token is expired or absent.
 func (t *Transport) ensureVaultToken() error {
 	if t.vaultToken != "" && time.Now().Add(60*time.Second).Before(t.vaultExp) {
@@ -0,0 +1,440 @@
+package dome
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Dome-Systems/sdk-dome-go/internal/policy"
+)
+
+// maxLLMUsageBody bounds how much of a non-streaming response is buffered
+// to extract token usage. Larger responses are passed through unrecorded.
+const maxLLMUsageBody = 16 << 20
+
+// maxLLMRequestBody bounds the request body read for policy evaluation.
+// Larger requests are denied.
+const maxLLMRequestBody = 32 << 20
+
+// ErrLLMDenied is matched by errors.Is for every LLM call blocked by
+// policy. Use errors.As with *LLMDeniedError for the decision.
+var ErrLLMDenied = errors.New("dome: llm call denied by policy")
+
+// LLMDeniedError is returned by the LLMTransport round tripper when policy
+// denies a model call.
+type LLMDeniedError struct {
+	Provider string
+	Model    string
+	Endpoint string
+	Decision *Decision
+}
+
+func (e *LLMDeniedError) Error() string {
+	return fmt.Sprintf("dome: llm call denied: %s/%s: %s", e.Provider, e.Model, e.Decision.Reason)
+}
+
+// Unwrap returns ErrLLMDenied.
+func (e *LLMDeniedError) Unwrap() error { return ErrLLMDenied }
+
+// LLMUsage records the token usage reported by one model call.
+type LLMUsage struct {
+	Time     time.Time
+	Provider string
+	// Model is the model named in the response, falling back to the
+	// requested model.
+	Model string
+	// Endpoint is "chat.completions", "embeddings" or "responses".
+	Endpoint     string
+	InputTokens  int64
+	OutputTokens int64
+	TotalTokens  int64
+}
+
+// LLMOptions configures Client.LLMTransport.
+type LLMOptions struct {
+	// Provider names the provider in the resource "<provider>/<model>".
+	// Defaults to a name derived from the request host, e.g. "openai" for
+	// api.openai.com, or the host itself for unknown endpoints.
+	Provider string
+
+	// OnUsage, if set, is called with the token usage of every completed
+	// call, after the response body has been read.
+	OnUsage func(LLMUsage)
+}
+
+// knownLLMProviders maps API hosts to provider names.
+var knownLLMProviders = map[string]string{
+	"api.openai.com":                    "openai",
+	"api.anthropic.com":                 "anthropic",
+	"generativelanguage.googleapis.com": "google",
+	"api.mistral.ai":                    "mistral",
+	"api.groq.com":                      "groq",
+	"api.together.xyz":                  "together",
+	"api.deepseek.com":                  "deepseek",
+	"openrouter.ai":                     "openrouter",
+}
+
+// llmProvider derives a provider name from an API host.
+func llmProvider(host string) string {
+	hostname := host
+	if h, _, ok := strings.Cut(host, ":"); ok {
+		hostname = h
+	}
+	if p, ok := knownLLMProviders[hostname]; ok {
+		return p
+	}
+	if strings.HasSuffix(hostname, ".openai.azure.com") {
+		return "azure"
+	}
+	return host
+}
+
+// llmEndpoint returns the endpoint name for an OpenAI-compatible API path,
+// or "" if the path is not a governed endpoint. Any path prefix is
+// accepted, so proxies that mount the API under "/openai/v1" work.
+func llmEndpoint(path string) string {
+	path = strings.TrimSuffix(path, "/")
+	switch {
+	case strings.HasSuffix(path, "/chat/completions"):
+		return "chat.completions"
+	case strings.HasSuffix(path, "/embeddings"):
+		return "embeddings"
+	case strings.HasSuffix(path, "/responses"):
+		return "responses"
+	}
+	return ""
+}
+
+// llmRequest is the subset of an OpenAI-compatible request body used for
+// policy evaluation.
+type llmRequest struct {
+	Model               string
+	MaxTokens           *int64
+	MaxCompletionTokens *int64
+	MaxOutputTokens     *int64
+	Tools               []json.RawMessage
+	Stream              bool
+}
+
+// decodeLLMRequest decodes a request body with decodeStrictObject, so the
+// model checked is the model the provider reads. The model is required.
+func decodeLLMRequest(body []byte) (llmRequest, error) {
+	var req llmRequest
+	obj, err := decodeStrictObject(body,
+		"model", "max_tokens", "max_completion_tokens", "max_output_tokens", "tools", "stream")
+	if err != nil {
+		return req, err
+	}
+	if err := json.Unmarshal(obj["model"], &req.Model); err != nil || req.Model == "" {
+		return req, errors.New("missing model")
+	}
+	fields := []struct {
+		key string
+		dst any
+	}{
+		{"max_tokens", &req.MaxTokens},
+		{"max_completion_tokens", &req.MaxCompletionTokens},
+		{"max_output_tokens", &req.MaxOutputTokens},
+		{"tools", &req.Tools},
+		{"stream", &req.Stream},
+	}
+	for _, f := range fields {
+		if raw, ok := obj[f.key]; ok {
+			if err := json.Unmarshal(raw, f.dst); err != nil {
+				return req, fmt.Errorf("%s: %w", f.key, err)
+			}
+		}
+	}
+	return req, nil
+}
+
+// maxTokens returns the requested output token limit, if any.
+func (r llmRequest) maxTokens() (int64, bool) {
+	for _, v := range []*int64{r.MaxCompletionTokens, r.MaxTokens, r.MaxOutputTokens} {
+		if v != nil {
+			return *v, true
+		}
+	}
+	return 0, false
+}
+
+// LLMTransport returns an http.RoundTripper for OpenAI-compatible APIs that
+// checks each call to /chat/completions, /embeddings and /responses
+// against policy before sending it (base is http.DefaultTransport if nil).
+// Pass it as the HTTP client of any OpenAI-compatible SDK:
+//
+//	httpClient := &http.Client{Transport: client.LLMTransport(nil, dome.LLMOptions{})}
+//
+// Calls are checked as action "llm:chat" on the resource "<provider>/<model>"
+// (a Dome::LLMModel). The context carries "llm.provider", "llm.model" and
+// "llm.endpoint" strings, plus the typed values "llm.max_tokens" (when
+// requested), "llm.tool_count" and "llm.stream". Denied calls are not sent;
+// RoundTrip returns an *LLMDeniedError.
+//
+// Token usage is read from the response, including streamed responses that
+// report usage in their final event, and is logged, reported to the control
+// plane as an "llm.usage" event and passed to opts.OnUsage.
+//
+// Other paths pass through unchecked. Requests whose body cannot be decoded
+// unambiguously (not a JSON object, no model, duplicate keys or keys that
+// differ from those above only in case) or is larger than 32 MiB are
+// denied. If the check itself fails, the call is sent (fail-open for
+// v0.4.0).
+func (c *Client) LLMTransport(base http.RoundTripper, opts LLMOptions) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &llmTransport{client: c, base: base, opts: opts}
+}
+
+type llmTransport struct {
+	client *Client
+	base   http.RoundTripper
+	opts   LLMOptions
+}
+
+func (t *llmTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	endpoint := llmEndpoint(r.URL.Path)
+	if endpoint == "" || r.Method != http.MethodPost || r.Body == nil {
+		return t.base.RoundTrip(r)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxLLMRequestBody+1))
+	_ = r.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	out := r.Clone(r.Context())
+	out.Body = io.NopCloser(bytes.NewReader(body))
+	out.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(body)), nil }
+
+	provider := t.opts.Provider
+	if provider == "" {
+		provider = llmProvider(r.URL.Host)
+	}
+
+	if len(body) > maxLLMRequestBody {
+		err = fmt.Errorf("request body exceeds %d bytes", maxLLMRequestBody)
+	}
+	var req llmRequest
+	if err == nil {
+		req, err = decodeLLMRequest(body)
+	}
+	if err != nil {
+		// The request cannot be checked, so it is not sent.
+		t.client.logger.Warn("dome: llm call rejected", "provider", provider, "endpoint", endpoint, "error", err)
+		return nil, &LLMDeniedError{
+			Provider: provider,
+			Model:    req.Model,
+			Endpoint: endpoint,
+			Decision: &Decision{Reason: "invalid request body: " + err.Error()},
+		}
+	}
+
+	decision, err := t.client.Check(r.Context(), llmCheckRequest(provider, endpoint, req))
+	switch {
+	case err != nil:
+		t.client.logger.Error("dome: policy check error", "provider", provider, "model", req.Model, "error", err)
+		// Fail-open on error.
+	case !decision.Allowed:
+		t.client.logger.Warn("dome: llm call denied",
+			"provider", provider,
+			"model", req.Model,
+			"reason", decision.Reason,
+		)
+		return nil, &LLMDeniedError{Provider: provider, Model: req.Model, Endpoint: endpoint, Decision: decision}
+	}
+
+	resp, err := t.base.RoundTrip(out)
+	if err != nil || resp.StatusCode >= 300 {
+		return resp, err
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	resp.Body = &usageReader{
+		ReadCloser: resp.Body,
+		stream:     mediaType == "text/event-stream",
+		usage: LLMUsage{
+			Provider: provider,
+			Model:    req.Model,
+			Endpoint: endpoint,
+		},
+		record: t.recordUsage,
+	}
+	return resp, nil
+}
+
+func llmCheckRequest(provider, endpoint string, req llmRequest) CheckRequest {
+	attrs := map[string]any{
+		"llm.tool_count": len(req.Tools),
+		"llm.stream":     req.Stream,
+	}
+	if n, ok := req.maxTokens(); ok {
+		attrs["llm.max_tokens"] = n
+	}
+	return CheckRequest{
+		Action:       policy.ActionLLMChat,
+		Resource:     provider + "/" + req.Model,
+		ResourceType: "llm",
+		Context: map[string]string{
+			"llm.provider": provider,
+			"llm.model":    req.Model,
+			"llm.endpoint": endpoint,
+		},
+		ContextAttributes: attrs,
+	}
+}
+
+// recordUsage logs a completed call's usage, reports it to the control
+// plane and invokes the OnUsage hook.
+func (t *llmTransport) recordUsage(u LLMUsage) {
+	t.client.logger.Debug("dome: llm usage",
+		"provider", u.Provider,
+		"model", u.Model,
+		"endpoint", u.Endpoint,
+		"input_tokens", u.InputTokens,
+		"output_tokens", u.OutputTokens,
+	)
+	go t.client.reportEventData(context.Background(), t.client.AgentID(), "llm.usage", map[string]any{
+		"provider":      u.Provider,
+		"model":         u.Model,
+		"endpoint":      u.Endpoint,
+		"input_tokens":  u.InputTokens,
+		"output_tokens": u.OutputTokens,
+		"total_tokens":  u.TotalTokens,
+	})
+	if t.opts.OnUsage != nil {
+		t.opts.OnUsage(u)
+	}
+}
+
+// usageReader observes a response body as the caller reads it and records
+// token usage once the body is exhausted or closed.
+type usageReader struct {
+	io.ReadCloser
+	stream bool
+	usage  LLMUsage
+	record func(LLMUsage)
+
+	buf      bytes.Buffer // whole body (non-streaming) or partial line (streaming)
+	found    bool
+	overflow bool
+	once     sync.Once
+}
+
+func (u *usageReader) Read(p []byte) (int, error) {
+	n, err := u.ReadCloser.Read(p)
+	if n > 0 {
+		u.observe(p[:n])
+	}
+	if err == io.EOF {
+		u.finish()
+	}
+	return n, err
+}
+
+func (u *usageReader) Close() error {
+	u.finish()
+	return u.ReadCloser.Close()
+}
+
+func (u *usageReader) observe(p []byte) {
+	if !u.stream {
+		if u.overflow || u.buf.Len()+len(p) > maxLLMUsageBody {
+			u.overflow = true
+			u.buf.Reset()
+			return
+		}
+		u.buf.Write(p)
+		return
+	}
+
+	u.buf.Write(p)
+	for {
+		line, err := u.buf.ReadBytes('\n')
+		if err != nil {
+			// Keep the incomplete line for the next read.
+			rest := append([]byte(nil), line...)
+			u.buf.Reset()
+			u.buf.Write(rest)
+			return
+		}
+		if data, ok := bytes.CutPrefix(bytes.TrimSpace(line), []byte("data:")); ok {
+			u.parse(bytes.TrimSpace(data))
+		}
+	}
+}
+
+// parse extracts usage from a response object or stream event. Chat and
+// embeddings responses carry a top-level "usage"; Responses API objects
+// and their "response.completed" events carry it under "response".
+func (u *usageReader) parse(data []byte) {
+	var body struct {
+		Model    string         `json:"model"`
+		Usage    *llmUsageJSON  `json:"usage"`
+		Response *llmUsageModel `json:"response"`
+	}
+	if json.Unmarshal(data, &body) != nil {
+		return
+	}
+	usage, model := body.Usage, body.Model
+	if usage == nil && body.Response != nil {
+		usage, model = body.Response.Usage, body.Response.Model
+	}
+	if usage == nil {
+		return
+	}
+	if model != "" {
+		u.usage.Model = model
+	}
+	u.usage.InputTokens = usage.PromptTokens + usage.InputTokens
+	u.usage.OutputTokens = usage.CompletionTokens + usage.OutputTokens
+	u.usage.TotalTokens = usage.TotalTokens
+	if u.usage.TotalTokens == 0 {
+		u.usage.TotalTokens = u.usage.InputTokens + u.usage.OutputTokens
+	}
+	u.found = true
+}
+
+type llmUsageJSON struct {
+	PromptTokens     int64 `json:"prompt_tokens"`
+	CompletionTokens int64 `json:"completion_tokens"`
+	InputTokens      int64 `json:"input_tokens"`
+	OutputTokens     int64 `json:"output_tokens"`
+	TotalTokens      int64 `json:"total_tokens"`
+}
+
+type llmUsageModel struct {
+	Model string        `json:"model"`
+	Usage *llmUsageJSON `json:"usage"`
+}
+
+func (u *usageReader) finish() {
+	u.once.Do(func() {
+		if !u.stream && !u.overflow {
+			u.parse(u.buf.Bytes())
+		} else if u.stream && u.buf.Len() > 0 {
+			// A final event without a trailing newline.
+			sc := bufio.NewScanner(&u.buf)
+			for sc.Scan() {
+				if data, ok := bytes.CutPrefix(bytes.TrimSpace(sc.Bytes()), []byte("data:")); ok {
+					u.parse(bytes.TrimSpace(data))
+				}
+			}
+		}
+		u.buf.Reset()
+		if u.found {
+			u.usage.Time = time.Now()
+			u.record(u.usage)
+		}
+	})
+}
@@ -0,0 +1,129 @@
+package dome_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	dome "github.com/Dome-Systems/sdk-dome-go"
+	"github.com/Dome-Systems/sdk-dome-go/internal/policy"
+)
+
+const llmCedar = `
+@id("mini-models")
+permit(
+    principal is Dome::Agent,
+    action == Dome::Action::"llm:chat",
+    resource == Dome::LLMModel::"openai/gpt-4o-mini"
+) when {
+    context["llm.tool_count"] <= 1 &&
+    (!(context has "llm.max_tokens") || context["llm.max_tokens"] <= 1000)
+};
+`
+
+// openAIStub serves canned OpenAI-compatible responses and counts the
+// completions it receives.
+func openAIStub(t *testing.T, received *int) string {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/chat/completions", func(w http.ResponseWriter, r *http.Request) {
+		*received++
+		body, _ := io.ReadAll(r.Body)
+		if strings.Contains(string(body), `"stream":true`) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = io.WriteString(w, "data: {\"model\":\"gpt-4o-mini-2024-07-18\",\"choices\":[{\"delta\":{\"content\":\"hi\"}}]}\n\n")
+			_, _ = io.WriteString(w, "data: {\"model\":\"gpt-4o-mini-2024-07-18\",\"choices\":[],\"usage\":{\"prompt_tokens\":7,\"completion_tokens\":3,\"total_tokens\":10}}\n\n")
+			_, _ = io.WriteString(w, "data: [DONE]\n\n")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"model":"gpt-4o-mini-2024-07-18","choices":[],"usage":{"prompt_tokens":12,"completion_tokens":5,"total_tokens":17}}`)
+	})
+	mux.HandleFunc("GET /v1/models", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = io.WriteString(w, `{"data":[]}`)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func TestLLMTransport(t *testing.T) {
+	serverURL := testServerWithBundle(t, policy.BundleResponse{
+		Version:  "v1",
+		Policies: []policy.PolicyFile{{Filename: "llm.cedar", Content: llmCedar}},
+	})
+	client := startedClient(t, serverURL)
+
+	var received int
+	stubURL := openAIStub(t, &received)
+
+	var usage []dome.LLMUsage
+	httpClient := &http.Client{Transport: client.LLMTransport(nil, dome.LLMOptions{
+		Provider: "openai",
+		OnUsage:  func(u dome.LLMUsage) { usage = append(usage, u) },
+	})}
+	post := func(body string) (*http.Response, error) {
+		resp, err := httpClient.Post(stubURL+"/v1/chat/completions", "application/json", strings.NewReader(body))
+		if err == nil {
+			_, _ = io.ReadAll(resp.Body)
+			_ = resp.Body.Close()
+		}
+		return resp, err
+	}
+
+	if _, err := post(`{"model":"gpt-4o-mini","max_tokens":500,"messages":[]}`); err != nil {
+		t.Fatalf("permitted call error: %v", err)
+	}
+	if _, err := post(`{"model":"gpt-4o-mini","stream":true,"stream_options":{"include_usage":true},"messages":[]}`); err != nil {
+		t.Fatalf("permitted streaming call error: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"unlisted model", `{"model":"gpt-4o","messages":[]}`},
+		{"max_tokens too high", `{"model":"gpt-4o-mini","max_completion_tokens":4096,"messages":[]}`},
+		{"too many tools", `{"model":"gpt-4o-mini","tools":[{},{}],"messages":[]}`},
+		{"case variant model", `{"model":"gpt-4o-mini","MODEL":"gpt-4o","messages":[]}`},
+		{"duplicate model", `{"model":"gpt-4o-mini","model":"gpt-4o","messages":[]}`},
+		{"no model", `{"messages":[]}`},
+		{"malformed body", `{"model":"gpt-4o-mini",`},
+		{"oversized body", `{"model":"gpt-4o-mini","messages":[],"pad":"` + strings.Repeat("x", 32<<20) + `"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := post(tt.body)
+			var denied *dome.LLMDeniedError
+			if !errors.As(err, &denied) || !errors.Is(err, dome.ErrLLMDenied) {
+				t.Fatalf("error = %v, want *LLMDeniedError", err)
+			}
+			if denied.Provider != "openai" || denied.Endpoint != "chat.completions" {
+				t.Errorf("denied = %+v", denied)
+			}
+		})
+	}
+	if received != 2 {
+		t.Errorf("stub received %d completions, want 2", received)
+	}
+
+	if len(usage) != 2 {
+		t.Fatalf("recorded %d usage entries, want 2", len(usage))
+	}
+	if u := usage[0]; u.InputTokens != 12 || u.OutputTokens != 5 || u.TotalTokens != 17 || u.Model != "gpt-4o-mini-2024-07-18" {
+		t.Errorf("usage = %+v", u)
+	}
+	if u := usage[1]; u.InputTokens != 7 || u.OutputTokens != 3 || u.TotalTokens != 10 {
+		t.Errorf("streamed usage = %+v", u)
+	}
+
+	// Ungoverned endpoints pass through.
+	resp, err := httpClient.Get(stubURL + "/v1/models")
+	if err != nil {
+		t.Fatalf("GET /v1/models error: %v", err)
+	}
+	_ = resp.Body.Close()
+}
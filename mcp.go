@@ -0,0 +1,746 @@
+package dome
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Dome-Systems/sdk-dome-go/internal/policy"
+)
+
+// MCPDeniedErrorCode is the JSON-RPC error code returned for tool calls
+// denied by policy. The error's data carries the stable Dome error code.
+const MCPDeniedErrorCode = -32001
+
+// Standard JSON-RPC error codes returned for messages the guard cannot
+// decode unambiguously.
+const (
+	jsonrpcInvalidRequest = -32600
+	jsonrpcInvalidParams  = -32602
+)
+
+// MCPOptions configures an MCPGuard.
+type MCPOptions struct {
+	// HideReasons omits the policy decision reason from JSON-RPC errors
+	// returned for denied tool calls. The reason is still logged.
+	HideReasons bool
+}
+
+// MCPGuard enforces policy on Model Context Protocol traffic for one MCP
+// server. Every tools/call request is checked as action "mcp:call" on the
+// resource "<server>/<tool>" (a Dome::MCPTool), with the tool arguments in
+// the context as "arguments.<name>" (nested objects are flattened with
+// dots; arrays are JSON-encoded). Denied calls are answered with a JSON-RPC
+// error instead of reaching the server, and tools/list results are
+// filtered down to the tools the agent may call. A tool is listed when a
+// call with no arguments would be permitted, so argument constraints are
+// best written as forbid policies guarded by "has".
+//
+// A guard wraps either side of a connection: Stdio and Transport for an
+// agent acting as an MCP client, Stdio and Handler for an MCP server.
+// Check errors fail open, matching Middleware. Messages the guard cannot
+// decode unambiguously, such as those with duplicate keys or keys that
+// differ from "method", "params" or "name" only in case, are not forwarded.
+type MCPGuard struct {
+	client *Client
+	server string
+	opts   MCPOptions
+}
+
+// MCPGuard returns a guard for the MCP server named server.
+func (c *Client) MCPGuard(server string, opts MCPOptions) *MCPGuard {
+	return &MCPGuard{client: c, server: server, opts: opts}
+}
+
+// CheckToolCall evaluates the policy decision for calling tool with args.
+func (g *MCPGuard) CheckToolCall(ctx context.Context, tool string, args map[string]any) (*Decision, error) {
+	checkCtx := map[string]string{
+		"mcp.server": g.server,
+		"mcp.tool":   tool,
+	}
+	flattenArguments(checkCtx, "arguments", args)
+
+	return g.client.Check(ctx, CheckRequest{
+		Action:       policy.ActionMCPCall,
+		Resource:     g.server + "/" + tool,
+		ResourceType: "mcp",
+		Context:      checkCtx,
+	})
+}
+
+// flattenArguments adds each argument to ctx under prefix.
+func flattenArguments(ctx map[string]string, prefix string, args map[string]any) {
+	for k, v := range args {
+		key := prefix + "." + k
+		switch v := v.(type) {
+		case map[string]any:
+			flattenArguments(ctx, key, v)
+		case string:
+			ctx[key] = v
+		case bool:
+			ctx[key] = strconv.FormatBool(v)
+		case float64:
+			ctx[key] = strconv.FormatFloat(v, 'f', -1, 64)
+		case nil:
+			ctx[key] = ""
+		default:
+			b, _ := json.Marshal(v)
+			ctx[key] = string(b)
+		}
+	}
+}
+
+// jsonrpcMessage is the subset of a JSON-RPC 2.0 message the guard reads.
+type jsonrpcMessage struct {
+	ID     json.RawMessage
+	Method string
+	Params json.RawMessage
+	Result json.RawMessage
+}
+
+// decodeJSONRPC decodes a JSON-RPC message with decodeStrictObject.
+func decodeJSONRPC(raw []byte) (jsonrpcMessage, error) {
+	obj, err := decodeStrictObject(raw, "id", "method", "params", "result")
+	if err != nil {
+		return jsonrpcMessage{}, err
+	}
+	msg := jsonrpcMessage{ID: obj["id"], Params: obj["params"], Result: obj["result"]}
+	if method, ok := obj["method"]; ok {
+		if err := json.Unmarshal(method, &msg.Method); err != nil {
+			return jsonrpcMessage{}, fmt.Errorf("method: %w", err)
+		}
+	}
+	return msg, nil
+}
+
+// toolCallParams are the params of a tools/call request.
+type toolCallParams struct {
+	Name      string
+	Arguments map[string]any
+}
+
+// decodeToolCall decodes tools/call params with decodeStrictObject. The
+// tool name is required.
+func decodeToolCall(raw json.RawMessage) (toolCallParams, error) {
+	var params toolCallParams
+	obj, err := decodeStrictObject(raw, "name", "arguments")
+	if err != nil {
+		return params, err
+	}
+	if err := json.Unmarshal(obj["name"], &params.Name); err != nil || params.Name == "" {
+		return params, errors.New("missing tool name")
+	}
+	if args, ok := obj["arguments"]; ok {
+		if err := json.Unmarshal(args, &params.Arguments); err != nil {
+			return params, fmt.Errorf("arguments: %w", err)
+		}
+	}
+	return params, nil
+}
+
+// decodeStrictObject decodes a JSON object into its members, matching keys
+// exactly. Unlike json.Unmarshal into a struct, which matches keys case
+// insensitively and lets a later duplicate win, it rejects objects with
+// duplicate keys or with a key that differs from one of guarded only in
+// case, so the guard never reads a different value than a strict peer.
+func decodeStrictObject(data []byte, guarded ...string) (map[string]json.RawMessage, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if tok, err := dec.Token(); err != nil {
+		return nil, err
+	} else if tok != json.Delim('{') {
+		return nil, errors.New("not a JSON object")
+	}
+	obj := make(map[string]json.RawMessage)
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		key := tok.(string)
+		if _, dup := obj[key]; dup {
+			return nil, fmt.Errorf("duplicate key %q", key)
+		}
+		for _, g := range guarded {
+			if key != g && strings.EqualFold(key, g) {
+				return nil, fmt.Errorf("key %q differs from %q only in case", key, g)
+			}
+		}
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return nil, err
+		}
+		obj[key] = value
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("trailing data after JSON object")
+	}
+	return obj, nil
+}
+
+// mcpSession tracks the outstanding tools/list requests on one connection
+// so their responses can be filtered.
+type mcpSession struct {
+	mu    sync.Mutex
+	lists map[string]bool
+}
+
+func (s *mcpSession) trackList(id json.RawMessage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.lists == nil {
+		s.lists = make(map[string]bool)
+	}
+	s.lists[string(id)] = true
+}
+
+func (s *mcpSession) takeList(id json.RawMessage) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.lists[string(id)] {
+		return false
+	}
+	delete(s.lists, string(id))
+	return true
+}
+
+// mcpResult is the outcome of inspecting one transport payload.
+type mcpResult struct {
+	// forward is the payload to deliver onward, or nil if nothing remains.
+	forward []byte
+	// reply holds error responses for denied calls, to send back to the
+	// sender, or nil.
+	reply []byte
+	// lists is true when the payload contained a tools/list request.
+	lists bool
+}
+
+// process inspects a single JSON-RPC message or batch travelling in either
+// direction. Payloads that cannot be decoded unambiguously are not
+// forwarded; the sender gets a JSON-RPC invalid request error.
+func (g *MCPGuard) process(ctx context.Context, s *mcpSession, data []byte) mcpResult {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 {
+		return mcpResult{forward: data}
+	}
+	if trimmed[0] != '[' {
+		forward, reply, lists := g.processOne(ctx, s, trimmed)
+		return mcpResult{forward: forward, reply: reply, lists: lists}
+	}
+
+	var batch []json.RawMessage
+	if err := json.Unmarshal(trimmed, &batch); err != nil {
+		return mcpResult{reply: g.rejectMessage(err)}
+	}
+	var res mcpResult
+	var forwards, replies []json.RawMessage
+	for _, raw := range batch {
+		forward, reply, lists := g.processOne(ctx, s, raw)
+		if forward != nil {
+			forwards = append(forwards, forward)
+		}
+		if reply != nil {
+			replies = append(replies, reply)
+		}
+		res.lists = res.lists || lists
+	}
+	if len(forwards) > 0 {
+		res.forward, _ = json.Marshal(forwards)
+	}
+	if len(replies) > 0 {
+		res.reply, _ = json.Marshal(replies)
+	}
+	return res
+}
+
+func (g *MCPGuard) processOne(ctx context.Context, s *mcpSession, raw []byte) (forward, reply []byte, lists bool) {
+	msg, err := decodeJSONRPC(raw)
+	if err != nil {
+		return nil, g.rejectMessage(err), false
+	}
+
+	switch {
+	case msg.Method == "tools/list" && msg.ID != nil:
+		s.trackList(msg.ID)
+		return raw, nil, true
+
+	case msg.Method == "tools/call":
+		params, err := decodeToolCall(msg.Params)
+		if err != nil {
+			g.client.logger.Warn("dome: mcp tool call rejected", "server", g.server, "error", err)
+			if msg.ID == nil {
+				return nil, nil, false
+			}
+			return nil, jsonrpcError(msg.ID, jsonrpcInvalidParams, "invalid params", nil), false
+		}
+
+		decision, err := g.CheckToolCall(ctx, params.Name, params.Arguments)
+		if err != nil {
+			g.client.logger.Error("dome: policy check error", "tool", params.Name, "error", err)
+			// Fail-open on error.
+			return raw, nil, false
+		}
+		if decision.Allowed {
+			return raw, nil, false
+		}
+		g.client.logger.Warn("dome: mcp tool call denied",
+			"server", g.server,
+			"tool", params.Name,
+			"reason", decision.Reason,
+		)
+		if msg.ID == nil {
+			// A notification cannot be answered; drop it.
+			return nil, nil, false
+		}
+		return nil, g.denial(msg.ID, decision), false
+
+	case msg.Method == "" && msg.ID != nil && msg.Result != nil && s.takeList(msg.ID):
+		return g.filterToolList(ctx, raw, msg.Result), nil, false
+	}
+	return raw, nil, false
+}
+
+// rejectMessage logs a message that cannot be decoded unambiguously and
+// returns the invalid request error answering it.
+func (g *MCPGuard) rejectMessage(err error) []byte {
+	g.client.logger.Warn("dome: mcp message rejected", "server", g.server, "error", err)
+	return jsonrpcError(nil, jsonrpcInvalidRequest, "invalid request", nil)
+}
+
+// denial builds the JSON-RPC error response for a denied tool call.
+func (g *MCPGuard) denial(id json.RawMessage, d *Decision) []byte {
+	message := d.Reason
+	if g.opts.HideReasons {
+		message = "denied by policy"
+		if d.QuotaExceeded {
+			message = "quota exceeded"
+		}
+	}
+	data := map[string]string{"code": DenialCode(d)}
+	if d.PolicyVersion != "" {
+		data["policy_version"] = d.PolicyVersion
+	}
+	return jsonrpcError(id, MCPDeniedErrorCode, message, data)
+}
+
+// jsonrpcError builds a JSON-RPC error response. A nil id is sent as null.
+func jsonrpcError(id json.RawMessage, code int, message string, data map[string]string) []byte {
+	e := map[string]any{
+		"code":    code,
+		"message": message,
+	}
+	if data != nil {
+		e["data"] = data
+	}
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	b, _ := json.Marshal(map[string]any{
+		"jsonrpc": "2.0",
+		"id":      id,
+		"error":   e,
+	})
+	return b
+}
+
+// filterToolList removes tools the agent may not call from a tools/list
+// response. Other result fields are preserved.
+func (g *MCPGuard) filterToolList(ctx context.Context, raw []byte, result json.RawMessage) []byte {
+	var fields map[string]json.RawMessage
+	var tools []json.RawMessage
+	if json.Unmarshal(result, &fields) != nil || json.Unmarshal(fields["tools"], &tools) != nil {
+		return raw
+	}
+
+	permitted := make([]json.RawMessage, 0, len(tools))
+	for _, tool := range tools {
+		var name string
+		obj, err := decodeStrictObject(tool, "name")
+		if err != nil || json.Unmarshal(obj["name"], &name) != nil {
+			// A tool the guard cannot name unambiguously is not listed.
+			continue
+		}
+		decision, err := g.CheckToolCall(ctx, name, nil)
+		if err != nil || decision.Allowed {
+			permitted = append(permitted, tool)
+		}
+	}
+	if len(permitted) == len(tools) {
+		return raw
+	}
+
+	var msg map[string]json.RawMessage
+	if json.Unmarshal(raw, &msg) != nil {
+		return raw
+	}
+	fields["tools"], _ = json.Marshal(permitted)
+	msg["result"], _ = json.Marshal(fields)
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return raw
+	}
+	return b
+}
+
+// rewriteResponse filters the JSON-RPC messages in an HTTP response body,
+// which is either application/json or a text/event-stream, and appends
+// reply (error responses for denied calls in the same request).
+func (g *MCPGuard) rewriteResponse(ctx context.Context, s *mcpSession, contentType string, body, reply []byte) (string, []byte) {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType == "text/event-stream" {
+		var out bytes.Buffer
+		var ev sseEvent
+		for _, line := range strings.SplitAfter(string(body), "\n") {
+			field := strings.TrimRight(line, "\r\n")
+			if field == "" {
+				// A blank line ends the event.
+				if g.rewriteEvent(ctx, s, &out, ev) {
+					out.WriteString(line)
+				}
+				ev = sseEvent{}
+				continue
+			}
+			if data, ok := strings.CutPrefix(field, "data:"); ok {
+				ev.data = append(ev.data, strings.TrimPrefix(data, " "))
+				ev.hasData = true
+				continue
+			}
+			ev.fields = append(ev.fields, line)
+		}
+		g.rewriteEvent(ctx, s, &out, ev)
+		if reply != nil {
+			out.WriteString("event: message\ndata: ")
+			out.Write(reply)
+			out.WriteString("\n\n")
+		}
+		return contentType, out.Bytes()
+	}
+
+	forward := g.process(ctx, s, body).forward
+	if len(bytes.TrimSpace(forward)) == 0 {
+		return "application/json", reply
+	}
+	if reply == nil {
+		return contentType, forward
+	}
+	return "application/json", mergeBatches(forward, reply)
+}
+
+// sseEvent is one server-sent event being rewritten.
+type sseEvent struct {
+	fields  []string // lines other than data, with their line endings
+	data    []string // data field values, in order
+	hasData bool
+}
+
+// rewriteEvent writes ev to out with its data, the lines of a multi-line
+// data field joined with "\n", filtered as a single JSON-RPC payload. An
+// event whose payload is dropped entirely is omitted, and rewriteEvent
+// reports false.
+func (g *MCPGuard) rewriteEvent(ctx context.Context, s *mcpSession, out *bytes.Buffer, ev sseEvent) bool {
+	if !ev.hasData {
+		for _, line := range ev.fields {
+			out.WriteString(line)
+		}
+		return true
+	}
+	forward := g.process(ctx, s, []byte(strings.Join(ev.data, "\n"))).forward
+	if len(bytes.TrimSpace(forward)) == 0 {
+		return false
+	}
+	for _, line := range ev.fields {
+		out.WriteString(line)
+	}
+	for _, line := range strings.Split(string(forward), "\n") {
+		out.WriteString("data: ")
+		out.WriteString(strings.TrimRight(line, "\r"))
+		out.WriteString("\n")
+	}
+	return true
+}
+
+// mergeBatches combines two JSON-RPC payloads into one batch.
+func mergeBatches(a, b []byte) []byte {
+	var msgs []json.RawMessage
+	for _, p := range [][]byte{a, b} {
+		p = bytes.TrimSpace(p)
+		var batch []json.RawMessage
+		if len(p) > 0 && p[0] == '[' && json.Unmarshal(p, &batch) == nil {
+			msgs = append(msgs, batch...)
+		} else if len(p) > 0 {
+			msgs = append(msgs, p)
+		}
+	}
+	out, _ := json.Marshal(msgs)
+	return out
+}
+
+// Handler wraps a streamable HTTP MCP server endpoint. Denied tool calls
+// are answered directly; tools/list responses are filtered.
+func (g *MCPGuard) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			next.ServeHTTP(w, r)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "read request body", http.StatusBadRequest)
+			return
+		}
+
+		s := &mcpSession{}
+		res := g.process(r.Context(), s, body)
+		if res.forward == nil {
+			writeMCPReply(w, res.reply)
+			return
+		}
+
+		r.Body = io.NopCloser(bytes.NewReader(res.forward))
+		r.ContentLength = int64(len(res.forward))
+		if !res.lists && res.reply == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		rec := &bufferedResponse{header: make(http.Header), status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		for k, v := range rec.header {
+			w.Header()[k] = v
+		}
+		status := rec.status
+		contentType, out := g.rewriteResponse(r.Context(), s, rec.header.Get("Content-Type"), rec.body.Bytes(), res.reply)
+		if status == http.StatusAccepted && res.reply != nil {
+			status = http.StatusOK
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Length", strconv.Itoa(len(out)))
+		w.WriteHeader(status)
+		_, _ = w.Write(out)
+	})
+}
+
+// writeMCPReply answers a request whose every message was denied.
+func writeMCPReply(w http.ResponseWriter, reply []byte) {
+	if reply == nil {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(reply)
+}
+
+// bufferedResponse captures a handler's response for rewriting.
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (b *bufferedResponse) Header() http.Header         { return b.header }
+func (b *bufferedResponse) Write(p []byte) (int, error) { return b.body.Write(p) }
+func (b *bufferedResponse) WriteHeader(status int)      { b.status = status }
+
+// Transport wraps the http.RoundTripper an MCP client uses to reach a
+// streamable HTTP server (http.DefaultTransport if base is nil). Denied
+// tool calls are answered locally without contacting the server;
+// tools/list responses are filtered.
+func (g *MCPGuard) Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		if r.Method != http.MethodPost || r.Body == nil {
+			return base.RoundTrip(r)
+		}
+		body, err := io.ReadAll(r.Body)
+		_ = r.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		s := &mcpSession{}
+		res := g.process(r.Context(), s, body)
+		if res.forward == nil {
+			return localMCPResponse(r, res.reply), nil
+		}
+
+		out := r.Clone(r.Context())
+		out.Body = io.NopCloser(bytes.NewReader(res.forward))
+		out.ContentLength = int64(len(res.forward))
+		out.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(res.forward)), nil }
+		resp, err := base.RoundTrip(out)
+		if err != nil || (!res.lists && res.reply == nil) {
+			return resp, err
+		}
+
+		respBody, err := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		contentType, rewritten := g.rewriteResponse(r.Context(), s, resp.Header.Get("Content-Type"), respBody, res.reply)
+		if resp.StatusCode == http.StatusAccepted && res.reply != nil {
+			resp.StatusCode = http.StatusOK
+			resp.Status = http.StatusText(http.StatusOK)
+		}
+		resp.Header.Set("Content-Type", contentType)
+		resp.Header.Del("Content-Length")
+		resp.ContentLength = int64(len(rewritten))
+		resp.Body = io.NopCloser(bytes.NewReader(rewritten))
+		return resp, nil
+	})
+}
+
+// localMCPResponse synthesizes the server response for a request whose
+// every message was denied.
+func localMCPResponse(r *http.Request, reply []byte) *http.Response {
+	resp := &http.Response{
+		StatusCode: http.StatusAccepted,
+		Status:     "202 Accepted",
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     make(http.Header),
+		Body:       http.NoBody,
+		Request:    r,
+	}
+	if reply != nil {
+		resp.StatusCode = http.StatusOK
+		resp.Status = "200 OK"
+		resp.Header.Set("Content-Type", "application/json")
+		resp.Body = io.NopCloser(bytes.NewReader(reply))
+		resp.ContentLength = int64(len(reply))
+	}
+	return resp
+}
+
+// Stdio wraps a newline-delimited JSON-RPC stream, as used by the MCP stdio
+// transport. For an MCP client, r is the server's stdout and w its stdin;
+// for an MCP server, r is os.Stdin and w is os.Stdout. The guard inspects
+// messages in both directions, so the same wrapper serves either role.
+// Close closes r and w if they implement io.Closer.
+func (g *MCPGuard) Stdio(r io.Reader, w io.Writer) io.ReadWriteCloser {
+	s := &mcpStdio{guard: g, w: w, r: r}
+	s.cond = sync.NewCond(&s.readMu)
+	go s.pump(bufio.NewReader(r))
+	return s
+}
+
+type mcpStdio struct {
+	guard   *MCPGuard
+	session mcpSession
+	r       io.Reader
+
+	writeMu sync.Mutex
+	w       io.Writer
+	partial []byte // incomplete line written by the caller
+
+	readMu  sync.Mutex
+	cond    *sync.Cond
+	readBuf bytes.Buffer // processed lines ready for Read
+	readErr error
+}
+
+// pump reads lines from the underlying reader, processes them and queues
+// the result for Read. Replies to denied requests read here are written
+// back to the underlying writer.
+func (s *mcpStdio) pump(br *bufio.Reader) {
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 {
+			res := s.guard.process(context.Background(), &s.session, line)
+			if res.reply != nil {
+				s.writeMu.Lock()
+				_, _ = s.w.Write(withNewline(res.reply))
+				s.writeMu.Unlock()
+			}
+			if res.forward != nil {
+				s.enqueue(withNewline(res.forward))
+			}
+		}
+		if err != nil {
+			s.readMu.Lock()
+			s.readErr = err
+			s.cond.Broadcast()
+			s.readMu.Unlock()
+			return
+		}
+	}
+}
+
+func (s *mcpStdio) enqueue(b []byte) {
+	s.readMu.Lock()
+	defer s.readMu.Unlock()
+	s.readBuf.Write(b)
+	s.cond.Broadcast()
+}
+
+func (s *mcpStdio) Read(p []byte) (int, error) {
+	s.readMu.Lock()
+	defer s.readMu.Unlock()
+	for s.readBuf.Len() == 0 && s.readErr == nil {
+		s.cond.Wait()
+	}
+	if s.readBuf.Len() > 0 {
+		return s.readBuf.Read(p)
+	}
+	return 0, s.readErr
+}
+
+func (s *mcpStdio) Write(p []byte) (int, error) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	s.partial = append(s.partial, p...)
+	for {
+		i := bytes.IndexByte(s.partial, '\n')
+		if i < 0 {
+			break
+		}
+		line := s.partial[:i+1]
+		res := s.guard.process(context.Background(), &s.session, line)
+		if res.forward != nil {
+			if _, err := s.w.Write(withNewline(res.forward)); err != nil {
+				return 0, err
+			}
+		}
+		if res.reply != nil {
+			s.enqueue(withNewline(res.reply))
+		}
+		s.partial = s.partial[i+1:]
+	}
+	return len(p), nil
+}
+
+// withNewline returns a copy of b terminated by a newline.
+func withNewline(b []byte) []byte {
+	out := make([]byte, len(b)+1)
+	copy(out, b)
+	out[len(b)] = '\n'
+	return out
+}
+
+func (s *mcpStdio) Close() error {
+	var firstErr error
+	for _, v := range []any{s.w, s.r} {
+		if c, ok := v.(io.Closer); ok {
+			if err := c.Close(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,307 @@
+package dome_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	dome "github.com/Dome-Systems/sdk-dome-go"
+	"github.com/Dome-Systems/sdk-dome-go/internal/policy"
+)
+
+const mcpCedar = `
+@id("files-read")
+permit(
+    principal is Dome::Agent,
+    action == Dome::Action::"mcp:call",
+    resource
+) when {
+    [Dome::MCPTool::"files/read_file", Dome::MCPTool::"files/list_dir"].contains(resource)
+};
+
+@id("no-etc")
+forbid(
+    principal,
+    action == Dome::Action::"mcp:call",
+    resource
+) when {
+    context has "arguments.path" && context["arguments.path"] like "/etc/*"
+};
+`
+
+const toolList = `{"jsonrpc":"2.0","id":1,"result":{"tools":[{"name":"read_file"},{"name":"list_dir"},{"name":"delete_file"}],"nextCursor":"c2"}}`
+
+func mcpClient(t *testing.T) *dome.Client {
+	t.Helper()
+	serverURL := testServerWithBundle(t, policy.BundleResponse{
+		Version:  "v3",
+		Policies: []policy.PolicyFile{{Filename: "mcp.cedar", Content: mcpCedar}},
+	})
+	return startedClient(t, serverURL)
+}
+
+// mcpServer is a minimal streamable HTTP MCP server that records the calls
+// it receives.
+func mcpServer(calls *[]string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+			Params struct {
+				Name string `json:"name"`
+			} `json:"params"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&msg)
+		w.Header().Set("Content-Type", "application/json")
+		switch msg.Method {
+		case "tools/list":
+			_, _ = io.WriteString(w, toolList)
+		case "tools/call":
+			*calls = append(*calls, msg.Params.Name)
+			_, _ = io.WriteString(w, `{"jsonrpc":"2.0","id":`+string(msg.ID)+`,"result":{"content":[]}}`)
+		}
+	})
+}
+
+type rpcError struct {
+	Error *struct {
+		Code    int               `json:"code"`
+		Message string            `json:"message"`
+		Data    map[string]string `json:"data"`
+	} `json:"error"`
+	Result *struct {
+		Tools []struct {
+			Name string `json:"name"`
+		} `json:"tools"`
+		NextCursor string `json:"nextCursor"`
+	} `json:"result"`
+}
+
+func toolCall(id int, name, path string) string {
+	b, _ := json.Marshal(map[string]any{
+		"jsonrpc": "2.0", "id": id, "method": "tools/call",
+		"params": map[string]any{"name": name, "arguments": map[string]any{"path": path}},
+	})
+	return string(b)
+}
+
+func toolNames(t *testing.T, resp rpcError) []string {
+	t.Helper()
+	if resp.Result == nil {
+		t.Fatalf("tools/list returned no result")
+	}
+	var names []string
+	for _, tool := range resp.Result.Tools {
+		names = append(names, tool.Name)
+	}
+	return names
+}
+
+func TestMCPGuard_Handler(t *testing.T) {
+	client := mcpClient(t)
+	var calls []string
+	server := httptest.NewServer(client.MCPGuard("files", dome.MCPOptions{}).Handler(mcpServer(&calls)))
+	t.Cleanup(server.Close)
+
+	post := func(body string) rpcError {
+		t.Helper()
+		resp, err := http.Post(server.URL, "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var out rpcError
+		if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+		return out
+	}
+
+	if out := post(toolCall(1, "read_file", "/tmp/a.txt")); out.Error != nil {
+		t.Errorf("permitted call error: %+v", out.Error)
+	}
+
+	out := post(toolCall(2, "read_file", "/etc/passwd"))
+	if out.Error == nil || out.Error.Code != dome.MCPDeniedErrorCode || out.Error.Data["code"] != dome.ErrorCodePolicyDenied {
+		t.Errorf("denied call response = %+v", out.Error)
+	}
+	if post(toolCall(3, "delete_file", "/tmp/a.txt")).Error == nil {
+		t.Error("expected delete_file to be denied")
+	}
+	if len(calls) != 1 || calls[0] != "read_file" {
+		t.Errorf("server received calls %v, want [read_file]", calls)
+	}
+
+	list := post(`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`)
+	if names := toolNames(t, list); strings.Join(names, ",") != "read_file,list_dir" {
+		t.Errorf("listed tools = %v", names)
+	}
+	if list.Result.NextCursor != "c2" {
+		t.Errorf("nextCursor = %q, want preserved", list.Result.NextCursor)
+	}
+}
+
+func TestMCPGuard_AmbiguousMessages(t *testing.T) {
+	client := mcpClient(t)
+	var calls []string
+	server := httptest.NewServer(client.MCPGuard("files", dome.MCPOptions{}).Handler(mcpServer(&calls)))
+	t.Cleanup(server.Close)
+
+	// Each message could be read as a permitted call by the guard and as
+	// delete_file by a server that decodes keys differently, or is not
+	// decodable at all. None may reach the server.
+	for _, body := range []string{
+		`{"jsonrpc":"2.0","id":1,"method":"ping","METHOD":"tools/call","params":{"name":"delete_file"}}`,
+		`{"jsonrpc":"2.0","id":1,"method":"tools/call","method":"ping","params":{"name":"delete_file"}}`,
+		`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"read_file","NAME":"delete_file"}}`,
+		`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"read_file"},"Params":{"name":"delete_file"}}`,
+		`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"read_file","name":"delete_file"}}`,
+		`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":"delete_file"}`,
+		`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"arguments":{}}}`,
+		`{"jsonrpc":"2.0","id":1,"method":"tools/call"`,
+	} {
+		resp, err := http.Post(server.URL, "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out rpcError
+		_ = json.NewDecoder(resp.Body).Decode(&out)
+		_ = resp.Body.Close()
+		if out.Error == nil {
+			t.Errorf("%s: expected an error response", body)
+		}
+	}
+	if len(calls) != 0 {
+		t.Errorf("ambiguous calls reached the server: %v", calls)
+	}
+}
+
+func TestMCPGuard_Transport(t *testing.T) {
+	client := mcpClient(t)
+	var calls []string
+	server := httptest.NewServer(mcpServer(&calls))
+	t.Cleanup(server.Close)
+
+	httpClient := &http.Client{Transport: client.MCPGuard("files", dome.MCPOptions{HideReasons: true}).Transport(nil)}
+	post := func(body string) rpcError {
+		t.Helper()
+		resp, err := httpClient.Post(server.URL, "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var out rpcError
+		_ = json.NewDecoder(resp.Body).Decode(&out)
+		return out
+	}
+
+	out := post(toolCall(1, "delete_file", "/tmp/a.txt"))
+	if out.Error == nil || out.Error.Message != "denied by policy" {
+		t.Errorf("denied call response = %+v", out.Error)
+	}
+	if len(calls) != 0 {
+		t.Errorf("denied call reached the server: %v", calls)
+	}
+
+	list := post(`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`)
+	if names := toolNames(t, list); len(names) != 2 {
+		t.Errorf("listed tools = %v", names)
+	}
+}
+
+func TestMCPGuard_Stdio(t *testing.T) {
+	client := mcpClient(t)
+
+	// The MCP server's stdin and stdout.
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+	go func() {
+		scanner := bufio.NewScanner(serverIn)
+		for scanner.Scan() {
+			var msg struct {
+				Method string `json:"method"`
+			}
+			_ = json.Unmarshal(scanner.Bytes(), &msg)
+			if msg.Method == "tools/list" {
+				_, _ = io.WriteString(serverOut, toolList+"\n")
+			}
+		}
+	}()
+
+	conn := client.MCPGuard("files", dome.MCPOptions{}).Stdio(clientIn, clientOut)
+	t.Cleanup(func() { _ = conn.Close() })
+	responses := bufio.NewScanner(conn)
+
+	// A denied call is answered locally on the read side.
+	if _, err := io.WriteString(conn, toolCall(7, "delete_file", "/tmp/x")+"\n"); err != nil {
+		t.Fatal(err)
+	}
+	if !responses.Scan() {
+		t.Fatal("no response to denied call")
+	}
+	var out rpcError
+	_ = json.Unmarshal(responses.Bytes(), &out)
+	if out.Error == nil || out.Error.Code != dome.MCPDeniedErrorCode {
+		t.Errorf("denied call response = %s", responses.Bytes())
+	}
+
+	if _, err := io.WriteString(conn, `{"jsonrpc":"2.0","id":1,"method":"tools/list"}`+"\n"); err != nil {
+		t.Fatal(err)
+	}
+	if !responses.Scan() {
+		t.Fatal("no tools/list response")
+	}
+	out = rpcError{}
+	_ = json.Unmarshal(responses.Bytes(), &out)
+	if names := toolNames(t, out); strings.Join(names, ",") != "read_file,list_dir" {
+		t.Errorf("listed tools = %v", names)
+	}
+}
+
+func TestMCPGuard_MultiLineSSEEvents(t *testing.T) {
+	client := mcpClient(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		// The tools/list result is split across data lines, as servers
+		// emitting pretty-printed JSON do, and followed by an event that is
+		// not valid JSON-RPC.
+		_, _ = io.WriteString(w, "event: message\nid: 1\n"+
+			"data: {\"jsonrpc\":\"2.0\",\"id\":1,\n"+
+			"data: \"result\":{\"tools\":[{\"name\":\"read_file\"},\n"+
+			"data: {\"name\":\"delete_file\"}]}}\n\n"+
+			"event: message\ndata: {\"jsonrpc\":\ndata: \n\n")
+	}))
+	t.Cleanup(server.Close)
+
+	httpClient := &http.Client{Transport: client.MCPGuard("files", dome.MCPOptions{}).Transport(nil)}
+	resp, err := httpClient.Post(server.URL, "application/json", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	events := strings.Split(strings.TrimRight(string(body), "\n"), "\n\n")
+	if len(events) != 1 {
+		t.Fatalf("events = %q, want only the tools/list result", events)
+	}
+	var data []string
+	for _, line := range strings.Split(events[0], "\n") {
+		if d, ok := strings.CutPrefix(line, "data: "); ok {
+			data = append(data, d)
+		} else if line != "event: message" && line != "id: 1" {
+			t.Errorf("unexpected line %q", line)
+		}
+	}
+	var list rpcError
+	if err := json.Unmarshal([]byte(strings.Join(data, "\n")), &list); err != nil {
+		t.Fatalf("decode event data %q: %v", data, err)
+	}
+	if names := toolNames(t, list); len(names) != 1 || names[0] != "read_file" {
+		t.Errorf("listed tools = %v, want [read_file]", names)
+	}
+}
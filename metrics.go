@@ -0,0 +1,199 @@
+package dome
+
+import (
+	"maps"
+	"math"
+	"runtime/metrics"
+	"sync"
+)
+
+// Go runtime metrics reported with WithRuntimeMetrics, keyed by heartbeat
+// metric name.
+var (
+	runtimeGauges = map[string]string{
+		"go.goroutines":         "/sched/goroutines:goroutines",
+		"go.heap.objects_bytes": "/memory/classes/heap/objects:bytes",
+		"go.memory.total_bytes": "/memory/classes/total:bytes",
+	}
+	runtimeCounters = map[string]string{
+		"go.gc.cycles": "/gc/cycles/total:gc-cycles",
+	}
+	// runtimeGCPauses is summed into the go.gc.pause_seconds counter.
+	runtimeGCPauses = "/sched/pauses/total/gc:seconds"
+)
+
+// metricSet holds the metric providers sampled on every heartbeat.
+type metricSet struct {
+	mu       sync.Mutex
+	gauges   map[string]func() float64
+	counters map[string]func() float64
+	// last holds each counter's value as of the last delivered heartbeat.
+	last map[string]float64
+}
+
+// RegisterGauge registers fn as the provider of the named metric. fn is
+// called on every heartbeat of the client's own agent and its value is sent
+// as is, e.g. a queue depth. Registering a name again replaces its
+// provider.
+func (c *Client) RegisterGauge(name string, fn func() float64) {
+	c.metrics.mu.Lock()
+	defer c.metrics.mu.Unlock()
+	if c.metrics.gauges == nil {
+		c.metrics.gauges = make(map[string]func() float64)
+	}
+	delete(c.metrics.counters, name)
+	c.metrics.gauges[name] = fn
+}
+
+// RegisterCounter registers fn as the provider of the named counter. fn
+// returns a running total, e.g. tasks completed since startup; every
+// heartbeat of the client's own agent sends the increase since the last
+// heartbeat that reached the control plane. A total lower than the
+// previous one is treated as a reset. Registering a name again replaces
+// its provider.
+//
+//	var completed atomic.Int64
+//	client.RegisterCounter("tasks.completed", func() float64 {
+//		return float64(completed.Load())
+//	})
+func (c *Client) RegisterCounter(name string, fn func() float64) {
+	c.metrics.mu.Lock()
+	defer c.metrics.mu.Unlock()
+	if c.metrics.counters == nil {
+		c.metrics.counters = make(map[string]func() float64)
+	}
+	delete(c.metrics.gauges, name)
+	c.metrics.counters[name] = fn
+}
+
+// heartbeatMetrics samples the quota counters and, for the client's own
+// agent (self), the registered metric providers. Counter deltas are
+// relative to the last committed sample; call commit once the heartbeat
+// has been delivered.
+func (c *Client) heartbeatMetrics(self bool) (out map[string]float64, commit func()) {
+	out = c.quotaMetrics()
+	if !self {
+		return out, func() {}
+	}
+
+	gauges, totals := c.metrics.sample()
+	if c.config.runtimeMetrics {
+		rg, rc := readRuntimeMetrics()
+		maps.Copy(gauges, rg)
+		maps.Copy(totals, rc)
+	}
+	if len(gauges) == 0 && len(totals) == 0 {
+		return out, func() {}
+	}
+	if out == nil {
+		out = make(map[string]float64, len(gauges)+len(totals))
+	}
+	maps.Copy(out, gauges)
+
+	c.metrics.mu.Lock()
+	for name, total := range totals {
+		delta := total - c.metrics.last[name]
+		if delta < 0 {
+			delta = total
+		}
+		out[name] = delta
+	}
+	c.metrics.mu.Unlock()
+
+	return out, func() {
+		c.metrics.mu.Lock()
+		defer c.metrics.mu.Unlock()
+		if c.metrics.last == nil {
+			c.metrics.last = make(map[string]float64, len(totals))
+		}
+		maps.Copy(c.metrics.last, totals)
+	}
+}
+
+// sample calls the registered providers and returns the gauge values and
+// counter totals.
+func (s *metricSet) sample() (gauges, totals map[string]float64) {
+	s.mu.Lock()
+	gaugeFns := maps.Clone(s.gauges)
+	counterFns := maps.Clone(s.counters)
+	s.mu.Unlock()
+
+	// Call the providers without holding the lock.
+	gauges = make(map[string]float64, len(gaugeFns))
+	for name, fn := range gaugeFns {
+		gauges[name] = fn()
+	}
+	totals = make(map[string]float64, len(counterFns))
+	for name, fn := range counterFns {
+		totals[name] = fn()
+	}
+	return gauges, totals
+}
+
+// readRuntimeMetrics reads the Go runtime metrics reported by
+// WithRuntimeMetrics. The GC pause total is estimated from its histogram.
+func readRuntimeMetrics() (gauges, totals map[string]float64) {
+	samples := make([]metrics.Sample, 0, len(runtimeGauges)+len(runtimeCounters)+1)
+	names := make([]string, 0, cap(samples))
+	for name, key := range runtimeGauges {
+		samples = append(samples, metrics.Sample{Name: key})
+		names = append(names, name)
+	}
+	for name, key := range runtimeCounters {
+		samples = append(samples, metrics.Sample{Name: key})
+		names = append(names, name)
+	}
+	samples = append(samples, metrics.Sample{Name: runtimeGCPauses})
+	metrics.Read(samples)
+
+	gauges = make(map[string]float64, len(runtimeGauges))
+	totals = make(map[string]float64, len(runtimeCounters)+1)
+	for i, s := range samples[:len(names)] {
+		v, ok := sampleValue(s.Value)
+		if !ok {
+			continue
+		}
+		if _, gauge := runtimeGauges[names[i]]; gauge {
+			gauges[names[i]] = v
+		} else {
+			totals[names[i]] = v
+		}
+	}
+	if h := samples[len(names)].Value; h.Kind() == metrics.KindFloat64Histogram {
+		totals["go.gc.pause_seconds"] = histogramSum(h.Float64Histogram())
+	}
+	return gauges, totals
+}
+
+func sampleValue(v metrics.Value) (float64, bool) {
+	switch v.Kind() {
+	case metrics.KindUint64:
+		return float64(v.Uint64()), true
+	case metrics.KindFloat64:
+		return v.Float64(), true
+	}
+	return 0, false
+}
+
+// histogramSum estimates the sum of the values in h from the midpoints of
+// its buckets, using the finite edge for unbounded buckets.
+func histogramSum(h *metrics.Float64Histogram) float64 {
+	var sum float64
+	for i, n := range h.Counts {
+		if n == 0 {
+			continue
+		}
+		lo, hi := h.Buckets[i], h.Buckets[i+1]
+		var mid float64
+		switch {
+		case math.IsInf(lo, -1):
+			mid = hi
+		case math.IsInf(hi, 1):
+			mid = lo
+		default:
+			mid = (lo + hi) / 2
+		}
+		sum += float64(n) * mid
+	}
+	return sum
+}
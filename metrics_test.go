@@ -0,0 +1,53 @@
+package dome_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	dome "github.com/Dome-Systems/sdk-dome-go"
+)
+
+func TestHeartbeatMetrics(t *testing.T) {
+	handler, url := registryServer(t)
+	client, err := dome.NewClient(
+		dome.WithAPIKey("test-key"),
+		dome.WithAPIURL(url),
+		dome.WithHeartbeatInterval(20*time.Millisecond),
+		dome.WithRuntimeMetrics(),
+	)
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	var completed atomic.Int64
+	completed.Store(3)
+	client.RegisterGauge("queue.depth", func() float64 { return 7 })
+	client.RegisterCounter("tasks.completed", func() float64 { return float64(completed.Load()) })
+
+	info, err := client.Start(context.Background(), dome.StartOptions{Name: "busy-agent"})
+	if err != nil {
+		t.Fatalf("Start error: %v", err)
+	}
+	waitFor(t, func() bool { return handler.heartbeatCount(info.ID) >= 2 })
+	completed.Add(2)
+	n := handler.heartbeatCount(info.ID)
+	waitFor(t, func() bool { return handler.heartbeatCount(info.ID) >= n+2 })
+
+	// Counters are sent as deltas, so they add up to the running total.
+	var sum float64
+	for _, m := range handler.heartbeatMetrics(info.ID) {
+		if m["queue.depth"] != 7 {
+			t.Fatalf("queue.depth = %v, want 7", m["queue.depth"])
+		}
+		if m["go.goroutines"] <= 0 {
+			t.Fatalf("go.goroutines = %v, want positive", m["go.goroutines"])
+		}
+		sum += m["tasks.completed"]
+	}
+	if sum != 5 {
+		t.Errorf("sum of tasks.completed deltas = %v, want 5", sum)
+	}
+}
@@ -1,24 +1,151 @@
 package dome
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"maps"
 	"net/http"
 	"strings"
 )
 
+// Stable error codes reported in problem responses. Clients may switch on
+// these values; they do not change between SDK versions.
+const (
+	ErrorCodePolicyDenied     = "policy_denied"
+	ErrorCodeQuotaExceeded    = "quota_exceeded"
+	ErrorCodeApprovalRequired = "approval_required"
+	ErrorCodeUnauthenticated  = "unauthenticated"
+	ErrorCodeUnavailable      = "unavailable"
+)
+
+// RequestIDHeader is the header used to correlate a denial with server
+// logs. An incoming value is echoed back; otherwise one is generated.
+const RequestIDHeader = "X-Request-Id"
+
+// DenyHandler writes the response for a request denied by policy.
+type DenyHandler func(w http.ResponseWriter, r *http.Request, decision *Decision)
+
+// RequestMapper maps an incoming HTTP request to the policy check it
+// requires.
+type RequestMapper func(r *http.Request) CheckRequest
+
+// DefaultSkipPaths lists common health and metrics endpoints. Pass it as
+// MiddlewareOptions.SkipPaths to serve probes without policy checks.
+var DefaultSkipPaths = []string{"/healthz", "/livez", "/readyz", "/health", "/metrics"}
+
+// MiddlewareOptions configures Client.MiddlewareWithOptions.
+type MiddlewareOptions struct {
+	// Mapper derives the CheckRequest for each request. Defaults to
+	// MethodPathMapper. See RouteMapper for ServeMux route patterns.
+	Mapper RequestMapper
+
+	// Headers lists request headers copied into the check context as
+	// "header.<name>" (name lower-cased). Absent headers are omitted.
+	Headers []string
+
+	// QueryParams lists query parameters copied into the check context as
+	// "query.<name>". Absent parameters are omitted.
+	QueryParams []string
+
+	// SkipPaths lists URL paths served without a policy check. A trailing
+	// "*" matches by prefix (e.g. "/debug/*").
+	SkipPaths []string
+
+	// DenyHandler replaces the default RFC 9457 problem response for
+	// denied requests.
+	DenyHandler DenyHandler
+
+	// HideReasons omits the policy decision reason (which may name
+	// internal policy IDs) from the default problem response. The reason
+	// is still logged.
+	HideReasons bool
+
+	// AuthorizeCaller evaluates each request with the calling agent, rather
+	// than this agent, as the principal. The caller is identified by the
+	// Dome identity token in the Authorization header, verified against the
+	// control plane's signing keys. Requests without a valid token receive
+	// 401 Unauthorized.
+	AuthorizeCaller bool
+
+	// Unauthenticated replaces the default 401 problem response when
+	// AuthorizeCaller is set and the caller cannot be authenticated.
+	Unauthenticated func(w http.ResponseWriter, r *http.Request, err error)
+}
+
+// Problem is an RFC 9457 problem details body, written with content type
+// application/problem+json when the middleware denies a request.
+type Problem struct {
+	Type          string `json:"type"`
+	Title         string `json:"title"`
+	Status        int    `json:"status"`
+	Detail        string `json:"detail,omitempty"`
+	Instance      string `json:"instance,omitempty"`
+	Code          string `json:"code"`
+	RequestID     string `json:"request_id,omitempty"`
+	PolicyVersion string `json:"policy_version,omitempty"`
+}
+
 // Middleware wraps an http.Handler with Dome governance. Each incoming request
 // is evaluated against the Cedar policy bundle. If denied, the request
-// receives a 403 Forbidden response with the denial reason.
+// receives a 403 Forbidden application/problem+json response (429 Too Many
+// Requests when a quota is exhausted).
 //
 // If no policies are loaded, all requests are allowed (fail-open for v0.4.0).
 func (c *Client) Middleware(next http.Handler) http.Handler {
+	return c.MiddlewareWithOptions(next, MiddlewareOptions{})
+}
+
+// MiddlewareWithOptions is like Middleware but allows customizing how
+// requests map to policy checks and how denied requests are answered.
+func (c *Client) MiddlewareWithOptions(next http.Handler, opts MiddlewareOptions) http.Handler {
+	deny := opts.DenyHandler
+	if deny == nil {
+		deny = problemDenyHandler(opts.HideReasons)
+	}
+	mapper := opts.Mapper
+	if mapper == nil {
+		mapper = MethodPathMapper
+	}
+	unauthenticated := opts.Unauthenticated
+	if unauthenticated == nil {
+		unauthenticated = problemUnauthenticatedHandler(opts.HideReasons)
+	}
+
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		action := httpMethodToAction(r.Method)
-		resource := strings.TrimPrefix(r.URL.Path, "/")
+		if skipPath(opts.SkipPaths, r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
 
-		decision, err := c.Check(r.Context(), CheckRequest{
-			Action:   action,
-			Resource: resource,
-		})
+		req := mapper(r)
+		req.Context = withRequestContext(req.Context, r, opts)
+
+		var decision *Decision
+		var err error
+		if opts.AuthorizeCaller {
+			caller, authErr := c.authenticateHeader(r.Context(), r.Header)
+			if authErr != nil {
+				c.logger.Warn("dome: caller authentication failed",
+					"method", r.Method,
+					"path", r.URL.Path,
+					"error", authErr,
+				)
+				if errors.Is(authErr, ErrUnauthenticated) {
+					unauthenticated(w, r, authErr)
+					return
+				}
+				// Control plane unreachable: fail closed, since there is
+				// no principal to evaluate.
+				writeUnavailable(w, r)
+				return
+			}
+			r = r.WithContext(ContextWithAgent(r.Context(), caller))
+			decision, err = c.CheckCaller(r.Context(), caller, req)
+		} else {
+			decision, err = c.Check(r.Context(), req)
+		}
 		if err != nil {
 			c.logger.Error("dome: policy check error", "error", err)
 			// Fail-open on error.
@@ -32,7 +159,7 @@ func (c *Client) Middleware(next http.Handler) http.Handler {
 				"path", r.URL.Path,
 				"reason", decision.Reason,
 			)
-			http.Error(w, "Forbidden: "+decision.Reason, http.StatusForbidden)
+			deny(w, r, decision)
 			return
 		}
 
@@ -40,6 +167,235 @@ func (c *Client) Middleware(next http.Handler) http.Handler {
 	})
 }
 
+// MethodPathMapper is the default RequestMapper. It maps the HTTP method to
+// an action (GET→read, POST→create, PUT/PATCH→update, DELETE→delete) and
+// uses the URL path without its leading slash as the resource.
+func MethodPathMapper(r *http.Request) CheckRequest {
+	return CheckRequest{
+		Action:   httpMethodToAction(r.Method),
+		Resource: strings.TrimPrefix(r.URL.Path, "/"),
+	}
+}
+
+// RouteMapper returns a RequestMapper that uses the ServeMux route pattern
+// matching the request as the resource, so a policy written for
+// "users/{id}" covers every user. The resource type is "route" and each
+// path wildcard is added to the context as "path.<name>".
+//
+// When the middleware wraps a handler registered on a ServeMux, the pattern
+// is read from r.Pattern. When it wraps the mux itself, pass the mux so the
+// pattern can be resolved before routing; mux may be nil otherwise. Requests
+// that match no pattern fall back to MethodPathMapper.
+func RouteMapper(mux *http.ServeMux) RequestMapper {
+	return func(r *http.Request) CheckRequest {
+		pattern := r.Pattern
+		if pattern == "" && mux != nil {
+			_, pattern = mux.Handler(r)
+		}
+		if pattern == "" {
+			return MethodPathMapper(r)
+		}
+
+		route := patternPath(pattern)
+		req := CheckRequest{
+			Action:       httpMethodToAction(r.Method),
+			Resource:     strings.TrimPrefix(route, "/"),
+			ResourceType: "route",
+		}
+		for name, value := range pathValues(route, r) {
+			if req.Context == nil {
+				req.Context = make(map[string]string)
+			}
+			req.Context["path."+name] = value
+		}
+		return req
+	}
+}
+
+// patternPath strips the method and host from a ServeMux pattern, e.g.
+// "GET example.com/users/{id}" becomes "/users/{id}".
+func patternPath(pattern string) string {
+	if _, rest, ok := strings.Cut(pattern, " "); ok {
+		pattern = strings.TrimSpace(rest)
+	}
+	if i := strings.IndexByte(pattern, '/'); i > 0 {
+		pattern = pattern[i:]
+	}
+	return pattern
+}
+
+// pathValues extracts the wildcard values of route from the request. It
+// prefers r.PathValue, which is populated once the mux has routed the
+// request, and otherwise matches the path segments itself.
+func pathValues(route string, r *http.Request) map[string]string {
+	patternSegs := strings.Split(strings.Trim(route, "/"), "/")
+	pathSegs := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	values := make(map[string]string)
+	for i, seg := range patternSegs {
+		if !strings.HasPrefix(seg, "{") || !strings.HasSuffix(seg, "}") {
+			continue
+		}
+		name := strings.TrimSuffix(strings.TrimPrefix(seg, "{"), "}")
+		if name == "$" {
+			continue
+		}
+		rest := strings.HasSuffix(name, "...")
+		name = strings.TrimSuffix(name, "...")
+
+		value := r.PathValue(name)
+		if value == "" && i < len(pathSegs) {
+			if rest {
+				value = strings.Join(pathSegs[i:], "/")
+			} else {
+				value = pathSegs[i]
+			}
+		}
+		if value != "" {
+			values[name] = value
+		}
+	}
+	return values
+}
+
+// withRequestContext adds the configured headers and query parameters to
+// a copy of the check context, leaving the Mapper's map untouched since it
+// may be shared between requests.
+func withRequestContext(ctx map[string]string, r *http.Request, opts MiddlewareOptions) map[string]string {
+	if len(opts.Headers) == 0 && len(opts.QueryParams) == 0 {
+		return ctx
+	}
+	ctx = maps.Clone(ctx)
+	if ctx == nil {
+		ctx = make(map[string]string)
+	}
+	for _, name := range opts.Headers {
+		if v := r.Header.Get(name); v != "" {
+			ctx["header."+strings.ToLower(name)] = v
+		}
+	}
+	query := r.URL.Query()
+	for _, name := range opts.QueryParams {
+		if query.Has(name) {
+			ctx["query."+name] = query.Get(name)
+		}
+	}
+	return ctx
+}
+
+// skipPath reports whether path matches an entry of skip.
+func skipPath(skip []string, path string) bool {
+	for _, s := range skip {
+		if strings.HasSuffix(s, "*") {
+			if strings.HasPrefix(path, strings.TrimSuffix(s, "*")) {
+				return true
+			}
+		} else if s == path {
+			return true
+		}
+	}
+	return false
+}
+
+// DenialCode returns the stable error code for a denied decision.
+func DenialCode(d *Decision) string {
+	if d != nil && d.QuotaExceeded {
+		return ErrorCodeQuotaExceeded
+	}
+	if d != nil && d.RequiresApproval {
+		return ErrorCodeApprovalRequired
+	}
+	return ErrorCodePolicyDenied
+}
+
+// problemDenyHandler returns the default DenyHandler, which writes an
+// RFC 9457 problem details body.
+func problemDenyHandler(hideReasons bool) DenyHandler {
+	return func(w http.ResponseWriter, r *http.Request, decision *Decision) {
+		code := DenialCode(decision)
+		status := http.StatusForbidden
+		detail := decision.Reason
+		if code == ErrorCodeQuotaExceeded {
+			status = http.StatusTooManyRequests
+		}
+		if hideReasons {
+			detail = "The request was denied by policy."
+			switch code {
+			case ErrorCodeQuotaExceeded:
+				detail = "The request exceeded a policy quota."
+			case ErrorCodeApprovalRequired:
+				detail = "The request requires human approval."
+			}
+		}
+
+		requestID := requestIDFrom(r)
+		w.Header().Set(RequestIDHeader, requestID)
+		writeProblem(w, Problem{
+			Type:          "urn:dome:error:" + code,
+			Title:         http.StatusText(status),
+			Status:        status,
+			Detail:        detail,
+			Instance:      r.URL.Path,
+			Code:          code,
+			RequestID:     requestID,
+			PolicyVersion: decision.PolicyVersion,
+		})
+	}
+}
+
+// problemUnauthenticatedHandler returns the default handler for requests
+// whose caller identity could not be verified.
+func problemUnauthenticatedHandler(hideReasons bool) func(http.ResponseWriter, *http.Request, error) {
+	return func(w http.ResponseWriter, r *http.Request, err error) {
+		detail := err.Error()
+		if hideReasons {
+			detail = "A valid Dome identity token is required."
+		}
+		requestID := requestIDFrom(r)
+		w.Header().Set(RequestIDHeader, requestID)
+		w.Header().Set("WWW-Authenticate", `Bearer realm="dome"`)
+		writeProblem(w, Problem{
+			Type:      "urn:dome:error:" + ErrorCodeUnauthenticated,
+			Title:     http.StatusText(http.StatusUnauthorized),
+			Status:    http.StatusUnauthorized,
+			Detail:    detail,
+			Instance:  r.URL.Path,
+			Code:      ErrorCodeUnauthenticated,
+			RequestID: requestID,
+		})
+	}
+}
+
+// writeUnavailable answers a request whose caller could not be verified
+// because the control plane is unreachable.
+func writeUnavailable(w http.ResponseWriter, r *http.Request) {
+	writeProblem(w, Problem{
+		Type:     "urn:dome:error:" + ErrorCodeUnavailable,
+		Title:    http.StatusText(http.StatusServiceUnavailable),
+		Status:   http.StatusServiceUnavailable,
+		Instance: r.URL.Path,
+		Code:     ErrorCodeUnavailable,
+	})
+}
+
+// writeProblem writes p as an application/problem+json response.
+func writeProblem(w http.ResponseWriter, p Problem) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	_ = json.NewEncoder(w).Encode(p)
+}
+
+// requestIDFrom returns the request's X-Request-Id or generates a new one.
+func requestIDFrom(r *http.Request) string {
+	if id := r.Header.Get(RequestIDHeader); id != "" {
+		return id
+	}
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
 func httpMethodToAction(method string) string {
 	switch method {
 	case http.MethodGet, http.MethodHead:
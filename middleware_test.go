@@ -0,0 +1,237 @@
+package dome_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	dome "github.com/Dome-Systems/sdk-dome-go"
+	"github.com/Dome-Systems/sdk-dome-go/internal/policy"
+)
+
+const readOnlyCedar = `
+@id("read-only")
+permit(
+    principal is Dome::Agent,
+    action == Dome::Action::"read",
+    resource
+);
+`
+
+func okHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+func TestMiddleware_ProblemResponse(t *testing.T) {
+	serverURL := testServerWithBundle(t, policy.BundleResponse{
+		Version:  "v7",
+		Policies: []policy.PolicyFile{{Filename: "read.cedar", Content: readOnlyCedar}},
+	})
+	client := startedClient(t, serverURL)
+
+	handler := client.Middleware(okHandler())
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/users", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET status = %d, want 200", rec.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodDelete, "/users/1", nil)
+	req.Header.Set(dome.RequestIDHeader, "req-123")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("DELETE status = %d, want 403", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/problem+json" {
+		t.Errorf("Content-Type = %q, want application/problem+json", ct)
+	}
+
+	var p dome.Problem
+	if err := json.NewDecoder(rec.Body).Decode(&p); err != nil {
+		t.Fatalf("decode problem: %v", err)
+	}
+	if p.Status != http.StatusForbidden || p.Code != dome.ErrorCodePolicyDenied {
+		t.Errorf("problem = %+v, want 403 %s", p, dome.ErrorCodePolicyDenied)
+	}
+	if p.RequestID != "req-123" || rec.Header().Get(dome.RequestIDHeader) != "req-123" {
+		t.Errorf("request ID = %q, want req-123 echoed", p.RequestID)
+	}
+	if p.PolicyVersion != "v7" {
+		t.Errorf("PolicyVersion = %q, want v7", p.PolicyVersion)
+	}
+	if p.Instance != "/users/1" {
+		t.Errorf("Instance = %q, want /users/1", p.Instance)
+	}
+	if p.Detail == "" {
+		t.Error("expected detail with the denial reason")
+	}
+}
+
+func TestMiddleware_HideReasons(t *testing.T) {
+	serverURL := testServerWithBundle(t, policy.BundleResponse{
+		Version:  "v1",
+		Policies: []policy.PolicyFile{{Filename: "read.cedar", Content: readOnlyCedar}},
+	})
+	client := startedClient(t, serverURL)
+
+	handler := client.MiddlewareWithOptions(okHandler(), dome.MiddlewareOptions{HideReasons: true})
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/users", nil))
+
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want 403", rec.Code)
+	}
+	body := rec.Body.String()
+	if strings.Contains(body, "read.cedar") || strings.Contains(body, "no matching permit") {
+		t.Errorf("body leaks internal reason: %s", body)
+	}
+	if rec.Header().Get(dome.RequestIDHeader) == "" {
+		t.Error("expected a generated request ID")
+	}
+}
+
+func TestMiddleware_CustomDenyHandler(t *testing.T) {
+	serverURL := testServerWithBundle(t, policy.BundleResponse{
+		Version:  "v1",
+		Policies: []policy.PolicyFile{{Filename: "read.cedar", Content: readOnlyCedar}},
+	})
+	client := startedClient(t, serverURL)
+
+	var got *dome.Decision
+	handler := client.MiddlewareWithOptions(okHandler(), dome.MiddlewareOptions{
+		DenyHandler: func(w http.ResponseWriter, _ *http.Request, d *dome.Decision) {
+			got = d
+			w.WriteHeader(http.StatusTeapot)
+		},
+	})
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/users/1", nil))
+
+	if rec.Code != http.StatusTeapot {
+		t.Errorf("status = %d, want 418", rec.Code)
+	}
+	if got == nil || got.Allowed {
+		t.Errorf("DenyHandler decision = %+v, want denied", got)
+	}
+}
+
+const routeCedar = `
+@id("read-own-user")
+permit(
+    principal is Dome::Agent,
+    action == Dome::Action::"read",
+    resource == Dome::Resource::"users/{id}"
+) when {
+    context["path.id"] == context["header.x-user-id"]
+};
+`
+
+func TestMiddleware_RouteMapper(t *testing.T) {
+	serverURL := testServerWithBundle(t, policy.BundleResponse{
+		Version:  "v1",
+		Policies: []policy.PolicyFile{{Filename: "route.cedar", Content: routeCedar}},
+	})
+	client := startedClient(t, serverURL)
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /users/{id}", okHandler())
+	mux.Handle("GET /healthz", okHandler())
+
+	tests := []struct {
+		name    string
+		handler http.Handler
+	}{
+		{
+			// Middleware wraps the mux: the pattern is resolved via the mux.
+			name: "outer",
+			handler: client.MiddlewareWithOptions(mux, dome.MiddlewareOptions{
+				Mapper:    dome.RouteMapper(mux),
+				Headers:   []string{"X-User-Id"},
+				SkipPaths: dome.DefaultSkipPaths,
+			}),
+		},
+		{
+			// Middleware wraps a routed handler: the pattern is on r.Pattern.
+			name: "inner",
+			handler: func() http.Handler {
+				inner := http.NewServeMux()
+				opts := dome.MiddlewareOptions{
+					Mapper:  dome.RouteMapper(nil),
+					Headers: []string{"X-User-Id"},
+				}
+				inner.Handle("GET /users/{id}", client.MiddlewareWithOptions(okHandler(), opts))
+				inner.Handle("GET /healthz", okHandler())
+				return inner
+			}(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/users/42", nil)
+			req.Header.Set("X-User-Id", "42")
+			rec := httptest.NewRecorder()
+			tt.handler.ServeHTTP(rec, req)
+			if rec.Code != http.StatusOK {
+				t.Errorf("own user: status = %d, want 200 (%s)", rec.Code, rec.Body)
+			}
+
+			req = httptest.NewRequest(http.MethodGet, "/users/7", nil)
+			req.Header.Set("X-User-Id", "42")
+			rec = httptest.NewRecorder()
+			tt.handler.ServeHTTP(rec, req)
+			if rec.Code != http.StatusForbidden {
+				t.Errorf("other user: status = %d, want 403", rec.Code)
+			}
+
+			rec = httptest.NewRecorder()
+			tt.handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+			if rec.Code != http.StatusOK {
+				t.Errorf("healthz: status = %d, want 200 (skipped)", rec.Code)
+			}
+		})
+	}
+}
+
+func TestMiddleware_CustomMapperAndQuery(t *testing.T) {
+	serverURL := testServerWithBundle(t, policy.BundleResponse{
+		Version: "v1",
+		Policies: []policy.PolicyFile{{Filename: "export.cedar", Content: `
+permit(principal, action == Dome::Action::"reports:export", resource)
+when { context["query.format"] == "csv" };
+`}},
+	})
+	client := startedClient(t, serverURL)
+
+	// The mapper returns a shared map, which must not be written to.
+	shared := map[string]string{"source": "reports"}
+	handler := client.MiddlewareWithOptions(okHandler(), dome.MiddlewareOptions{
+		Mapper: func(r *http.Request) dome.CheckRequest {
+			return dome.CheckRequest{Action: "reports:export", Resource: r.URL.Path, Context: shared}
+		},
+		QueryParams: []string{"format"},
+	})
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/reports?format=csv", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("csv: status = %d, want 200", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/reports?format=pdf", nil))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("pdf: status = %d, want 403", rec.Code)
+	}
+
+	if len(shared) != 1 {
+		t.Errorf("mapper context modified: %v", shared)
+	}
+}
@@ -22,15 +22,28 @@ const (
 
 // clientConfig holds resolved configuration for the SDK client.
 type clientConfig struct {
-	apiURL              string
-	apiKey              string
-	credentials         string
-	heartbeatInterval   time.Duration
-	disableHeartbeat    bool
-	gracefulDegradation bool
-	policyRefresh       time.Duration
-	disablePolicy       bool
-	logger              *slog.Logger
+	apiURL                  string
+	apiKey                  string
+	credentials             string
+	heartbeatInterval       time.Duration
+	disableHeartbeat        bool
+	gracefulDegradation     bool
+	policyRefresh           time.Duration
+	disablePolicy           bool
+	quotaBackend            QuotaBackend
+	approver                Approver
+	driftPolicy             DriftPolicy
+	disableRuntimeDetection bool
+	runtimeMetrics          bool
+	onRegistered            func(*AgentInfo)
+	onRevoked               func(*Agent)
+	onSuspended             func(*Agent)
+	onReactivated           func(*Agent)
+	jwksURL                 string
+	tokenIssuer             string
+	tokenAudience           string
+	vaultSecretsPrefix      string
+	logger                  *slog.Logger
 }
 
 // Option configures the SDK client.
@@ -79,7 +92,8 @@ func WithHeartbeatInterval(d time.Duration) Option {
 	}
 }
 
-// WithoutHeartbeat disables the background heartbeat goroutine.
+// WithoutHeartbeat disables heartbeats. The agent's control plane status is
+// still refreshed at the heartbeat interval.
 func WithoutHeartbeat() Option {
 	return func(c *clientConfig) {
 		c.disableHeartbeat = true
@@ -117,11 +131,132 @@ func WithoutPolicy() Option {
 	}
 }
 
+// WithQuotaBackend replaces the in-process token bucket used to enforce
+// policy quotas. Use this to share quotas across replicas of an agent.
+func WithQuotaBackend(b QuotaBackend) Option {
+	return func(c *clientConfig) {
+		if b != nil {
+			c.quotaBackend = b
+		}
+	}
+}
+
+// WithApprover replaces the control plane as the source of human approval
+// decisions for RequestApproval, e.g. with a LocalApprover in tests.
+func WithApprover(a Approver) Option {
+	return func(c *clientConfig) {
+		if a != nil {
+			c.approver = a
+		}
+	}
+}
+
+// WithDriftPolicy sets what Start does when an existing agent's stored
+// capabilities or metadata differ from StartOptions. Default: DriftReconcile.
+func WithDriftPolicy(p DriftPolicy) Option {
+	return func(c *clientConfig) {
+		c.driftPolicy = p
+	}
+}
+
+// WithoutRuntimeDetection stops Start from describing the runtime with
+// DetectRuntime when StartOptions.Runtime is nil; no runtime configuration
+// is sent.
+func WithoutRuntimeDetection() Option {
+	return func(c *clientConfig) {
+		c.disableRuntimeDetection = true
+	}
+}
+
+// WithRuntimeMetrics adds Go runtime metrics to the heartbeats of the
+// client's own agent: goroutines, heap and total memory in bytes as gauges,
+// and GC cycles and (estimated) GC pause seconds as counters.
+func WithRuntimeMetrics() Option {
+	return func(c *clientConfig) {
+		c.runtimeMetrics = true
+	}
+}
+
+// WithOnRegistered registers a callback run once the agent is registered:
+// during Start, or under WithGracefulDegradation when background
+// registration succeeds. It must not block.
+func WithOnRegistered(fn func(*AgentInfo)) Option {
+	return func(c *clientConfig) {
+		c.onRegistered = fn
+	}
+}
+
+// WithOnRevoked registers a callback run when the control plane reports
+// that this agent was revoked. From then on Check denies everything. The
+// status is refreshed at the heartbeat interval, even under
+// WithoutHeartbeat. The callback runs on a background goroutine and must
+// not block.
+func WithOnRevoked(fn func(*Agent)) Option {
+	return func(c *clientConfig) {
+		c.onRevoked = fn
+	}
+}
+
+// WithOnSuspended registers a callback run when the control plane reports
+// that this agent was suspended. Until it is reactivated Check denies
+// everything. The callback runs on a background goroutine and must not
+// block.
+func WithOnSuspended(fn func(*Agent)) Option {
+	return func(c *clientConfig) {
+		c.onSuspended = fn
+	}
+}
+
+// WithOnReactivated registers a callback run when a revoked or suspended
+// agent becomes usable again and Check resumes evaluating policy. The
+// callback runs on a background goroutine and must not block.
+func WithOnReactivated(fn func(*Agent)) Option {
+	return func(c *clientConfig) {
+		c.onReactivated = fn
+	}
+}
+
+// WithJWKSURL sets the URL of the control plane's JSON Web Key Set used to
+// verify inbound caller identity tokens. Default: <API URL>/.well-known/jwks.json.
+func WithJWKSURL(url string) Option {
+	return func(c *clientConfig) {
+		c.jwksURL = url
+	}
+}
+
+// WithTokenIssuer requires inbound caller identity tokens to carry the given
+// "iss" claim.
+func WithTokenIssuer(issuer string) Option {
+	return func(c *clientConfig) {
+		c.tokenIssuer = issuer
+	}
+}
+
+// WithTokenAudience sets the value inbound caller identity tokens must list
+// in their "aud" claim. Default: this agent's ID. Callers must request
+// tokens for the same audience.
+func WithTokenAudience(audience string) Option {
+	return func(c *clientConfig) {
+		c.tokenAudience = audience
+	}
+}
+
 func defaultConfig() clientConfig {
 	return clientConfig{
 		apiURL:            DefaultAPIURL,
 		heartbeatInterval: DefaultHeartbeatInterval,
 		policyRefresh:     DefaultPolicyRefreshInterval,
+		quotaBackend:      newLocalQuotaBackend(),
 		logger:            slog.Default(),
 	}
 }
+
+// WithVaultSecrets makes FetchCredential read secrets directly from Vault at
+// <prefix>/<name>, using the Vault login from WithCredentials, instead of
+// through the control plane. For KV v2 the prefix includes the "data"
+// segment, e.g. "secret/data/agents/billing".
+func WithVaultSecrets(prefix string) Option {
+	return func(c *clientConfig) {
+		c.vaultSecretsPrefix = prefix
+	}
+}
@@ -0,0 +1,250 @@
+package dome
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+
+	"connectrpc.com/connect"
+)
+
+const (
+	// AgentTokenHeader carries the calling agent's Dome identity token on
+	// agent-to-agent requests. A dedicated header leaves Authorization free
+	// for the target service's own credentials.
+	//
+	// The token sent is not the agent's control plane credential: it is
+	// exchanged for one whose audience is the agent being called, so a
+	// receiver that is compromised or malicious cannot replay it against
+	// other agents or the control plane. Within its lifetime it can still be
+	// replayed to the same receiver. AgentChainHeader is not covered by the
+	// token; treat the chain as a claim made by the verified caller.
+	AgentTokenHeader = "Dome-Agent-Token"
+
+	// AgentChainHeader lists the IDs of the agents that called the calling
+	// agent, outermost first, separated by commas.
+	AgentChainHeader = "Dome-Agent-Chain"
+
+	// maxCallChain caps the number of chain entries accepted from a request.
+	maxCallChain = 32
+)
+
+// errNoIdentityToken is returned when outbound identity propagation is
+// used without agent credentials.
+var errNoIdentityToken = errors.New("no agent identity token: identity propagation requires agent credentials (WithCredentials or DOME_AGENT_TOKEN), not an API key")
+
+type agentContextKey struct{}
+
+// ContextWithAgent returns a copy of ctx carrying caller as the verified
+// calling agent.
+func ContextWithAgent(ctx context.Context, caller *Caller) context.Context {
+	return context.WithValue(ctx, agentContextKey{}, caller)
+}
+
+// AgentFromContext returns the verified calling agent of an inbound
+// request. It is set by IdentityMiddleware, IdentityInterceptor and
+// Middleware with AuthorizeCaller.
+func AgentFromContext(ctx context.Context) (*Caller, bool) {
+	caller, ok := ctx.Value(agentContextKey{}).(*Caller)
+	return caller, ok && caller != nil
+}
+
+// outboundIdentity returns this agent's identity token for audience and
+// the call chain to send with a request made while handling ctx.
+func (c *Client) outboundIdentity(ctx context.Context, audience string) (token, chain string, err error) {
+	if c.identityToken == nil {
+		return "", "", errorf("%w", errNoIdentityToken)
+	}
+	token, err = c.identityToken(audience)
+	if err != nil {
+		return "", "", errorf("identity token: %w", err)
+	}
+	if caller, ok := AgentFromContext(ctx); ok {
+		chain = strings.Join(append(slices.Clone(caller.Chain), caller.AgentID), ",")
+	}
+	return token, chain, nil
+}
+
+// setIdentityHeaders adds the identity token and call chain to h.
+func setIdentityHeaders(h http.Header, token, chain string) {
+	h.Set(AgentTokenHeader, token)
+	if chain != "" {
+		h.Set(AgentChainHeader, chain)
+	} else {
+		h.Del(AgentChainHeader)
+	}
+}
+
+// IdentityTransport returns an http.RoundTripper that attaches a Dome
+// identity token for this agent to every request, so the receiving agent
+// can verify who is calling. The token is scoped to audience, the token
+// audience of the receiving agent (by default its agent ID; see
+// WithTokenAudience), and is rejected anywhere else. When the request
+// context carries a verified caller (the request is made while handling
+// one), the call chain is extended with it. If base is nil,
+// http.DefaultTransport is used.
+//
+// Identity propagation requires agent credentials; with an API key every
+// request fails.
+func (c *Client) IdentityTransport(base http.RoundTripper, audience string) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		token, chain, err := c.outboundIdentity(req.Context(), audience)
+		if err != nil {
+			if req.Body != nil {
+				_ = req.Body.Close()
+			}
+			return nil, err
+		}
+		clone := req.Clone(req.Context())
+		setIdentityHeaders(clone.Header, token, chain)
+		return base.RoundTrip(clone)
+	})
+}
+
+// callerToken extracts an identity token from AgentTokenHeader or, failing
+// that, an "Authorization: Bearer" header.
+func callerToken(h http.Header) string {
+	if token := strings.TrimSpace(h.Get(AgentTokenHeader)); token != "" {
+		return token
+	}
+	scheme, token, ok := strings.Cut(h.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// callChain parses AgentChainHeader.
+func callChain(h http.Header) []string {
+	value := h.Get(AgentChainHeader)
+	if value == "" {
+		return nil
+	}
+	var chain []string
+	for _, id := range strings.Split(value, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			chain = append(chain, id)
+		}
+	}
+	if len(chain) > maxCallChain {
+		chain = chain[len(chain)-maxCallChain:]
+	}
+	return chain
+}
+
+// authenticateHeader verifies the identity token in h and attaches the
+// call chain to the resulting caller.
+func (c *Client) authenticateHeader(ctx context.Context, h http.Header) (*Caller, error) {
+	caller, err := c.AuthenticateCaller(ctx, callerToken(h))
+	if err != nil {
+		return nil, err
+	}
+	caller.Chain = callChain(h)
+	return caller, nil
+}
+
+// IdentityMiddleware verifies the Dome identity token of inbound requests
+// and makes the caller available through AgentFromContext. Requests without
+// a token are passed through without a caller; requests with an invalid
+// token are answered 401 with a problem details body. It does not evaluate
+// policy — use Middleware with AuthorizeCaller for that.
+func (c *Client) IdentityMiddleware(next http.Handler) http.Handler {
+	unauthenticated := problemUnauthenticatedHandler(false)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if callerToken(r.Header) == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		caller, err := c.authenticateHeader(r.Context(), r.Header)
+		if err != nil {
+			c.logger.Warn("dome: caller authentication failed", "method", r.Method, "path", r.URL.Path, "error", err)
+			if errors.Is(err, ErrUnauthenticated) {
+				unauthenticated(w, r, err)
+				return
+			}
+			writeUnavailable(w, r)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(ContextWithAgent(r.Context(), caller)))
+	})
+}
+
+// IdentityInterceptor returns a connect.Interceptor that propagates agent
+// identity. On clients it attaches an identity token scoped to audience and
+// the call chain to every call, like IdentityTransport. On handlers it
+// verifies the caller's token and makes the caller available through
+// AgentFromContext; calls without a token proceed without a caller, and
+// calls with an invalid token fail with connect.CodeUnauthenticated.
+// Handlers ignore audience, so pass "" for an interceptor used only there.
+func (c *Client) IdentityInterceptor(audience string) connect.Interceptor {
+	return &identityInterceptor{client: c, audience: audience}
+}
+
+type identityInterceptor struct {
+	client   *Client
+	audience string // of outbound calls
+}
+
+func (i *identityInterceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
+	return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		if req.Spec().IsClient {
+			token, chain, err := i.client.outboundIdentity(ctx, i.audience)
+			if err != nil {
+				return nil, connect.NewError(connect.CodeUnauthenticated, err)
+			}
+			setIdentityHeaders(req.Header(), token, chain)
+			return next(ctx, req)
+		}
+		ctx, err := i.verify(ctx, req.Spec().Procedure, req.Header())
+		if err != nil {
+			return nil, err
+		}
+		return next(ctx, req)
+	}
+}
+
+func (i *identityInterceptor) WrapStreamingClient(next connect.StreamingClientFunc) connect.StreamingClientFunc {
+	return func(ctx context.Context, spec connect.Spec) connect.StreamingClientConn {
+		conn := next(ctx, spec)
+		token, chain, err := i.client.outboundIdentity(ctx, i.audience)
+		if err != nil {
+			// The server rejects the stream as unauthenticated.
+			i.client.logger.Warn("dome: identity propagation failed", "procedure", spec.Procedure, "error", err)
+			return conn
+		}
+		setIdentityHeaders(conn.RequestHeader(), token, chain)
+		return conn
+	}
+}
+
+func (i *identityInterceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+	return func(ctx context.Context, conn connect.StreamingHandlerConn) error {
+		ctx, err := i.verify(ctx, conn.Spec().Procedure, conn.RequestHeader())
+		if err != nil {
+			return err
+		}
+		return next(ctx, conn)
+	}
+}
+
+// verify authenticates the caller of an inbound RPC, if it presented a
+// token, and stores it in the returned context.
+func (i *identityInterceptor) verify(ctx context.Context, procedure string, h http.Header) (context.Context, error) {
+	if callerToken(h) == "" {
+		return ctx, nil
+	}
+	caller, err := i.client.authenticateHeader(ctx, h)
+	if err != nil {
+		i.client.logger.Warn("dome: caller authentication failed", "procedure", procedure, "error", err)
+		if errors.Is(err, ErrUnauthenticated) {
+			return ctx, connect.NewError(connect.CodeUnauthenticated, err)
+		}
+		return ctx, connect.NewError(connect.CodeUnavailable, err)
+	}
+	return ContextWithAgent(ctx, caller), nil
+}
@@ -0,0 +1,204 @@
+package dome_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+
+	dome "github.com/Dome-Systems/sdk-dome-go"
+	apiv1 "github.com/Dome-Systems/sdk-dome-go/internal/api"
+	"github.com/Dome-Systems/sdk-dome-go/internal/api/agentv1connect"
+	"github.com/Dome-Systems/sdk-dome-go/internal/policy"
+)
+
+const chainCedar = `
+@id("reports-readers")
+permit(
+    principal is Dome::Agent,
+    action == Dome::Action::"read",
+    resource
+) when {
+    principal.capabilities.contains("reports:read")
+};
+
+@id("no-untrusted-origin")
+forbid(principal, action, resource) when {
+    context has "caller.chain" && context["caller.chain"].contains("untrusted")
+};
+`
+
+// callingClient returns a client authenticated as agentID through token
+// exchange, with tokens minted by env. A token exchanged for an audience
+// carries it; the agent's own token has none.
+func callingClient(t *testing.T, env *identityEnv, agentID string) *dome.Client {
+	t.Helper()
+	exchange := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"access_token": env.token(t, agentID, req["audience"], time.Hour),
+			"expires_in":   3600,
+		})
+	}))
+	t.Cleanup(exchange.Close)
+
+	blob, _ := json.Marshal(map[string]string{
+		"api_url":     exchange.URL,
+		"auth_method": "approle",
+		"role_id":     "role",
+		"secret_id":   "secret",
+	})
+	client, err := dome.NewClient(
+		dome.WithCredentials(base64.StdEncoding.EncodeToString(blob)),
+		dome.WithAPIURL(env.url),
+		dome.WithoutHeartbeat(),
+		dome.WithoutPolicy(),
+	)
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+	return client
+}
+
+func TestIdentityTransport(t *testing.T) {
+	env := newIdentityEnv(t, policy.BundleResponse{
+		Version:  "v1",
+		Policies: []policy.PolicyFile{{Filename: "chain.cedar", Content: chainCedar}},
+	})
+	env.addAgent("agent-a", apiv1.AgentStatus_AGENT_STATUS_ACTIVE, "reports:read")
+	receiver := startedClient(t, env.url)
+
+	var mu sync.Mutex
+	var seen *dome.Caller
+	server := httptest.NewServer(receiver.IdentityMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		caller, _ := dome.AgentFromContext(r.Context())
+		mu.Lock()
+		seen = caller
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	})))
+	t.Cleanup(server.Close)
+	lastCaller := func() *dome.Caller {
+		mu.Lock()
+		defer mu.Unlock()
+		return seen
+	}
+
+	calling := callingClient(t, env, "agent-a")
+	httpClient := &http.Client{Transport: calling.IdentityTransport(nil, receiver.AgentID())}
+
+	resp, err := httpClient.Get(server.URL)
+	if err != nil {
+		t.Fatalf("GET error: %v", err)
+	}
+	_ = resp.Body.Close()
+	if c := lastCaller(); c == nil || c.AgentID != "agent-a" || len(c.Chain) != 0 {
+		t.Fatalf("caller = %+v, want agent-a without chain", c)
+	}
+
+	// A token scoped to another agent is rejected.
+	resp, err = (&http.Client{Transport: calling.IdentityTransport(nil, "agent-z")}).Get(server.URL)
+	if err != nil {
+		t.Fatalf("GET error: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("status with a token for another audience = %d, want 401", resp.StatusCode)
+	}
+
+	// A request made while handling a call extends the chain.
+	ctx := dome.ContextWithAgent(context.Background(), &dome.Caller{AgentID: "agent-0", Chain: []string{"untrusted"}})
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	resp, err = httpClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET error: %v", err)
+	}
+	_ = resp.Body.Close()
+	caller := lastCaller()
+	if caller == nil || !slices.Equal(caller.Chain, []string{"untrusted", "agent-0"}) {
+		t.Fatalf("caller = %+v, want chain [untrusted agent-0]", caller)
+	}
+
+	// Policy can authorize on the chain.
+	d, err := receiver.CheckCaller(context.Background(), caller, dome.CheckRequest{Action: "read", Resource: "q3"})
+	if err != nil {
+		t.Fatalf("CheckCaller error: %v", err)
+	}
+	if d.Allowed {
+		t.Error("call via untrusted origin allowed, want denied")
+	}
+
+	// An invalid token is rejected; no token passes without a caller.
+	req, _ = http.NewRequest(http.MethodGet, server.URL, nil)
+	req.Header.Set(dome.AgentTokenHeader, "not-a-token")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET error: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("status = %d, want 401", resp.StatusCode)
+	}
+	resp, err = http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("GET error: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent || lastCaller() != nil {
+		t.Errorf("status = %d, caller = %+v, want 204 without caller", resp.StatusCode, lastCaller())
+	}
+
+	// API key clients have no identity token to propagate.
+	if _, err := (&http.Client{Transport: receiver.IdentityTransport(nil, "agent-a")}).Get(server.URL); err == nil {
+		t.Error("expected error propagating identity with an API key")
+	}
+}
+
+// callerRecorder is a registry handler that records the verified caller.
+type callerRecorder struct {
+	*mockHandler
+	caller chan *dome.Caller
+}
+
+func (h *callerRecorder) GetAgent(ctx context.Context, req *connect.Request[apiv1.GetAgentRequest]) (*connect.Response[apiv1.GetAgentResponse], error) {
+	caller, _ := dome.AgentFromContext(ctx)
+	h.caller <- caller
+	return connect.NewResponse(&apiv1.GetAgentResponse{Agent: &apiv1.Agent{Id: req.Msg.GetId()}}), nil
+}
+
+func TestIdentityInterceptor(t *testing.T) {
+	env := newIdentityEnv(t, policy.BundleResponse{})
+	env.addAgent("agent-a", apiv1.AgentStatus_AGENT_STATUS_ACTIVE)
+	receiver := startedClient(t, env.url)
+
+	recorder := &callerRecorder{mockHandler: newMockHandler(), caller: make(chan *dome.Caller, 1)}
+	mux := http.NewServeMux()
+	mux.Handle(agentv1connect.NewAgentRegistryHandler(recorder, connect.WithInterceptors(receiver.IdentityInterceptor(""))))
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	rpc := agentv1connect.NewAgentRegistryClient(http.DefaultClient, server.URL,
+		connect.WithInterceptors(callingClient(t, env, "agent-a").IdentityInterceptor(receiver.AgentID())))
+	if _, err := rpc.GetAgent(context.Background(), connect.NewRequest(&apiv1.GetAgentRequest{Id: "x"})); err != nil {
+		t.Fatalf("GetAgent error: %v", err)
+	}
+	if c := <-recorder.caller; c == nil || c.AgentID != "agent-a" {
+		t.Errorf("caller = %+v, want agent-a", c)
+	}
+
+	req := connect.NewRequest(&apiv1.GetAgentRequest{Id: "x"})
+	req.Header().Set(dome.AgentTokenHeader, "not-a-token")
+	_, err := agentv1connect.NewAgentRegistryClient(http.DefaultClient, server.URL).GetAgent(context.Background(), req)
+	if connect.CodeOf(err) != connect.CodeUnauthenticated {
+		t.Errorf("code = %v, want Unauthenticated", connect.CodeOf(err))
+	}
+}
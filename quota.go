@@ -0,0 +1,157 @@
+package dome
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Dome-Systems/sdk-dome-go/internal/policy"
+)
+
+// QuotaLimit describes a token bucket: Limit requests per Window, with up to
+// Burst requests allowed at once.
+type QuotaLimit struct {
+	Limit  int
+	Window time.Duration
+	Burst  int
+}
+
+// QuotaBackend consumes quota for a key. The default backend is an
+// in-process token bucket per client. Implement QuotaBackend to share quotas
+// across processes (e.g. backed by Redis) and install it with
+// WithQuotaBackend.
+type QuotaBackend interface {
+	// Take consumes one unit of quota for key. It returns false if the
+	// quota is exhausted.
+	Take(ctx context.Context, key string, limit QuotaLimit) (bool, error)
+	// Refund returns one unit taken by Take. It is called when a later
+	// quota of the same check is exhausted, so a denied check consumes
+	// nothing.
+	Refund(ctx context.Context, key string, limit QuotaLimit) error
+}
+
+// QuotaUsage holds the counters for a single quota since the client started.
+type QuotaUsage struct {
+	ID      string
+	Allowed uint64
+	Denied  uint64
+}
+
+// localQuotaBackend is the default in-process QuotaBackend.
+type localQuotaBackend struct {
+	limiter *policy.Limiter
+}
+
+func newLocalQuotaBackend() *localQuotaBackend {
+	return &localQuotaBackend{limiter: policy.NewLimiter()}
+}
+
+func (b *localQuotaBackend) Take(_ context.Context, key string, limit QuotaLimit) (bool, error) {
+	return b.limiter.Take(key, limit.quota()), nil
+}
+
+func (b *localQuotaBackend) Refund(_ context.Context, key string, limit QuotaLimit) error {
+	b.limiter.Refund(key, limit.quota())
+	return nil
+}
+
+func (l QuotaLimit) quota() policy.Quota {
+	return policy.Quota{Limit: l.Limit, Window: l.Window, Burst: l.Burst}
+}
+
+// quotaCounters tracks allowed/denied counts per quota ID.
+type quotaCounters struct {
+	mu    sync.Mutex
+	usage map[string]*QuotaUsage
+}
+
+func (q *quotaCounters) record(id string, allowed bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.usage == nil {
+		q.usage = make(map[string]*QuotaUsage)
+	}
+	u, ok := q.usage[id]
+	if !ok {
+		u = &QuotaUsage{ID: id}
+		q.usage[id] = u
+	}
+	if allowed {
+		u.Allowed++
+	} else {
+		u.Denied++
+	}
+}
+
+func (q *quotaCounters) snapshot() []QuotaUsage {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	out := make([]QuotaUsage, 0, len(q.usage))
+	for _, u := range q.usage {
+		out = append(out, *u)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// QuotaUsage returns the per-quota counters accumulated by Check, sorted by
+// quota ID. The same counters are reported in heartbeat metrics as
+// "quota.<id>.allowed" and "quota.<id>.denied".
+func (c *Client) QuotaUsage() []QuotaUsage {
+	return c.quotaCounters.snapshot()
+}
+
+// enforceQuotas consumes one unit from each quota that applies to an allowed
+// decision. It returns the first exhausted quota, or nil if all quotas have
+// capacity. When a quota is exhausted, the units already taken from the
+// earlier quotas are refunded. Backend errors fail open.
+func (c *Client) enforceQuotas(ctx context.Context, agentID string, quotas []policy.Quota) *policy.Quota {
+	taken := make([]int, 0, len(quotas))
+	for i := range quotas {
+		q := quotas[i]
+		ok, err := c.config.quotaBackend.Take(ctx, quotaKey(q, agentID), quotaLimit(q))
+		if err != nil {
+			c.logger.Warn("dome: quota backend error", "quota_id", q.ID, "error", err)
+			continue
+		}
+		if !ok {
+			for _, j := range taken {
+				prev := quotas[j]
+				if err := c.config.quotaBackend.Refund(ctx, quotaKey(prev, agentID), quotaLimit(prev)); err != nil {
+					c.logger.Warn("dome: quota refund failed", "quota_id", prev.ID, "error", err)
+				}
+			}
+			c.quotaCounters.record(q.ID, false)
+			return &q
+		}
+		taken = append(taken, i)
+	}
+	for _, i := range taken {
+		c.quotaCounters.record(quotas[i].ID, true)
+	}
+	return nil
+}
+
+func quotaKey(q policy.Quota, agentID string) string {
+	return q.ID + "|" + agentID
+}
+
+func quotaLimit(q policy.Quota) QuotaLimit {
+	return QuotaLimit{Limit: q.Limit, Window: q.Window, Burst: q.Burst}
+}
+
+// quotaMetrics renders the quota counters as heartbeat metrics.
+func (c *Client) quotaMetrics() map[string]float64 {
+	usage := c.quotaCounters.snapshot()
+	if len(usage) == 0 {
+		return nil
+	}
+	metrics := make(map[string]float64, 2*len(usage))
+	for _, u := range usage {
+		metrics[fmt.Sprintf("quota.%s.allowed", u.ID)] = float64(u.Allowed)
+		metrics[fmt.Sprintf("quota.%s.denied", u.ID)] = float64(u.Denied)
+	}
+	return metrics
+}
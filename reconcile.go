@@ -0,0 +1,117 @@
+package dome
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"connectrpc.com/connect"
+
+	apiv1 "github.com/Dome-Systems/sdk-dome-go/internal/api"
+)
+
+// DriftPolicy decides what Start does when an agent with the requested name
+// already exists but its stored definition differs from StartOptions.
+type DriftPolicy int
+
+const (
+	// DriftReconcile updates the stored agent to match StartOptions. This
+	// is the default. The control plane cannot remove every capability or
+	// metadata entry, so drift that would need that makes Start return an
+	// *AgentDriftError.
+	DriftReconcile DriftPolicy = iota
+	// DriftWarn logs the differences and keeps the stored agent.
+	DriftWarn
+	// DriftFail makes Start return an *AgentDriftError.
+	DriftFail
+)
+
+// ErrAgentDrift is matched by errors.Is when Start refuses to use an agent
+// whose stored definition differs from StartOptions. Use errors.As with
+// *AgentDriftError for the details.
+var ErrAgentDrift = errors.New("dome: agent definition drift")
+
+// AgentDriftError is returned by Start under DriftFail.
+type AgentDriftError struct {
+	AgentID string
+	Name    string
+	// Fields lists the fields that differ: "capabilities", "metadata".
+	Fields []string
+}
+
+func (e *AgentDriftError) Error() string {
+	return fmt.Sprintf("dome: agent %s (%s) differs from start options: %s", e.Name, e.AgentID, strings.Join(e.Fields, ", "))
+}
+
+// Unwrap returns ErrAgentDrift.
+func (e *AgentDriftError) Unwrap() error { return ErrAgentDrift }
+
+// agentDrift returns the fields of stored that differ from opts. Only the
+// fields set in opts are compared, so an agent registered with the CLI can
+// be started by name alone. Capabilities are compared as a set.
+func agentDrift(stored *apiv1.Agent, opts StartOptions) []string {
+	var fields []string
+	if opts.Capabilities != nil {
+		want := slices.Sorted(slices.Values(opts.Capabilities))
+		got := slices.Sorted(slices.Values(stored.GetCapabilities()))
+		if !slices.Equal(slices.Compact(want), slices.Compact(got)) {
+			fields = append(fields, "capabilities")
+		}
+	}
+	if opts.Metadata != nil && !maps.Equal(opts.Metadata, stored.GetMetadata()) {
+		fields = append(fields, "metadata")
+	}
+	return fields
+}
+
+// reconcileAgent applies the configured DriftPolicy to an existing agent
+// and returns the agent to use.
+func (c *Client) reconcileAgent(ctx context.Context, stored *apiv1.Agent, opts StartOptions) (*apiv1.Agent, error) {
+	fields := agentDrift(stored, opts)
+	if len(fields) == 0 {
+		return stored, nil
+	}
+
+	switch c.config.driftPolicy {
+	case DriftWarn:
+		c.logger.Warn("dome: agent definition differs from start options",
+			"agent_id", stored.GetId(), "agent_name", opts.Name, "fields", fields)
+		return stored, nil
+	case DriftFail:
+		return nil, &AgentDriftError{AgentID: stored.GetId(), Name: opts.Name, Fields: fields}
+	}
+
+	// An empty list or map on the wire means "unchanged".
+	var unclearable []string
+	if slices.Contains(fields, "capabilities") && len(opts.Capabilities) == 0 {
+		unclearable = append(unclearable, "capabilities")
+	}
+	if slices.Contains(fields, "metadata") && len(opts.Metadata) == 0 {
+		unclearable = append(unclearable, "metadata")
+	}
+	if len(unclearable) > 0 {
+		return nil, &AgentDriftError{AgentID: stored.GetId(), Name: opts.Name, Fields: unclearable}
+	}
+
+	// The stored agent has no description to compare, so it is only
+	// updated along with other drift.
+	req := &apiv1.UpdateAgentRequest{Id: stored.GetId()}
+	if opts.Description != "" {
+		req.Description = &opts.Description
+	}
+	if slices.Contains(fields, "capabilities") {
+		req.Capabilities = opts.Capabilities
+	}
+	if slices.Contains(fields, "metadata") {
+		req.Metadata = opts.Metadata
+	}
+	resp, err := c.rpc.UpdateAgent(ctx, connect.NewRequest(req))
+	if err != nil {
+		return nil, errorf("reconcile agent %s: %w", stored.GetId(), err)
+	}
+	c.logger.Info("dome: agent definition reconciled", "agent_id", stored.GetId(), "agent_name", opts.Name, "fields", fields)
+	return resp.Msg.GetAgent(), nil
+}
@@ -0,0 +1,174 @@
+package dome_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"testing"
+
+	dome "github.com/Dome-Systems/sdk-dome-go"
+	apiv1 "github.com/Dome-Systems/sdk-dome-go/internal/api"
+)
+
+// driftServer returns a registry holding an agent "worker" with capability
+// "read", behind more agents than fit in one ListAgents page.
+func driftServer(t *testing.T) (*mockHandler, string) {
+	t.Helper()
+	handler, url := registryServer(t)
+	for i := 0; i < 150; i++ {
+		id := fmt.Sprintf("filler-%03d", i)
+		handler.agents[id] = &apiv1.Agent{Id: id, Name: id, Status: apiv1.AgentStatus_AGENT_STATUS_ACTIVE}
+	}
+	handler.agents["worker-1"] = &apiv1.Agent{
+		Id:           "worker-1",
+		Name:         "worker",
+		Status:       apiv1.AgentStatus_AGENT_STATUS_ACTIVE,
+		Capabilities: []string{"read"},
+		Metadata:     map[string]string{"version": "1"},
+	}
+	return handler, url
+}
+
+func startWorker(t *testing.T, url string, policy dome.DriftPolicy) (*dome.AgentInfo, error) {
+	t.Helper()
+	client, err := dome.NewClient(
+		dome.WithAPIKey("test-key"),
+		dome.WithAPIURL(url),
+		dome.WithoutHeartbeat(),
+		dome.WithDriftPolicy(policy),
+	)
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+	return client.Start(context.Background(), dome.StartOptions{
+		Name:         "worker",
+		Description:  "reads and writes",
+		Capabilities: []string{"write", "read"},
+		Metadata:     map[string]string{"version": "2"},
+	})
+}
+
+func TestStart_ReconcilesDrift(t *testing.T) {
+	handler, url := driftServer(t)
+	info, err := startWorker(t, url, dome.DriftReconcile)
+	if err != nil {
+		t.Fatalf("Start error: %v", err)
+	}
+	if info.ID != "worker-1" {
+		t.Fatalf("ID = %q, want the existing agent", info.ID)
+	}
+	if !slices.Equal(info.Capabilities, []string{"write", "read"}) || info.Metadata["version"] != "2" {
+		t.Errorf("info = %+v, want reconciled capabilities and metadata", info)
+	}
+	stored := handler.agents["worker-1"]
+	if !slices.Equal(stored.Capabilities, []string{"write", "read"}) || stored.Metadata["version"] != "2" {
+		t.Errorf("stored agent = %v %v, want updated", stored.Capabilities, stored.Metadata)
+	}
+	if got := handler.descriptions["worker-1"]; got != "reads and writes" {
+		t.Errorf("description = %q, want it sent with the update", got)
+	}
+}
+
+func TestStart_NoUpdateWithoutDrift(t *testing.T) {
+	handler, url := driftServer(t)
+	client := adminClient(t, url)
+	// Options that leave capabilities and metadata unset do not drift.
+	if _, err := client.Start(context.Background(), dome.StartOptions{Name: "worker"}); err != nil {
+		t.Fatalf("Start error: %v", err)
+	}
+	if len(handler.updates) != 0 {
+		t.Errorf("UpdateAgent called %d times, want 0", len(handler.updates))
+	}
+}
+
+func TestStart_DriftWarn(t *testing.T) {
+	handler, url := driftServer(t)
+	info, err := startWorker(t, url, dome.DriftWarn)
+	if err != nil {
+		t.Fatalf("Start error: %v", err)
+	}
+	if !slices.Equal(info.Capabilities, []string{"read"}) {
+		t.Errorf("Capabilities = %v, want the stored ones", info.Capabilities)
+	}
+	if len(handler.updates) != 0 {
+		t.Errorf("UpdateAgent called %d times, want 0", len(handler.updates))
+	}
+}
+
+func TestStart_DriftFail(t *testing.T) {
+	handler, url := driftServer(t)
+	_, err := startWorker(t, url, dome.DriftFail)
+	var drift *dome.AgentDriftError
+	if !errors.As(err, &drift) || !errors.Is(err, dome.ErrAgentDrift) {
+		t.Fatalf("Start error = %v, want *AgentDriftError", err)
+	}
+	if drift.AgentID != "worker-1" || !slices.Equal(drift.Fields, []string{"capabilities", "metadata"}) {
+		t.Errorf("drift = %+v", drift)
+	}
+	if !maps.Equal(handler.agents["worker-1"].Metadata, map[string]string{"version": "1"}) {
+		t.Error("stored agent changed under DriftFail")
+	}
+}
+
+func TestStart_SendsDescription(t *testing.T) {
+	handler, url := registryServer(t)
+	client := adminClient(t, url)
+	info, err := client.Start(context.Background(), dome.StartOptions{Name: "fresh", Description: "new agent"})
+	if err != nil {
+		t.Fatalf("Start error: %v", err)
+	}
+	if got := handler.descriptions[info.ID]; got != "new agent" {
+		t.Errorf("description = %q, want %q", got, "new agent")
+	}
+}
+
+func TestStart_DriftFailNotDegraded(t *testing.T) {
+	_, url := driftServer(t)
+	client, err := dome.NewClient(
+		dome.WithAPIKey("test-key"),
+		dome.WithAPIURL(url),
+		dome.WithoutHeartbeat(),
+		dome.WithGracefulDegradation(),
+		dome.WithDriftPolicy(dome.DriftFail),
+	)
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+	_, err = client.Start(context.Background(), dome.StartOptions{Name: "worker", Capabilities: []string{"write"}})
+	if !errors.Is(err, dome.ErrAgentDrift) {
+		t.Fatalf("Start error = %v, want ErrAgentDrift", err)
+	}
+	if s := client.State(); s != dome.StateInitialized {
+		t.Errorf("state = %s, want initialized", s)
+	}
+}
+
+func TestStart_DoesNotAdoptChildAgent(t *testing.T) {
+	handler, url := registryServer(t)
+	parent := "planner-1"
+	handler.agents["worker-1"] = &apiv1.Agent{Id: "worker-1", Name: "worker", ParentId: &parent, Status: apiv1.AgentStatus_AGENT_STATUS_ACTIVE}
+
+	_, err := adminClient(t, url).Start(context.Background(), dome.StartOptions{Name: "worker"})
+	if err == nil {
+		t.Fatal("expected error starting a top-level agent named like another agent's child")
+	}
+}
+
+func TestStart_DriftThatWouldClear(t *testing.T) {
+	handler, url := driftServer(t)
+	_, err := adminClient(t, url).Start(context.Background(), dome.StartOptions{
+		Name:         "worker",
+		Capabilities: []string{},
+	})
+	var drift *dome.AgentDriftError
+	if !errors.As(err, &drift) || !slices.Equal(drift.Fields, []string{"capabilities"}) {
+		t.Fatalf("error = %v, want *AgentDriftError for capabilities", err)
+	}
+	if len(handler.updates) != 0 {
+		t.Errorf("UpdateAgent called %d times, want 0", len(handler.updates))
+	}
+}
@@ -101,3 +101,25 @@ func TestRetryWithBackoff_StopsOnCancel(t *testing.T) {
 		t.Errorf("expected context.Canceled, got: %v", err)
 	}
 }
+
+func TestHeartbeatSchedule_SuccessWithoutDeadlineClearsIt(t *testing.T) {
+	now := time.Now()
+	s := heartbeatSchedule{base: time.Minute}
+	s.record(true, now.Add(30*time.Second), now)
+	if s.window != 30*time.Second {
+		t.Fatalf("window = %v, want 30s", s.window)
+	}
+
+	// The server stops sending deadlines: the old one no longer applies.
+	later := now.Add(40 * time.Second)
+	s.record(true, time.Time{}, later)
+	if !s.deadline.IsZero() || s.window != 0 {
+		t.Errorf("deadline = %v, window = %v, want cleared", s.deadline, s.window)
+	}
+	if s.missed(later.Add(time.Minute)) {
+		t.Error("missed a cleared deadline")
+	}
+	if next := s.next(later); next < 54*time.Second {
+		t.Errorf("next = %v, want the configured interval", next)
+	}
+}
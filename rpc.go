@@ -0,0 +1,295 @@
+package dome
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"connectrpc.com/connect"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// ErrorInfoDomain is the domain of the google.rpc.ErrorInfo detail attached
+// to PermissionDenied errors returned by the RPC interceptors.
+const ErrorInfoDomain = "dome.systems"
+
+// DefaultSkipProcedures lists the standard gRPC health and reflection
+// procedures. Pass it as RPCOptions.SkipProcedures to serve them without
+// policy checks.
+var DefaultSkipProcedures = []string{
+	"/grpc.health.v1.Health/Check",
+	"/grpc.health.v1.Health/Watch",
+	"/grpc.reflection.v1.ServerReflection/ServerReflectionInfo",
+	"/grpc.reflection.v1alpha.ServerReflection/ServerReflectionInfo",
+}
+
+// RPCOptions configures policy enforcement for Connect and gRPC server
+// interceptors.
+type RPCOptions struct {
+	// ActionMapper maps a full procedure name ("/pkg.Service/Method") to
+	// the policy action. Defaults to ProcedureAction.
+	ActionMapper func(procedure string) string
+
+	// ResourceField names the request message field used as the resource,
+	// e.g. "document_id" or "document.name" for nested messages. Both proto
+	// and JSON field names are accepted. When empty, or when the field is
+	// unset, the resource is the service name.
+	ResourceField string
+
+	// ResourceType is the resource category passed to Check. Defaults to
+	// "rpc".
+	ResourceType string
+
+	// SkipProcedures lists procedures served without a policy check.
+	SkipProcedures []string
+
+	// HideReasons omits the policy decision reason from error messages
+	// returned to callers. The reason is still logged.
+	HideReasons bool
+}
+
+// ProcedureAction is the default RPCOptions.ActionMapper. It returns the
+// procedure without its leading slash, e.g. "pkg.Service/Method".
+func ProcedureAction(procedure string) string {
+	return strings.TrimPrefix(procedure, "/")
+}
+
+// procedureService returns the service portion of a procedure name.
+func procedureService(procedure string) string {
+	service, _, _ := strings.Cut(strings.TrimPrefix(procedure, "/"), "/")
+	return service
+}
+
+// CheckRPC evaluates the policy decision for an RPC. msg is the request
+// message (nil for stream opens); it is consulted only when
+// opts.ResourceField is set. It is the building block for the Connect and
+// gRPC interceptors.
+func (c *Client) CheckRPC(ctx context.Context, procedure string, msg any, opts RPCOptions) (*Decision, error) {
+	return c.Check(ctx, rpcCheckRequest(procedure, msg, opts))
+}
+
+// RPCDenial describes an RPC denied by EnforceRPC.
+type RPCDenial struct {
+	// QuotaExceeded reports that a quota, rather than a policy, denied the
+	// call.
+	QuotaExceeded bool
+	// Message is the caller-facing error message. It omits the decision
+	// reason when RPCOptions.HideReasons is set.
+	Message string
+	// Info is the google.rpc.ErrorInfo detail to attach to the error.
+	Info *errdetails.ErrorInfo
+}
+
+// EnforceRPC applies RPCOptions to one RPC: skipped procedures pass, denials
+// are logged and returned, and check errors are logged and fail open,
+// matching Middleware. It returns nil if the call may proceed. It is what
+// the Connect and gRPC interceptors call; use it to build interceptors for
+// other RPC frameworks.
+func (c *Client) EnforceRPC(ctx context.Context, procedure string, msg any, opts RPCOptions) *RPCDenial {
+	if slices.Contains(opts.SkipProcedures, procedure) {
+		return nil
+	}
+
+	decision, err := c.CheckRPC(ctx, procedure, msg, opts)
+	if err != nil {
+		c.logger.Warn("dome: policy check error, allowing rpc", "procedure", procedure, "error", err)
+		return nil
+	}
+	if decision.Allowed {
+		return nil
+	}
+
+	c.logger.Warn("dome: rpc denied",
+		"procedure", procedure,
+		"reason", decision.Reason,
+	)
+	return &RPCDenial{
+		QuotaExceeded: decision.QuotaExceeded,
+		Message:       rpcDenialMessage(decision, opts),
+		Info:          rpcErrorInfo(procedure, decision, opts),
+	}
+}
+
+// rpcDenialMessage returns the caller-facing message for a denied decision.
+func rpcDenialMessage(d *Decision, opts RPCOptions) string {
+	if opts.HideReasons {
+		if d.QuotaExceeded {
+			return "quota exceeded"
+		}
+		if d.RequiresApproval {
+			return "approval required"
+		}
+		return "denied by policy"
+	}
+	return d.Reason
+}
+
+// rpcErrorInfo builds the google.rpc.ErrorInfo detail describing a denied
+// decision. The reason field carries the stable error code.
+func rpcErrorInfo(procedure string, d *Decision, opts RPCOptions) *errdetails.ErrorInfo {
+	info := &errdetails.ErrorInfo{
+		Reason: strings.ToUpper(DenialCode(d)),
+		Domain: ErrorInfoDomain,
+		Metadata: map[string]string{
+			"procedure": procedure,
+		},
+	}
+	if d.PolicyVersion != "" {
+		info.Metadata["policy_version"] = d.PolicyVersion
+	}
+	if !opts.HideReasons {
+		info.Metadata["decision_reason"] = d.Reason
+	}
+	return info
+}
+
+func rpcCheckRequest(procedure string, msg any, opts RPCOptions) CheckRequest {
+	mapper := opts.ActionMapper
+	if mapper == nil {
+		mapper = ProcedureAction
+	}
+	resourceType := opts.ResourceType
+	if resourceType == "" {
+		resourceType = "rpc"
+	}
+
+	resource := procedureService(procedure)
+	if opts.ResourceField != "" {
+		if m, ok := msg.(proto.Message); ok {
+			if v, ok := messageField(m.ProtoReflect(), opts.ResourceField); ok && v != "" {
+				resource = v
+			}
+		}
+	}
+
+	return CheckRequest{
+		Action:       mapper(procedure),
+		Resource:     resource,
+		ResourceType: resourceType,
+		Context: map[string]string{
+			"rpc.procedure": procedure,
+		},
+	}
+}
+
+// messageField resolves a dotted field path on a message and renders the
+// value as a string. Only singular scalar, enum and message-traversal
+// fields are supported.
+func messageField(m protoreflect.Message, path string) (string, bool) {
+	names := strings.Split(path, ".")
+	for i, name := range names {
+		fields := m.Descriptor().Fields()
+		fd := fields.ByName(protoreflect.Name(name))
+		if fd == nil {
+			fd = fields.ByJSONName(name)
+		}
+		if fd == nil || fd.IsList() || fd.IsMap() || !m.Has(fd) {
+			return "", false
+		}
+
+		v := m.Get(fd)
+		if i < len(names)-1 {
+			if fd.Kind() != protoreflect.MessageKind && fd.Kind() != protoreflect.GroupKind {
+				return "", false
+			}
+			m = v.Message()
+			continue
+		}
+
+		switch fd.Kind() {
+		case protoreflect.MessageKind, protoreflect.GroupKind, protoreflect.BytesKind:
+			return "", false
+		case protoreflect.EnumKind:
+			if ev := fd.Enum().Values().ByNumber(v.Enum()); ev != nil {
+				return string(ev.Name()), true
+			}
+			return fmt.Sprint(v.Enum()), true
+		default:
+			return v.String(), true
+		}
+	}
+	return "", false
+}
+
+// Interceptor returns a connect.Interceptor that enforces policy on every
+// unary and streaming RPC served by a Connect handler, including calls made
+// with the gRPC and gRPC-Web protocols. Denied calls fail with
+// connect.CodePermissionDenied (connect.CodeResourceExhausted when a quota
+// is exhausted) and a google.rpc.ErrorInfo detail.
+//
+// For streams, the stream open is checked when opts.ResourceField is empty;
+// otherwise each received message is checked against its own resource.
+func (c *Client) Interceptor(opts RPCOptions) connect.Interceptor {
+	return &rpcInterceptor{client: c, opts: opts}
+}
+
+type rpcInterceptor struct {
+	client *Client
+	opts   RPCOptions
+}
+
+func (i *rpcInterceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
+	return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		if req.Spec().IsClient {
+			return next(ctx, req)
+		}
+		procedure := req.Spec().Procedure
+		if err := i.check(ctx, procedure, req.Any()); err != nil {
+			return nil, err
+		}
+		return next(ctx, req)
+	}
+}
+
+func (i *rpcInterceptor) WrapStreamingClient(next connect.StreamingClientFunc) connect.StreamingClientFunc {
+	return next
+}
+
+func (i *rpcInterceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+	return func(ctx context.Context, conn connect.StreamingHandlerConn) error {
+		procedure := conn.Spec().Procedure
+		if i.opts.ResourceField == "" {
+			if err := i.check(ctx, procedure, nil); err != nil {
+				return err
+			}
+			return next(ctx, conn)
+		}
+		return next(ctx, &checkedHandlerConn{StreamingHandlerConn: conn, ctx: ctx, interceptor: i})
+	}
+}
+
+// check evaluates policy for one RPC and converts a denial to a Connect
+// error.
+func (i *rpcInterceptor) check(ctx context.Context, procedure string, msg any) error {
+	denial := i.client.EnforceRPC(ctx, procedure, msg, i.opts)
+	if denial == nil {
+		return nil
+	}
+	code := connect.CodePermissionDenied
+	if denial.QuotaExceeded {
+		code = connect.CodeResourceExhausted
+	}
+	connectErr := connect.NewError(code, errors.New(denial.Message))
+	if detail, detailErr := connect.NewErrorDetail(denial.Info); detailErr == nil {
+		connectErr.AddDetail(detail)
+	}
+	return connectErr
+}
+
+// checkedHandlerConn checks each message received on a stream.
+type checkedHandlerConn struct {
+	connect.StreamingHandlerConn
+	ctx         context.Context
+	interceptor *rpcInterceptor
+}
+
+func (c *checkedHandlerConn) Receive(msg any) error {
+	if err := c.StreamingHandlerConn.Receive(msg); err != nil {
+		return err
+	}
+	return c.interceptor.check(c.ctx, c.Spec().Procedure, msg)
+}
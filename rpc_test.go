@@ -0,0 +1,106 @@
+package dome_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"connectrpc.com/connect"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+
+	dome "github.com/Dome-Systems/sdk-dome-go"
+	apiv1 "github.com/Dome-Systems/sdk-dome-go/internal/api"
+	"github.com/Dome-Systems/sdk-dome-go/internal/api/agentv1connect"
+	"github.com/Dome-Systems/sdk-dome-go/internal/policy"
+)
+
+const rpcCedar = `
+@id("get-public-agent")
+permit(
+    principal is Dome::Agent,
+    action == Dome::Action::"dome.agent.v1.AgentRegistry/GetAgent",
+    resource == Dome::Resource::"agent-public"
+);
+`
+
+func TestInterceptor(t *testing.T) {
+	serverURL := testServerWithBundle(t, policy.BundleResponse{
+		Version:  "v7",
+		Policies: []policy.PolicyFile{{Filename: "rpc.cedar", Content: rpcCedar}},
+	})
+	client := startedClient(t, serverURL)
+
+	registry := newMockHandler()
+	registry.agents["agent-public"] = &apiv1.Agent{Id: "agent-public"}
+	registry.agents["agent-secret"] = &apiv1.Agent{Id: "agent-secret"}
+
+	path, h := agentv1connect.NewAgentRegistryHandler(registry,
+		connect.WithInterceptors(client.Interceptor(dome.RPCOptions{ResourceField: "id"})),
+	)
+	mux := http.NewServeMux()
+	mux.Handle(path, h)
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	rpc := agentv1connect.NewAgentRegistryClient(http.DefaultClient, server.URL, connect.WithGRPC())
+	ctx := context.Background()
+
+	if _, err := rpc.GetAgent(ctx, connect.NewRequest(&apiv1.GetAgentRequest{Id: "agent-public"})); err != nil {
+		t.Fatalf("permitted GetAgent error: %v", err)
+	}
+
+	_, err := rpc.GetAgent(ctx, connect.NewRequest(&apiv1.GetAgentRequest{Id: "agent-secret"}))
+	if connect.CodeOf(err) != connect.CodePermissionDenied {
+		t.Fatalf("code = %v, want PermissionDenied (err %v)", connect.CodeOf(err), err)
+	}
+
+	var connectErr *connect.Error
+	if !errors.As(err, &connectErr) || len(connectErr.Details()) != 1 {
+		t.Fatalf("expected one error detail, got %v", err)
+	}
+	detail, err := connectErr.Details()[0].Value()
+	if err != nil {
+		t.Fatalf("decode detail: %v", err)
+	}
+	info, ok := detail.(*errdetails.ErrorInfo)
+	if !ok {
+		t.Fatalf("detail = %T, want *errdetails.ErrorInfo", detail)
+	}
+	if info.GetReason() != "POLICY_DENIED" || info.GetDomain() != dome.ErrorInfoDomain {
+		t.Errorf("ErrorInfo = %+v", info)
+	}
+	if info.GetMetadata()["procedure"] != agentv1connect.AgentRegistryGetAgentProcedure ||
+		info.GetMetadata()["policy_version"] != "v7" {
+		t.Errorf("ErrorInfo metadata = %v", info.GetMetadata())
+	}
+
+	_, err = rpc.ListAgents(ctx, connect.NewRequest(&apiv1.ListAgentsRequest{}))
+	if connect.CodeOf(err) != connect.CodePermissionDenied {
+		t.Errorf("ListAgents code = %v, want PermissionDenied", connect.CodeOf(err))
+	}
+}
+
+func TestInterceptor_SkipProcedures(t *testing.T) {
+	serverURL := testServerWithBundle(t, policy.BundleResponse{
+		Version:  "v1",
+		Policies: []policy.PolicyFile{{Filename: "rpc.cedar", Content: rpcCedar}},
+	})
+	client := startedClient(t, serverURL)
+
+	path, h := agentv1connect.NewAgentRegistryHandler(newMockHandler(),
+		connect.WithInterceptors(client.Interceptor(dome.RPCOptions{
+			SkipProcedures: []string{agentv1connect.AgentRegistryListAgentsProcedure},
+		})),
+	)
+	mux := http.NewServeMux()
+	mux.Handle(path, h)
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	rpc := agentv1connect.NewAgentRegistryClient(http.DefaultClient, server.URL)
+	if _, err := rpc.ListAgents(context.Background(), connect.NewRequest(&apiv1.ListAgentsRequest{})); err != nil {
+		t.Errorf("skipped ListAgents error: %v", err)
+	}
+}
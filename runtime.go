@@ -0,0 +1,78 @@
+package dome
+
+import (
+	"maps"
+	"strconv"
+
+	apiv1 "github.com/Dome-Systems/sdk-dome-go/internal/api"
+	"github.com/Dome-Systems/sdk-dome-go/internal/runtimeenv"
+)
+
+// Runtime provider names understood by the helpers in this package. The
+// control plane may support others.
+const (
+	RuntimeKubernetes = runtimeenv.Kubernetes
+	RuntimeContainer  = runtimeenv.Container
+	RuntimeProcess    = runtimeenv.Process
+)
+
+// RuntimeConfig describes where an agent runs. Provider must name a runtime
+// provider registered with the control plane, which validates Config.
+type RuntimeConfig struct {
+	Provider string
+	Config   map[string]string
+}
+
+// KubernetesRuntime describes an agent running in a Kubernetes pod.
+func KubernetesRuntime(namespace, pod, serviceAccount string) *RuntimeConfig {
+	return &RuntimeConfig{Provider: RuntimeKubernetes, Config: nonEmpty(map[string]string{
+		"namespace":       namespace,
+		"pod":             pod,
+		"service_account": serviceAccount,
+	})}
+}
+
+// ContainerRuntime describes an agent running in a container.
+func ContainerRuntime(containerID, image string) *RuntimeConfig {
+	return &RuntimeConfig{Provider: RuntimeContainer, Config: nonEmpty(map[string]string{
+		"container_id": containerID,
+		"image":        image,
+	})}
+}
+
+// ProcessRuntime describes an agent running as a plain process.
+func ProcessRuntime(hostname string, pid int) *RuntimeConfig {
+	return &RuntimeConfig{Provider: RuntimeProcess, Config: nonEmpty(map[string]string{
+		"hostname": hostname,
+		"pid":      strconv.Itoa(pid),
+	})}
+}
+
+// DetectRuntime describes the current process: a Kubernetes pod when
+// KUBERNETES_SERVICE_HOST is set, a container when container markers or a
+// container cgroup are present, and a plain process otherwise. Start uses
+// it when StartOptions.Runtime is nil, unless WithoutRuntimeDetection was
+// used.
+func DetectRuntime() *RuntimeConfig {
+	provider, config := runtimeenv.Detect(runtimeenv.OS())
+	return &RuntimeConfig{Provider: provider, Config: config}
+}
+
+func nonEmpty(m map[string]string) map[string]string {
+	maps.DeleteFunc(m, func(_, v string) bool { return v == "" })
+	return m
+}
+
+func runtimeToProto(r *RuntimeConfig) *apiv1.RuntimeConfig {
+	if r == nil {
+		return nil
+	}
+	return &apiv1.RuntimeConfig{Provider: r.Provider, Config: r.Config}
+}
+
+func runtimeFromProto(r *apiv1.RuntimeConfig) *RuntimeConfig {
+	if r == nil {
+		return nil
+	}
+	return &RuntimeConfig{Provider: r.GetProvider(), Config: r.GetConfig()}
+}
@@ -0,0 +1,59 @@
+package dome_test
+
+import (
+	"context"
+	"testing"
+
+	dome "github.com/Dome-Systems/sdk-dome-go"
+)
+
+func TestStart_Runtime(t *testing.T) {
+	handler, url := registryServer(t)
+	client := adminClient(t, url)
+	ctx := context.Background()
+
+	info, err := client.Start(ctx, dome.StartOptions{
+		Name:    "pod-agent",
+		Runtime: dome.KubernetesRuntime("agents", "pod-agent-0", ""),
+	})
+	if err != nil {
+		t.Fatalf("Start error: %v", err)
+	}
+	if info.Runtime == nil || info.Runtime.Provider != dome.RuntimeKubernetes || info.Runtime.Config["pod"] != "pod-agent-0" {
+		t.Fatalf("Runtime = %+v, want the kubernetes runtime", info.Runtime)
+	}
+	if _, ok := info.Runtime.Config["service_account"]; ok {
+		t.Error("empty service_account included in runtime config")
+	}
+
+	// Without an explicit runtime, Start describes the current process.
+	info, err = client.Start(ctx, dome.StartOptions{Name: "detected-agent"})
+	if err != nil {
+		t.Fatalf("Start error: %v", err)
+	}
+	if got := handler.agents[info.ID].GetRuntime().GetProvider(); got != dome.DetectRuntime().Provider {
+		t.Errorf("stored provider = %q, want detected %q", got, dome.DetectRuntime().Provider)
+	}
+}
+
+func TestStart_WithoutRuntimeDetection(t *testing.T) {
+	handler, url := registryServer(t)
+	client, err := dome.NewClient(
+		dome.WithAPIKey("test-key"),
+		dome.WithAPIURL(url),
+		dome.WithoutHeartbeat(),
+		dome.WithoutRuntimeDetection(),
+	)
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	info, err := client.Start(context.Background(), dome.StartOptions{Name: "plain-agent"})
+	if err != nil {
+		t.Fatalf("Start error: %v", err)
+	}
+	if info.Runtime != nil || handler.agents[info.ID].GetRuntime() != nil {
+		t.Errorf("Runtime = %+v, want none", info.Runtime)
+	}
+}
@@ -0,0 +1,375 @@
+package dome
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Dome-Systems/sdk-dome-go/internal/policy"
+	"github.com/Dome-Systems/sdk-dome-go/internal/secrets"
+)
+
+const (
+	// credentialStaticTTL is how long a credential without a lease is
+	// served from cache before it is fetched again.
+	credentialStaticTTL = 5 * time.Minute
+
+	// credentialRenewTimeout bounds a background lease renewal.
+	credentialRenewTimeout = 30 * time.Second
+)
+
+// ErrCredentialDenied is matched by errors.Is when policy denies access to
+// a credential. Use errors.As with *CredentialDeniedError for the decision.
+var ErrCredentialDenied = errors.New("dome: credential access denied by policy")
+
+// CredentialDeniedError is returned by FetchCredential and RevokeCredential
+// when policy denies the operation.
+type CredentialDeniedError struct {
+	Name     string
+	Action   string
+	Decision *Decision
+}
+
+func (e *CredentialDeniedError) Error() string {
+	return fmt.Sprintf("dome: %s denied for %s: %s", e.Action, e.Name, e.Decision.Reason)
+}
+
+// Unwrap returns ErrCredentialDenied.
+func (e *CredentialDeniedError) Unwrap() error { return ErrCredentialDenied }
+
+// Credential is a secret obtained through Dome.
+type Credential struct {
+	Name string
+	// Value is the "value" field of the secret, or its only field.
+	Value string
+	// Data holds every field of the secret.
+	Data map[string]string
+	// LeaseID identifies the lease; empty for static secrets.
+	LeaseID string
+	// ExpiresAt is when the lease expires, or zero if it does not.
+	ExpiresAt time.Time
+	// Renewable reports whether the SDK renews the lease in the background.
+	Renewable bool
+}
+
+// credentialCache holds fetched credentials and their renewal timers.
+type credentialCache struct {
+	mu      sync.Mutex
+	entries map[string]*credentialEntry
+	closed  bool
+}
+
+type credentialEntry struct {
+	mu        sync.Mutex // serializes fetch, renewal and revocation
+	secret    *secrets.Secret
+	cred      *Credential
+	refreshAt time.Time
+	timer     *time.Timer
+	closed    bool // set by stop; nothing more is cached
+}
+
+// entry returns the cache entry for name, or nil once the cache is stopped.
+func (cc *credentialCache) entry(name string) *credentialEntry {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	if cc.closed {
+		return nil
+	}
+	if cc.entries == nil {
+		cc.entries = make(map[string]*credentialEntry)
+	}
+	e, ok := cc.entries[name]
+	if !ok {
+		e = &credentialEntry{}
+		cc.entries[name] = e
+	}
+	return e
+}
+
+// stop cancels every pending renewal, empties the cache and returns the
+// cached secrets that hold a lease. Later fetches are refused.
+func (cc *credentialCache) stop() []*secrets.Secret {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	cc.closed = true
+	var leased []*secrets.Secret
+	for _, e := range cc.entries {
+		e.mu.Lock()
+		e.closed = true
+		if e.timer != nil {
+			e.timer.Stop()
+			e.timer = nil
+		}
+		if e.secret != nil && e.secret.LeaseID != "" {
+			leased = append(leased, e.secret)
+		}
+		e.secret, e.cred = nil, nil
+		e.mu.Unlock()
+	}
+	return leased
+}
+
+// revokeLeases revokes leases the client no longer uses, logging failures.
+func (c *Client) revokeLeases(ctx context.Context, leased ...*secrets.Secret) {
+	if len(leased) == 0 {
+		return
+	}
+	source, err := c.secretSource()
+	if err != nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), credentialRenewTimeout)
+	defer cancel()
+	for _, secret := range leased {
+		if err := source.Revoke(ctx, secret); err != nil {
+			c.logger.Warn("dome: credential lease revocation failed", "lease_id", secret.LeaseID, "error", err)
+			continue
+		}
+		c.logger.Debug("dome: credential lease revoked", "lease_id", secret.LeaseID)
+	}
+}
+
+// secretSource returns the configured credential source, creating it on
+// first use.
+func (c *Client) secretSource() (secrets.Source, error) {
+	c.sourceOnce.Do(func() {
+		if c.config.vaultSecretsPrefix == "" {
+			c.source = secrets.NewAPISource(c.httpClient, c.config.apiURL)
+			return
+		}
+		if c.vaultAuth == nil {
+			c.sourceErr = errorf("WithVaultSecrets requires Vault credentials (WithCredentials with a vault_addr)")
+			return
+		}
+		c.source = secrets.NewVaultSource(nil, c.vaultAuth.Addr(), c.config.vaultSecretsPrefix, c.vaultAuth.VaultToken)
+	})
+	return c.source, c.sourceErr
+}
+
+// checkCredential evaluates action on the named credential.
+func (c *Client) checkCredential(ctx context.Context, action, name string) error {
+	decision, err := c.Check(ctx, CheckRequest{
+		Action:       action,
+		Resource:     name,
+		ResourceType: "credential",
+	})
+	if err != nil {
+		return err
+	}
+	if !decision.Allowed {
+		c.logger.Warn("dome: credential access denied", "action", action, "credential", name, "reason", decision.Reason)
+		go c.reportEventData(context.Background(), c.AgentID(), "credential.denied", map[string]any{
+			"action":     action,
+			"credential": name,
+			"reason":     decision.Reason,
+		})
+		return &CredentialDeniedError{Name: name, Action: action, Decision: decision}
+	}
+	return nil
+}
+
+// FetchCredential returns the named secret after checking "credential:fetch"
+// on it (a Dome::Credential) against policy. Denied requests return a
+// *CredentialDeniedError.
+//
+// Secrets come from the control plane, or from Vault when configured with
+// WithVaultSecrets. They are cached: leased secrets until two thirds of the
+// lease has elapsed, static secrets for five minutes. Renewable leases are
+// renewed in the background until RevokeCredential or Close, so a cached
+// credential stays valid. Policy is evaluated on every call, including
+// cache hits.
+//
+// Fetches from the source are reported to the control plane as
+// "credential.fetched" events; secret values are never reported. When a
+// cached secret is fetched again, its previous lease is revoked.
+//
+// Names are slash-separated paths; empty, "." and ".." segments are
+// rejected so a name cannot resolve to a different secret than the one
+// policy was checked against.
+//
+// FetchCredential fails once the client is closed.
+func (c *Client) FetchCredential(ctx context.Context, name string) (*Credential, error) {
+	if err := secrets.ValidateName(name); err != nil {
+		return nil, errorf("%w", err)
+	}
+	select {
+	case <-c.closed:
+		return nil, errorf("fetch credential %s: client closed", name)
+	default:
+	}
+	if err := c.checkCredential(ctx, policy.ActionCredentialFetch, name); err != nil {
+		return nil, err
+	}
+	source, err := c.secretSource()
+	if err != nil {
+		return nil, err
+	}
+
+	e := c.credentials.entry(name)
+	if e == nil {
+		return nil, errorf("fetch credential %s: client closed", name)
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.closed {
+		// Close ran while this call was in flight.
+		return nil, errorf("fetch credential %s: client closed", name)
+	}
+
+	now := time.Now()
+	if e.cred != nil && now.Before(e.refreshAt) {
+		return e.cred.clone(), nil
+	}
+
+	secret, err := source.Fetch(ctx, name)
+	if err != nil {
+		if errors.Is(err, secrets.ErrNotFound) {
+			return nil, errorf("credential %s not found", name)
+		}
+		return nil, errorf("%w", err)
+	}
+	if e.timer != nil {
+		e.timer.Stop()
+		e.timer = nil
+	}
+	if prev := e.secret; prev != nil && prev.LeaseID != "" && prev.LeaseID != secret.LeaseID {
+		c.revokeLeases(ctx, prev)
+	}
+	c.storeCredential(name, e, secret, now)
+
+	c.logger.Debug("dome: credential fetched", "credential", name, "lease_id", secret.LeaseID)
+	go c.reportEventData(context.Background(), c.AgentID(), "credential.fetched", map[string]any{
+		"credential":     name,
+		"lease_id":       secret.LeaseID,
+		"lease_duration": secret.LeaseDuration.Seconds(),
+	})
+	return e.cred.clone(), nil
+}
+
+// storeCredential caches secret in e and schedules renewal of a renewable
+// lease. The caller holds e.mu.
+func (c *Client) storeCredential(name string, e *credentialEntry, secret *secrets.Secret, now time.Time) {
+	cred := &Credential{
+		Name:      name,
+		Data:      secret.Data,
+		LeaseID:   secret.LeaseID,
+		Renewable: secret.Renewable,
+	}
+	if v, ok := secret.Data["value"]; ok {
+		cred.Value = v
+	} else if len(secret.Data) == 1 {
+		for _, v := range secret.Data {
+			cred.Value = v
+		}
+	}
+
+	e.secret = secret
+	e.cred = cred
+	if secret.LeaseDuration <= 0 {
+		e.refreshAt = now.Add(credentialStaticTTL)
+		return
+	}
+	cred.ExpiresAt = now.Add(secret.LeaseDuration)
+	e.refreshAt = now.Add(secret.LeaseDuration * 2 / 3)
+	if secret.Renewable && secret.LeaseID != "" {
+		e.timer = time.AfterFunc(e.refreshAt.Sub(now), func() { c.renewCredential(name, e) })
+	}
+}
+
+// renewCredential renews a cached lease. On failure the entry is marked
+// stale so the next FetchCredential obtains a new secret.
+func (c *Client) renewCredential(name string, e *credentialEntry) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.secret == nil || e.timer == nil || time.Now().Before(e.refreshAt) {
+		// Revoked, stopped or refetched since the timer was set.
+		return
+	}
+	e.timer = nil
+
+	source, err := c.secretSource()
+	if err != nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), credentialRenewTimeout)
+	defer cancel()
+
+	renewed, err := source.Renew(ctx, e.secret)
+	if err != nil {
+		c.logger.Warn("dome: credential lease renewal failed", "credential", name, "error", err)
+		e.refreshAt = time.Now()
+		return
+	}
+	c.logger.Debug("dome: credential lease renewed", "credential", name, "lease_id", renewed.LeaseID)
+	c.storeCredential(name, e, renewed, time.Now())
+}
+
+// RevokeCredential revokes the lease of a previously fetched credential,
+// after checking "credential:revoke" against policy, and removes it from the
+// cache. The revocation is reported as a "credential.revoked" event.
+// Revoking a credential that is not cached is a no-op.
+func (c *Client) RevokeCredential(ctx context.Context, name string) error {
+	if err := secrets.ValidateName(name); err != nil {
+		return errorf("%w", err)
+	}
+	if err := c.checkCredential(ctx, policy.ActionCredentialRevoke, name); err != nil {
+		return err
+	}
+
+	e := c.credentials.entry(name)
+	if e == nil {
+		return nil
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.secret == nil {
+		return nil
+	}
+	if e.timer != nil {
+		e.timer.Stop()
+		e.timer = nil
+	}
+
+	leaseID := e.secret.LeaseID
+	if leaseID != "" {
+		source, err := c.secretSource()
+		if err != nil {
+			return err
+		}
+		if err := source.Revoke(ctx, e.secret); err != nil {
+			return errorf("%w", err)
+		}
+	}
+	e.secret, e.cred = nil, nil
+
+	go c.reportEventData(context.Background(), c.AgentID(), "credential.revoked", map[string]any{
+		"credential": name,
+		"lease_id":   leaseID,
+	})
+	return nil
+}
+
+func (cred *Credential) clone() *Credential {
+	out := *cred
+	out.Data = make(map[string]string, len(cred.Data))
+	for k, v := range cred.Data {
+		out.Data[k] = v
+	}
+	return &out
+}
+
+// String redacts the secret so credentials can be logged safely.
+func (cred *Credential) String() string {
+	var b strings.Builder
+	b.WriteString("Credential{Name: ")
+	b.WriteString(cred.Name)
+	if cred.LeaseID != "" {
+		b.WriteString(", LeaseID: ")
+		b.WriteString(cred.LeaseID)
+	}
+	b.WriteString(", Value: [REDACTED]}")
+	return b.String()
+}
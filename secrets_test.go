@@ -0,0 +1,230 @@
+package dome_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	dome "github.com/Dome-Systems/sdk-dome-go"
+	"github.com/Dome-Systems/sdk-dome-go/internal/api/agentv1connect"
+	"github.com/Dome-Systems/sdk-dome-go/internal/policy"
+)
+
+const credentialCedar = `
+@id("billing-db")
+permit(
+    principal is Dome::Agent,
+    action in [Dome::Action::"credential:fetch", Dome::Action::"credential:revoke"],
+    resource == Dome::Credential::"billing/db"
+);
+`
+
+// credentialEnv is a control plane stand-in serving the registry, a policy
+// bundle and the credential API.
+type credentialEnv struct {
+	url       string
+	handler   *mockHandler
+	fetches   atomic.Int32
+	renewals  atomic.Int32
+	renewable atomic.Bool
+
+	mu      sync.Mutex
+	revoked []string // lease IDs
+}
+
+func newCredentialEnv(t *testing.T, leaseSeconds int) *credentialEnv {
+	t.Helper()
+	env := &credentialEnv{handler: newMockHandler()}
+	env.renewable.Store(true)
+
+	mux := http.NewServeMux()
+	path, h := agentv1connect.NewAgentRegistryHandler(env.handler)
+	mux.Handle(path, h)
+	mux.HandleFunc("/api/v1/policies/bundle", func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(policy.BundleResponse{
+			Version:  "v1",
+			Policies: []policy.PolicyFile{{Filename: "creds.cedar", Content: credentialCedar}},
+		})
+	})
+	mux.HandleFunc("GET /api/v1/credentials/billing/db", func(w http.ResponseWriter, _ *http.Request) {
+		n := env.fetches.Add(1)
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"lease_id":       fmt.Sprintf("lease-%d", n),
+			"lease_duration": leaseSeconds,
+			"renewable":      env.renewable.Load(),
+			"data":           map[string]any{"username": "billing", "value": "s3cret"},
+		})
+	})
+	mux.HandleFunc("POST /api/v1/credentials/leases/renew", func(w http.ResponseWriter, r *http.Request) {
+		env.renewals.Add(1)
+		var body struct {
+			LeaseID string `json:"lease_id"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		_ = json.NewEncoder(w).Encode(map[string]any{"lease_id": body.LeaseID, "lease_duration": leaseSeconds, "renewable": true})
+	})
+	mux.HandleFunc("POST /api/v1/credentials/leases/revoke", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			LeaseID string `json:"lease_id"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		env.mu.Lock()
+		env.revoked = append(env.revoked, body.LeaseID)
+		env.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	env.url = server.URL
+	return env
+}
+
+func TestFetchCredential(t *testing.T) {
+	env := newCredentialEnv(t, 3600)
+	client := startedClient(t, env.url)
+	ctx := context.Background()
+
+	cred, err := client.FetchCredential(ctx, "billing/db")
+	if err != nil {
+		t.Fatalf("FetchCredential error: %v", err)
+	}
+	if cred.Value != "s3cret" || cred.Data["username"] != "billing" || cred.LeaseID != "lease-1" {
+		t.Errorf("credential = %+v", cred)
+	}
+	if until := time.Until(cred.ExpiresAt); until < 59*time.Minute || until > time.Hour {
+		t.Errorf("ExpiresAt in %v, want about 1h", until)
+	}
+
+	// A second fetch is served from cache.
+	if _, err := client.FetchCredential(ctx, "billing/db"); err != nil {
+		t.Fatalf("cached FetchCredential error: %v", err)
+	}
+	if n := env.fetches.Load(); n != 1 {
+		t.Errorf("source fetches = %d, want 1", n)
+	}
+
+	_, err = client.FetchCredential(ctx, "payroll/db")
+	var denied *dome.CredentialDeniedError
+	if !errors.As(err, &denied) || !errors.Is(err, dome.ErrCredentialDenied) {
+		t.Fatalf("error = %v, want *CredentialDeniedError", err)
+	}
+
+	if err := client.RevokeCredential(ctx, "billing/db"); err != nil {
+		t.Fatalf("RevokeCredential error: %v", err)
+	}
+	if got := env.revokedLeases(); !slices.Equal(got, []string{"lease-1"}) {
+		t.Errorf("revoked leases = %v, want [lease-1]", got)
+	}
+
+	// After revocation the credential is fetched anew.
+	if _, err := client.FetchCredential(ctx, "billing/db"); err != nil {
+		t.Fatalf("FetchCredential after revoke error: %v", err)
+	}
+	if n := env.fetches.Load(); n != 2 {
+		t.Errorf("source fetches = %d, want 2", n)
+	}
+
+	waitFor(t, func() bool {
+		events := env.handler.eventTypes()
+		return slices.Contains(events, "credential.fetched") &&
+			slices.Contains(events, "credential.revoked") &&
+			slices.Contains(events, "credential.denied")
+	})
+}
+
+func (env *credentialEnv) revokedLeases() []string {
+	env.mu.Lock()
+	defer env.mu.Unlock()
+	return slices.Clone(env.revoked)
+}
+
+func TestFetchCredential_RevokesUnusedLeases(t *testing.T) {
+	env := newCredentialEnv(t, 1)
+	env.renewable.Store(false)
+	client := startedClient(t, env.url)
+	ctx := context.Background()
+
+	if _, err := client.FetchCredential(ctx, "billing/db"); err != nil {
+		t.Fatalf("FetchCredential error: %v", err)
+	}
+	// Once the cached lease is due for refresh, fetching again replaces it
+	// and revokes the old lease.
+	time.Sleep(700 * time.Millisecond)
+	cred, err := client.FetchCredential(ctx, "billing/db")
+	if err != nil {
+		t.Fatalf("FetchCredential error: %v", err)
+	}
+	if cred.LeaseID != "lease-2" {
+		t.Errorf("LeaseID = %q, want lease-2", cred.LeaseID)
+	}
+	if got := env.revokedLeases(); !slices.Equal(got, []string{"lease-1"}) {
+		t.Errorf("revoked leases = %v, want [lease-1]", got)
+	}
+
+	// Close revokes the lease still in use.
+	_ = client.Close()
+	if got := env.revokedLeases(); !slices.Equal(got, []string{"lease-1", "lease-2"}) {
+		t.Errorf("revoked leases after Close = %v, want [lease-1 lease-2]", got)
+	}
+}
+
+func TestFetchCredential_InvalidName(t *testing.T) {
+	env := newCredentialEnv(t, 3600)
+	client := startedClient(t, env.url)
+
+	for _, name := range []string{"", "billing/db/../../payroll/db", "billing//db", "./billing/db"} {
+		if _, err := client.FetchCredential(context.Background(), name); err == nil {
+			t.Errorf("FetchCredential(%q) expected error", name)
+		}
+	}
+	if n := env.fetches.Load(); n != 0 {
+		t.Errorf("source fetches = %d, want 0", n)
+	}
+}
+
+func TestFetchCredential_RenewsLease(t *testing.T) {
+	env := newCredentialEnv(t, 1)
+	client := startedClient(t, env.url)
+
+	if _, err := client.FetchCredential(context.Background(), "billing/db"); err != nil {
+		t.Fatalf("FetchCredential error: %v", err)
+	}
+	waitFor(t, func() bool { return env.renewals.Load() >= 1 })
+	if n := env.fetches.Load(); n != 1 {
+		t.Errorf("source fetches = %d, want 1 (renewed, not refetched)", n)
+	}
+}
+
+// waitFor polls cond until it holds or two seconds pass.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met within 2s")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestFetchCredential_AfterClose(t *testing.T) {
+	env := newCredentialEnv(t, 3600)
+	client := startedClient(t, env.url)
+	_ = client.Close()
+
+	if _, err := client.FetchCredential(context.Background(), "billing/db"); err == nil {
+		t.Fatal("FetchCredential after Close expected error")
+	}
+	if n := env.fetches.Load(); n != 0 {
+		t.Errorf("source fetches = %d, want 0", n)
+	}
+}
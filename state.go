@@ -0,0 +1,167 @@
+package dome
+
+import (
+	"time"
+)
+
+// State is the lifecycle state of a Client.
+type State string
+
+// Client lifecycle states.
+const (
+	// StateInitialized: NewClient returned; Start has not been called or
+	// registration failed.
+	StateInitialized State = "initialized"
+	// StateRegistering: Start is announcing the agent.
+	StateRegistering State = "registering"
+	// StateActive: the agent is registered and the control plane reachable.
+	StateActive State = "active"
+	// StateDegraded: registration or heartbeats are failing and being
+	// retried. Check keeps using the cached policy bundle.
+	StateDegraded State = "degraded"
+	// StateStale: heartbeats kept failing past the server's heartbeat
+	// deadline, so the control plane considers the agent stale.
+	StateStale State = "stale"
+	// StateSuspended: the agent was suspended in the control plane. Check
+	// denies everything until it is reactivated.
+	StateSuspended State = "suspended"
+	// StateRevoked: the agent was revoked in the control plane. Check
+	// denies everything.
+	StateRevoked State = "revoked"
+	// StateStopping: Close is running.
+	StateStopping State = "stopping"
+	// StateStopped: Close has completed.
+	StateStopped State = "stopped"
+)
+
+// StateChange describes a transition between lifecycle states.
+type StateChange struct {
+	From   State
+	To     State
+	Reason string
+	// Err is the error that caused the transition, if any.
+	Err  error
+	Time time.Time
+}
+
+// State returns the client's current lifecycle state.
+func (c *Client) State() State {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	return c.state
+}
+
+// OnStateChange registers fn to be called on every lifecycle transition and
+// returns a function that unregisters it. Watchers see transitions one at a
+// time, in the order they happened. fn runs on a goroutine causing a
+// transition, often the heartbeat goroutine; it must not block or call
+// Close.
+//
+//	client.OnStateChange(func(sc dome.StateChange) {
+//		if sc.To == dome.StateDegraded {
+//			alert("agent degraded: " + sc.Reason)
+//		}
+//	})
+func (c *Client) OnStateChange(fn func(StateChange)) (remove func()) {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	if c.stateWatchers == nil {
+		c.stateWatchers = make(map[int]func(StateChange))
+	}
+	id := c.nextStateWatcher
+	c.nextStateWatcher++
+	c.stateWatchers[id] = fn
+	return func() {
+		c.stateMu.Lock()
+		defer c.stateMu.Unlock()
+		delete(c.stateWatchers, id)
+	}
+}
+
+// transition moves the client to state to and notifies watchers. Once Close
+// has begun, only the move from StateStopping to StateStopped is allowed.
+// It returns the previous state and whether the state changed.
+func (c *Client) transition(to State, reason string, err error) (State, bool) {
+	return c.transitionIf(func(State) bool { return true }, to, reason, err)
+}
+
+// transitionIf is transition restricted to current states accepted by ok.
+func (c *Client) transitionIf(ok func(State) bool, to State, reason string, err error) (State, bool) {
+	c.stateMu.Lock()
+	from := c.state
+	allowed := from != to && ok(from)
+	switch from {
+	case StateStopping:
+		allowed = allowed && to == StateStopped
+	case StateStopped:
+		allowed = false
+	}
+	if !allowed {
+		c.stateMu.Unlock()
+		return from, false
+	}
+	c.state = to
+	c.stateChanges = append(c.stateChanges, StateChange{From: from, To: to, Reason: reason, Err: err, Time: time.Now()})
+	notify := !c.notifying
+	c.notifying = true
+	c.stateMu.Unlock()
+
+	c.logger.Debug("dome: client state changed", "from", from, "to", to, "reason", reason)
+	if notify {
+		c.notifyStateChanges()
+	}
+	return from, true
+}
+
+// notifyStateChanges delivers queued state changes to the watchers until
+// the queue is empty. Only one goroutine delivers at a time; transitions
+// made meanwhile, even by the watchers themselves, are queued for it, so
+// watchers see them in order.
+func (c *Client) notifyStateChanges() {
+	for {
+		c.stateMu.Lock()
+		if len(c.stateChanges) == 0 {
+			c.notifying = false
+			c.stateMu.Unlock()
+			return
+		}
+		change := c.stateChanges[0]
+		c.stateChanges = c.stateChanges[1:]
+		watchers := make([]func(StateChange), 0, len(c.stateWatchers))
+		for _, fn := range c.stateWatchers {
+			watchers = append(watchers, fn)
+		}
+		c.stateMu.Unlock()
+
+		for _, fn := range watchers {
+			fn(change)
+		}
+	}
+}
+
+// unblocked reports whether s is neither StateRevoked nor StateSuspended,
+// which only the agent's control plane status changes.
+func unblocked(s State) bool {
+	return s != StateRevoked && s != StateSuspended
+}
+
+// degrade moves an active client to StateDegraded.
+func (c *Client) degrade(reason string, err error) {
+	c.transitionIf(func(s State) bool {
+		return s == StateActive || s == StateRegistering
+	}, StateDegraded, reason, err)
+}
+
+// markStale moves an active or degraded client to StateStale.
+func (c *Client) markStale(deadline time.Time) {
+	c.transitionIf(func(s State) bool {
+		return s == StateActive || s == StateDegraded
+	}, StateStale, "missed heartbeat deadline "+deadline.Format(time.RFC3339), nil)
+}
+
+// restore moves a degraded or stale client back to StateActive.
+func (c *Client) restore(reason string) {
+	c.transitionIf(func(s State) bool {
+		return s == StateDegraded || s == StateStale
+	}, StateActive, reason, nil)
+}
@@ -0,0 +1,43 @@
+package dome
+
+import (
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+)
+
+func TestTransition_NotifiesInOrder(t *testing.T) {
+	c := &Client{logger: slog.New(slog.NewTextHandler(io.Discard, nil)), state: StateActive}
+	var changes []StateChange
+	c.OnStateChange(func(sc StateChange) { changes = append(changes, sc) })
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 200 {
+				c.degrade("test", nil)
+				c.restore("test")
+			}
+		}()
+	}
+	wg.Wait()
+
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	if c.notifying || len(c.stateChanges) != 0 {
+		t.Fatalf("%d changes undelivered", len(c.stateChanges))
+	}
+	from := StateActive
+	for i, sc := range changes {
+		if sc.From != from {
+			t.Fatalf("change %d = %s -> %s, want from %s", i, sc.From, sc.To, from)
+		}
+		from = sc.To
+	}
+	if from != c.state {
+		t.Errorf("last change to %s, state is %s", from, c.state)
+	}
+}
@@ -0,0 +1,169 @@
+package dome_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	dome "github.com/Dome-Systems/sdk-dome-go"
+	apiv1 "github.com/Dome-Systems/sdk-dome-go/internal/api"
+	"github.com/Dome-Systems/sdk-dome-go/internal/api/agentv1connect"
+)
+
+// stateRecorder collects the states a client moves through.
+type stateRecorder struct {
+	mu      sync.Mutex
+	states  []dome.State
+	changed chan dome.StateChange
+}
+
+func recordStates(client *dome.Client) *stateRecorder {
+	r := &stateRecorder{changed: make(chan dome.StateChange, 64)}
+	client.OnStateChange(func(sc dome.StateChange) {
+		r.mu.Lock()
+		r.states = append(r.states, sc.To)
+		r.mu.Unlock()
+		r.changed <- sc
+	})
+	return r
+}
+
+func (r *stateRecorder) all() []dome.State {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.states)
+}
+
+// waitFor waits until the client enters state.
+func (r *stateRecorder) waitFor(t *testing.T, state dome.State) dome.StateChange {
+	t.Helper()
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case sc := <-r.changed:
+			if sc.To == state {
+				return sc
+			}
+		case <-timeout:
+			t.Fatalf("client did not enter %s; states: %v", state, r.all())
+		}
+	}
+}
+
+func TestState_Lifecycle(t *testing.T) {
+	_, url := registryServer(t)
+	client, err := dome.NewClient(dome.WithAPIKey("test-key"), dome.WithAPIURL(url), dome.WithoutHeartbeat())
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	if got := client.State(); got != dome.StateInitialized {
+		t.Errorf("State after NewClient = %s, want initialized", got)
+	}
+	states := recordStates(client)
+
+	if _, err := client.Start(context.Background(), dome.StartOptions{Name: "lifecycle"}); err != nil {
+		t.Fatalf("Start error: %v", err)
+	}
+	if got := client.State(); got != dome.StateActive {
+		t.Errorf("State after Start = %s, want active", got)
+	}
+	if err := client.Close(); err != nil {
+		t.Fatalf("Close error: %v", err)
+	}
+	_ = client.Close()
+
+	want := []dome.State{dome.StateRegistering, dome.StateActive, dome.StateStopping, dome.StateStopped}
+	if got := states.all(); !slices.Equal(got, want) {
+		t.Errorf("transitions = %v, want %v", got, want)
+	}
+}
+
+func TestState_DegradedOnHeartbeatFailure(t *testing.T) {
+	var failing atomic.Bool
+	mux := http.NewServeMux()
+	mux.Handle(agentv1connect.NewAgentRegistryHandler(newMockHandler()))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/dome.agent.v1.AgentRegistry/Heartbeat" && failing.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := dome.NewClient(
+		dome.WithAPIKey("test-key"),
+		dome.WithAPIURL(server.URL),
+		dome.WithHeartbeatInterval(20*time.Millisecond),
+	)
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+	states := recordStates(client)
+
+	if _, err := client.Start(context.Background(), dome.StartOptions{Name: "flaky"}); err != nil {
+		t.Fatalf("Start error: %v", err)
+	}
+	failing.Store(true)
+	sc := states.waitFor(t, dome.StateDegraded)
+	if sc.From != dome.StateActive || sc.Err == nil {
+		t.Errorf("degraded transition = %+v, want from active with an error", sc)
+	}
+
+	failing.Store(false)
+	states.waitFor(t, dome.StateActive)
+}
+
+func TestState_DegradedDuringBackgroundRegistration(t *testing.T) {
+	client, err := dome.NewClient(
+		dome.WithAPIKey("test-key"),
+		dome.WithAPIURL("http://127.0.0.1:1"),
+		dome.WithGracefulDegradation(),
+		dome.WithoutHeartbeat(),
+	)
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	if _, err := client.Start(context.Background(), dome.StartOptions{Name: "offline"}); err != nil {
+		t.Fatalf("Start error: %v", err)
+	}
+	if got := client.State(); got != dome.StateDegraded {
+		t.Errorf("State = %s, want degraded", got)
+	}
+}
+
+func TestState_StartKeepsRevoked(t *testing.T) {
+	handler, url := registryServer(t)
+	client, err := dome.NewClient(dome.WithAPIKey("test-key"), dome.WithAPIURL(url), dome.WithHeartbeatInterval(20*time.Millisecond))
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+	states := recordStates(client)
+
+	info, err := client.Start(context.Background(), dome.StartOptions{Name: "revocable"})
+	if err != nil {
+		t.Fatalf("Start error: %v", err)
+	}
+	handler.setAgentStatus(info.ID, apiv1.AgentStatus_AGENT_STATUS_REVOKED)
+	states.waitFor(t, dome.StateRevoked)
+
+	if _, err := client.Start(context.Background(), dome.StartOptions{Name: "revocable"}); err != nil {
+		t.Fatalf("second Start error: %v", err)
+	}
+	if got := client.State(); got != dome.StateRevoked {
+		t.Errorf("State after second Start = %s, want revoked", got)
+	}
+	want := []dome.State{dome.StateRegistering, dome.StateActive, dome.StateRevoked}
+	if got := states.all(); !slices.Equal(got, want) {
+		t.Errorf("transitions = %v, want %v", got, want)
+	}
+}
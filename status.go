@@ -0,0 +1,133 @@
+package dome
+
+import (
+	"context"
+	"time"
+
+	"connectrpc.com/connect"
+
+	apiv1 "github.com/Dome-Systems/sdk-dome-go/internal/api"
+)
+
+// agentInactive reports whether the control plane rejected a heartbeat in a
+// way that suggests the agent was revoked or suspended.
+func agentInactive(err error) bool {
+	switch connect.CodeOf(err) {
+	case connect.CodePermissionDenied, connect.CodeNotFound,
+		connect.CodeFailedPrecondition, connect.CodeUnauthenticated:
+		return true
+	}
+	return false
+}
+
+// blockedStatus returns the agent's status if it is revoked or suspended,
+// or AgentStatusUnknown otherwise.
+func (c *Client) blockedStatus() AgentStatus {
+	switch c.State() {
+	case StateRevoked:
+		return AgentStatusRevoked
+	case StateSuspended:
+		return AgentStatusSuspended
+	}
+	return AgentStatusUnknown
+}
+
+// runStatusRefresh refreshes the agent's status at the heartbeat interval
+// until ctx is canceled. Heartbeats alone do not reveal every revocation or
+// suspension: a control plane may keep accepting them, and WithoutHeartbeat
+// sends none.
+func (c *Client) runStatusRefresh(ctx context.Context, agentID string) {
+	ticker := time.NewTicker(c.config.heartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.refreshAgentStatus(ctx, agentID)
+		}
+	}
+}
+
+// refreshAgentStatus fetches the agent's status from the control plane and
+// applies it.
+func (c *Client) refreshAgentStatus(ctx context.Context, agentID string) {
+	if agent, ok := c.fetchAgentStatus(ctx, agentID); ok {
+		c.setAgentStatus(agent, false)
+	}
+}
+
+// fetchAgentStatus fetches an agent from the control plane to learn its
+// status. An agent the control plane no longer knows counts as revoked.
+// Failures are logged and reported as !ok.
+func (c *Client) fetchAgentStatus(ctx context.Context, agentID string) (*Agent, bool) {
+	resp, err := c.rpc.GetAgent(ctx, connect.NewRequest(&apiv1.GetAgentRequest{Id: agentID}))
+	switch {
+	case connect.CodeOf(err) == connect.CodeNotFound:
+		return &Agent{ID: agentID, Status: AgentStatusRevoked}, true
+	case err != nil:
+		if ctx.Err() == nil {
+			c.logger.Debug("dome: agent status refresh failed", "agent_id", agentID, "error", err)
+		}
+		return nil, false
+	}
+	return agentToSDK(resp.Msg.GetAgent()), true
+}
+
+// setAgentStatus moves the client to the state matching the agent's
+// control plane status and, when the agent becomes blocked or unblocked,
+// logs the change, reports an event and runs the matching callback. An
+// active status only lifts a revocation or suspension, so a refresh does not
+// pull the client out of StateDegraded or StateStale while heartbeats fail;
+// registered is set when the agent has just registered, which activates the
+// client from any state.
+func (c *Client) setAgentStatus(agent *Agent, registered bool) {
+	to, reason := StateActive, "agent is "+string(agent.Status)
+	switch agent.Status {
+	case AgentStatusRevoked:
+		to, reason = StateRevoked, "agent revoked in control plane"
+	case AgentStatusSuspended:
+		to, reason = StateSuspended, "agent suspended in control plane"
+	case AgentStatusUnknown:
+		reason = "agent registered"
+	}
+	from, changed := c.transitionIf(func(s State) bool {
+		return registered || to != StateActive || !unblocked(s)
+	}, to, reason, nil)
+	if !changed {
+		return
+	}
+
+	switch {
+	case to == StateRevoked:
+		c.logger.Warn("dome: agent revoked, denying all checks", "agent_id", agent.ID)
+		c.reportEventForAgent(context.Background(), agent.ID, "agent.revoked")
+		if c.config.onRevoked != nil {
+			c.config.onRevoked(agent)
+		}
+	case to == StateSuspended:
+		c.logger.Warn("dome: agent suspended, denying all checks", "agent_id", agent.ID)
+		c.reportEventForAgent(context.Background(), agent.ID, "agent.suspended")
+		if c.config.onSuspended != nil {
+			c.config.onSuspended(agent)
+		}
+	case from == StateRevoked || from == StateSuspended:
+		c.logger.Info("dome: agent reactivated", "agent_id", agent.ID, "status", agent.Status)
+		c.reportEventForAgent(context.Background(), agent.ID, "agent.reactivated")
+		if c.config.onReactivated != nil {
+			c.config.onReactivated(agent)
+		}
+	}
+}
+
+// agentFromInfo converts a registration to an Agent.
+func agentFromInfo(info *AgentInfo) *Agent {
+	return &Agent{
+		ID:           info.ID,
+		Name:         info.Name,
+		Status:       agentStatusFromProto(apiv1.AgentStatus(apiv1.AgentStatus_value[info.Status])),
+		Capabilities: info.Capabilities,
+		Metadata:     info.Metadata,
+		Runtime:      info.Runtime,
+	}
+}
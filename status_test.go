@@ -0,0 +1,180 @@
+package dome_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+
+	dome "github.com/Dome-Systems/sdk-dome-go"
+	apiv1 "github.com/Dome-Systems/sdk-dome-go/internal/api"
+)
+
+func TestAgentStatus_SuspendAndReactivate(t *testing.T) {
+	handler, url := registryServer(t)
+	suspended := make(chan *dome.Agent, 1)
+	reactivated := make(chan *dome.Agent, 1)
+	client, err := dome.NewClient(
+		dome.WithAPIKey("test-key"),
+		dome.WithAPIURL(url),
+		dome.WithHeartbeatInterval(20*time.Millisecond),
+		dome.WithOnSuspended(func(a *dome.Agent) { suspended <- a }),
+		dome.WithOnReactivated(func(a *dome.Agent) { reactivated <- a }),
+	)
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	ctx := context.Background()
+	info, err := client.Start(ctx, dome.StartOptions{Name: "suspendable"})
+	if err != nil {
+		t.Fatalf("Start error: %v", err)
+	}
+	req := dome.CheckRequest{Action: "llm:invoke", Resource: "gpt-4"}
+	if d, _ := client.Check(ctx, req); !d.Allowed {
+		t.Fatalf("Check before suspension denied: %s", d.Reason)
+	}
+
+	handler.setAgentStatus(info.ID, apiv1.AgentStatus_AGENT_STATUS_SUSPENDED)
+	select {
+	case a := <-suspended:
+		if a.ID != info.ID || a.Status != dome.AgentStatusSuspended {
+			t.Errorf("OnSuspended agent = %s %q", a.ID, a.Status)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("OnSuspended not called")
+	}
+	d, err := client.Check(ctx, req)
+	if err != nil {
+		t.Fatalf("Check error: %v", err)
+	}
+	if d.Allowed || !strings.Contains(d.Reason, "suspended") {
+		t.Errorf("Check while suspended = %v (%s), want denied", d.Allowed, d.Reason)
+	}
+
+	handler.setAgentStatus(info.ID, apiv1.AgentStatus_AGENT_STATUS_ACTIVE)
+	select {
+	case <-reactivated:
+	case <-time.After(2 * time.Second):
+		t.Fatal("OnReactivated not called")
+	}
+	if d, _ := client.Check(ctx, req); !d.Allowed {
+		t.Errorf("Check after reactivation denied: %s", d.Reason)
+	}
+}
+
+func TestAgentStatus_Revoked(t *testing.T) {
+	handler, url := registryServer(t)
+	revoked := make(chan *dome.Agent, 1)
+	client, err := dome.NewClient(
+		dome.WithAPIKey("test-key"),
+		dome.WithAPIURL(url),
+		dome.WithHeartbeatInterval(20*time.Millisecond),
+		dome.WithOnRevoked(func(a *dome.Agent) { revoked <- a }),
+	)
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	ctx := context.Background()
+	info, err := client.Start(ctx, dome.StartOptions{Name: "revocable"})
+	if err != nil {
+		t.Fatalf("Start error: %v", err)
+	}
+	handler.setAgentStatus(info.ID, apiv1.AgentStatus_AGENT_STATUS_REVOKED)
+	select {
+	case <-revoked:
+	case <-time.After(2 * time.Second):
+		t.Fatal("OnRevoked not called")
+	}
+	d, _ := client.Check(ctx, dome.CheckRequest{Action: "llm:invoke", Resource: "gpt-4"})
+	if d.Allowed || d.Reason != "agent is revoked" {
+		t.Errorf("Check while revoked = %v (%s), want denied", d.Allowed, d.Reason)
+	}
+}
+
+// lenientHeartbeats is a registry that accepts heartbeats from suspended
+// and revoked agents.
+type lenientHeartbeats struct {
+	*mockHandler
+}
+
+func (lenientHeartbeats) Heartbeat(context.Context, *connect.Request[apiv1.HeartbeatRequest]) (*connect.Response[apiv1.HeartbeatResponse], error) {
+	return connect.NewResponse(&apiv1.HeartbeatResponse{}), nil
+}
+
+func TestAgentStatus_DetectedWithoutHeartbeatRejection(t *testing.T) {
+	for name, opt := range map[string]dome.Option{
+		"heartbeats accepted": dome.WithHeartbeatInterval(20 * time.Millisecond),
+		"without heartbeat":   dome.WithoutHeartbeat(),
+	} {
+		t.Run(name, func(t *testing.T) {
+			handler := newMockHandler()
+			suspended := make(chan *dome.Agent, 1)
+			client, err := dome.NewClient(
+				dome.WithAPIKey("test-key"),
+				dome.WithAPIURL(serveRegistry(t, lenientHeartbeats{handler})),
+				dome.WithHeartbeatInterval(20*time.Millisecond),
+				opt,
+				dome.WithOnSuspended(func(a *dome.Agent) { suspended <- a }),
+			)
+			if err != nil {
+				t.Fatalf("NewClient error: %v", err)
+			}
+			defer func() { _ = client.Close() }()
+
+			info, err := client.Start(context.Background(), dome.StartOptions{Name: "suspendable"})
+			if err != nil {
+				t.Fatalf("Start error: %v", err)
+			}
+			handler.setAgentStatus(info.ID, apiv1.AgentStatus_AGENT_STATUS_SUSPENDED)
+			select {
+			case <-suspended:
+			case <-time.After(2 * time.Second):
+				t.Fatal("OnSuspended not called")
+			}
+			if s := client.State(); s != dome.StateSuspended {
+				t.Errorf("state = %v, want suspended", s)
+			}
+		})
+	}
+}
+
+// unavailableHeartbeats is a registry whose heartbeat endpoint is down while
+// the rest of the API still answers.
+type unavailableHeartbeats struct {
+	*mockHandler
+}
+
+func (unavailableHeartbeats) Heartbeat(context.Context, *connect.Request[apiv1.HeartbeatRequest]) (*connect.Response[apiv1.HeartbeatResponse], error) {
+	return nil, connect.NewError(connect.CodeUnavailable, nil)
+}
+
+func TestAgentStatus_RefreshKeepsDegraded(t *testing.T) {
+	client, err := dome.NewClient(
+		dome.WithAPIKey("test-key"),
+		dome.WithAPIURL(serveRegistry(t, unavailableHeartbeats{newMockHandler()})),
+		dome.WithHeartbeatInterval(20*time.Millisecond),
+	)
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+	states := recordStates(client)
+
+	if _, err := client.Start(context.Background(), dome.StartOptions{Name: "degraded"}); err != nil {
+		t.Fatalf("Start error: %v", err)
+	}
+	states.waitFor(t, dome.StateDegraded)
+
+	// The status refresh reports the agent active while heartbeats keep
+	// failing; the client stays degraded.
+	time.Sleep(200 * time.Millisecond)
+	if s := client.State(); s != dome.StateDegraded {
+		t.Errorf("state = %v, want degraded (states: %v)", s, states.all())
+	}
+}
@@ -0,0 +1,69 @@
+package dome
+
+import (
+	"context"
+	"strings"
+
+	"github.com/Dome-Systems/sdk-dome-go/internal/policy"
+)
+
+// UpdateOptions describes changes to the running agent. Nil fields are left
+// unchanged.
+type UpdateOptions struct {
+	Description *string
+	// Capabilities replaces the agent's capabilities. An empty, non-nil
+	// slice is an error: the control plane cannot remove them all.
+	Capabilities []string
+	// Metadata replaces the agent's metadata. An empty, non-nil map is an
+	// error, as for Capabilities.
+	Metadata map[string]string
+}
+
+// Update changes the running agent's definition in the control plane, e.g.
+// when a plugin that needs new capabilities loads. Once the control plane
+// accepts the change, Check evaluates with the updated capabilities; checks
+// running concurrently see either the old or the new agent. Update reports
+// an "agent.updated" event. Start must have completed first.
+func (c *Client) Update(ctx context.Context, opts UpdateOptions) (*AgentInfo, error) {
+	agentID := c.AgentID()
+	if agentID == "" {
+		return nil, errorf("update: agent is not registered")
+	}
+
+	agent, err := c.Agents().Update(ctx, agentID, AgentUpdate{
+		Description:  opts.Description,
+		Capabilities: opts.Capabilities,
+		Metadata:     opts.Metadata,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.agentCtx = policy.AgentContext{
+		ID:           agentID,
+		TenantID:     c.agentCtx.TenantID,
+		Capabilities: agent.Capabilities,
+	}
+	info := &AgentInfo{
+		ID:           agent.ID,
+		Name:         agent.Name,
+		Status:       agentStatusToProto(agent.Status).String(),
+		Capabilities: agent.Capabilities,
+		Metadata:     agent.Metadata,
+		Runtime:      agent.Runtime,
+	}
+	if c.info != nil {
+		info.Token = c.info.Token
+	}
+	c.info = info
+	c.mu.Unlock()
+	// Inbound calls from this agent must not be judged by the old record.
+	c.callers.invalidate(agentID)
+
+	c.logger.Info("dome: agent updated", "agent_id", agentID, "capabilities", agent.Capabilities)
+	c.reportEventData(ctx, agentID, "agent.updated", map[string]any{
+		"capabilities": strings.Join(agent.Capabilities, ","),
+	})
+	return info, nil
+}
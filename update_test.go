@@ -0,0 +1,88 @@
+package dome_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	dome "github.com/Dome-Systems/sdk-dome-go"
+	"github.com/Dome-Systems/sdk-dome-go/internal/api/agentv1connect"
+	"github.com/Dome-Systems/sdk-dome-go/internal/policy"
+)
+
+func TestUpdate_RefreshesCapabilities(t *testing.T) {
+	handler := newMockHandler()
+	mux := http.NewServeMux()
+	mux.Handle(agentv1connect.NewAgentRegistryHandler(handler))
+	mux.HandleFunc("/api/v1/policies/bundle", func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(policy.BundleResponse{
+			Version:  "v1",
+			Policies: []policy.PolicyFile{{Filename: "caps.cedar", Content: capabilityCedar}},
+		})
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	client := startedClient(t, server.URL, "llm:chat")
+	ctx := context.Background()
+
+	search := dome.CheckRequest{Action: "mcp:call", Resource: "web/search"}
+	if d, _ := client.Check(ctx, search); d.Allowed {
+		t.Fatal("mcp:call allowed before update")
+	}
+
+	info, err := client.Update(ctx, dome.UpdateOptions{Capabilities: []string{"llm:chat", "mcp:call"}})
+	if err != nil {
+		t.Fatalf("Update error: %v", err)
+	}
+	if !slices.Equal(info.Capabilities, []string{"llm:chat", "mcp:call"}) {
+		t.Errorf("Capabilities = %v", info.Capabilities)
+	}
+	if d, _ := client.Check(ctx, search); !d.Allowed {
+		t.Errorf("mcp:call denied after update: %s", d.Reason)
+	}
+	if !slices.Contains(handler.eventTypes(), "agent.updated") {
+		t.Errorf("events = %v, want agent.updated", handler.eventTypes())
+	}
+}
+
+func TestUpdate_NotRegistered(t *testing.T) {
+	_, url := registryServer(t)
+	if _, err := adminClient(t, url).Update(context.Background(), dome.UpdateOptions{}); err == nil {
+		t.Error("Update before Start returned nil error")
+	}
+}
+
+func TestUpdate_RejectsEmptyLists(t *testing.T) {
+	handler, url := registryServer(t)
+	client := startedClient(t, url, "llm:chat")
+	ctx := context.Background()
+
+	// Nil capabilities are left unchanged.
+	info, err := client.Update(ctx, dome.UpdateOptions{Metadata: map[string]string{"team": "ml"}})
+	if err != nil {
+		t.Fatalf("Update error: %v", err)
+	}
+	if !slices.Equal(info.Capabilities, []string{"llm:chat"}) {
+		t.Errorf("Capabilities after metadata update = %v, want unchanged", info.Capabilities)
+	}
+
+	// Empty lists mean "unchanged" on the wire, so clearing is refused
+	// rather than silently ignored.
+	for name, opts := range map[string]dome.UpdateOptions{
+		"capabilities": {Capabilities: []string{}},
+		"metadata":     {Metadata: map[string]string{}},
+	} {
+		if _, err := client.Update(ctx, opts); err == nil {
+			t.Errorf("Update with empty %s returned nil error", name)
+		}
+	}
+	handler.mu.Lock()
+	defer handler.mu.Unlock()
+	if n := len(handler.updates); n != 1 {
+		t.Errorf("UpdateAgent called %d times, want 1", n)
+	}
+}
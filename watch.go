@@ -0,0 +1,244 @@
+package dome
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"maps"
+	"slices"
+	"time"
+
+	"connectrpc.com/connect"
+
+	apiv1 "github.com/Dome-Systems/sdk-dome-go/internal/api"
+)
+
+const (
+	watchRetryBase = 250 * time.Millisecond
+	watchRetryMax  = 30 * time.Second
+	// watchHealthyAfter is how long a stream that delivers no events must
+	// stay up for the reconnect backoff to reset.
+	watchHealthyAfter = 10 * time.Second
+)
+
+// WatchAgents returns an iterator over changes to agents matching filter.
+// Unlike Agents.Watch it survives stream failures: it reconnects with
+// exponential backoff and, on every reconnect, lists the matching agents
+// and yields events for whatever changed while disconnected, so no change
+// is missed. Such synthesized events may repeat changes already delivered
+// by the stream; an agent that stops matching the filter is reported as
+// updated (or revoked).
+//
+// The agents matching filter when the watch starts are not reported. The
+// iterator ends when ctx is done or the loop exits. Errors that
+// reconnecting cannot fix (connect.CodeUnauthenticated,
+// CodePermissionDenied and CodeUnimplemented) end it too, yielded as the
+// final element with a zero event.
+//
+//	for event, err := range client.WatchAgents(ctx, dome.WatchFilter{}) {
+//		if err != nil {
+//			return err
+//		}
+//		log.Printf("%s %s", event.Type, event.Agent.Name)
+//	}
+func (c *Client) WatchAgents(ctx context.Context, filter WatchFilter) iter.Seq2[AgentEvent, error] {
+	return func(yield func(AgentEvent, error) bool) {
+		w := &agentWatch{client: c, filter: filter}
+		w.run(ctx, yield)
+	}
+}
+
+// agentWatch is the state of one WatchAgents iteration.
+type agentWatch struct {
+	client *Client
+	filter WatchFilter
+	// known holds the agents matching the filter as last observed; nil
+	// until the first listing.
+	known map[string]*Agent
+}
+
+func (w *agentWatch) run(ctx context.Context, yield func(AgentEvent, error) bool) {
+	failures := 0
+	for {
+		healthy, stop, err := w.session(ctx, yield)
+		if err != nil {
+			yield(AgentEvent{}, errorf("watch agents: %w", err))
+			return
+		}
+		if stop || ctx.Err() != nil {
+			return
+		}
+		if healthy {
+			failures = 0
+		} else {
+			failures++
+		}
+
+		wait := backoff(watchRetryBase, watchRetryMax, failures)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// session opens one stream, resynchronizes and delivers events until the
+// stream ends. healthy reports that the stream delivered an event or stayed
+// up for watchHealthyAfter; stop that the consumer or ctx ended the watch.
+// err is set when the watch cannot continue.
+func (w *agentWatch) session(ctx context.Context, yield func(AgentEvent, error) bool) (healthy, stop bool, err error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	started := time.Now()
+
+	// Open the stream before listing so changes made during the listing
+	// are delivered by the stream. A server stream only returns once the
+	// server sends its headers, which may not happen before the first
+	// event, so receive in the background.
+	var streamErr error
+	received := make(chan *apiv1.AgentEvent, 64)
+	go w.receive(ctx, received, &streamErr)
+
+	missed, err := w.resync(ctx)
+	if err != nil {
+		if ctx.Err() != nil {
+			return false, true, nil
+		}
+		if permanentWatchError(err) {
+			return false, true, err
+		}
+		w.client.logger.Warn("dome: agent watch resync failed, reconnecting", "error", err)
+		return false, false, nil
+	}
+	for _, e := range missed {
+		if !yield(e, nil) {
+			return false, true, nil
+		}
+	}
+
+	for msg := range received {
+		healthy = true
+		e := agentEventToSDK(msg)
+		w.track(e.Agent)
+		if !yield(e, nil) {
+			return true, true, nil
+		}
+	}
+	if ctx.Err() != nil {
+		return healthy, true, nil
+	}
+	if permanentWatchError(streamErr) {
+		return healthy, true, streamErr
+	}
+	w.client.logger.Warn("dome: agent watch interrupted, reconnecting", "error", streamErr)
+	return healthy || time.Since(started) >= watchHealthyAfter, false, nil
+}
+
+// permanentWatchError reports whether err means reconnecting is futile.
+func permanentWatchError(err error) bool {
+	switch connect.CodeOf(err) {
+	case connect.CodeUnauthenticated, connect.CodePermissionDenied, connect.CodeUnimplemented:
+		return true
+	}
+	return false
+}
+
+// receive forwards stream events to received until the stream ends or ctx
+// is done, then stores the stream error in errp and closes received.
+func (w *agentWatch) receive(ctx context.Context, received chan<- *apiv1.AgentEvent, errp *error) {
+	defer close(received)
+	stream, err := w.client.rpc.WatchAgents(ctx, connect.NewRequest(watchRequest(w.filter)))
+	if err != nil {
+		*errp = err
+		return
+	}
+	defer func() { _ = stream.Close() }()
+	for stream.Receive() {
+		select {
+		case received <- stream.Msg():
+		case <-ctx.Done():
+			return
+		}
+	}
+	*errp = stream.Err()
+}
+
+// resync lists the agents matching the filter and returns events for the
+// differences from the last observed state.
+func (w *agentWatch) resync(ctx context.Context) ([]AgentEvent, error) {
+	agents := w.client.Agents()
+	current := make(map[string]*Agent)
+	for agent, err := range agents.List(ctx, ListAgentsOptions{Status: w.filter.Status, ParentID: w.filter.ParentID}) {
+		if err != nil {
+			return nil, err
+		}
+		current[agent.ID] = agent
+	}
+	if w.known == nil {
+		w.known = current
+		return nil, nil
+	}
+
+	now := time.Now()
+	var events []AgentEvent
+	for _, id := range slices.Sorted(maps.Keys(current)) {
+		agent := current[id]
+		old, ok := w.known[id]
+		switch {
+		case !ok:
+			events = append(events, AgentEvent{Type: AgentEventRegistered, Agent: agent, Time: now})
+		case agentChanged(old, agent):
+			events = append(events, AgentEvent{Type: changeType(old, agent), Agent: agent, Time: now})
+		}
+	}
+	for _, id := range slices.Sorted(maps.Keys(w.known)) {
+		if _, ok := current[id]; ok {
+			continue
+		}
+		// The agent no longer matches the filter; find out why.
+		old := w.known[id]
+		agent, err := agents.Get(ctx, id)
+		if errors.Is(err, ErrAgentNotFound) {
+			gone := *old
+			gone.Status = AgentStatusRevoked
+			agent, err = &gone, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, AgentEvent{Type: changeType(old, agent), Agent: agent, Time: now})
+	}
+	w.known = current
+	return events, nil
+}
+
+// track records an agent delivered by the stream.
+func (w *agentWatch) track(agent *Agent) {
+	if agent == nil || w.known == nil {
+		return
+	}
+	if (w.filter.Status == AgentStatusUnknown || agent.Status == w.filter.Status) &&
+		(w.filter.ParentID == "" || agent.ParentID == w.filter.ParentID) {
+		w.known[agent.ID] = agent
+	} else {
+		delete(w.known, agent.ID)
+	}
+}
+
+func changeType(old, agent *Agent) AgentEventType {
+	if agent.Status == AgentStatusRevoked && old.Status != AgentStatusRevoked {
+		return AgentEventRevoked
+	}
+	return AgentEventUpdated
+}
+
+func agentChanged(old, agent *Agent) bool {
+	return !old.UpdatedAt.Equal(agent.UpdatedAt) ||
+		old.Name != agent.Name ||
+		old.ParentID != agent.ParentID ||
+		old.Status != agent.Status ||
+		old.Connection != agent.Connection ||
+		!slices.Equal(old.Capabilities, agent.Capabilities) ||
+		!maps.Equal(old.Metadata, agent.Metadata)
+}
@@ -0,0 +1,123 @@
+package dome_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+
+	dome "github.com/Dome-Systems/sdk-dome-go"
+	apiv1 "github.com/Dome-Systems/sdk-dome-go/internal/api"
+)
+
+func TestWatchAgents_ResyncsAfterReconnect(t *testing.T) {
+	handler, url := registryServer(t)
+	handler.agents["a1"] = &apiv1.Agent{Id: "a1", Name: "planner", Status: apiv1.AgentStatus_AGENT_STATUS_ACTIVE}
+	client := adminClient(t, url)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := make(chan dome.AgentEvent, 8)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for e, err := range client.WatchAgents(ctx, dome.WatchFilter{}) {
+			if err != nil {
+				t.Errorf("WatchAgents error: %v", err)
+				return
+			}
+			events <- e
+		}
+	}()
+	next := func() dome.AgentEvent {
+		t.Helper()
+		select {
+		case e := <-events:
+			return e
+		case <-time.After(2 * time.Second):
+			t.Fatal("no event within 2s")
+			return dome.AgentEvent{}
+		}
+	}
+
+	handler.watch <- &apiv1.AgentEvent{
+		Type:  apiv1.AgentEventType_AGENT_EVENT_TYPE_UPDATED,
+		Agent: &apiv1.Agent{Id: "a1", Name: "planner-v2", Status: apiv1.AgentStatus_AGENT_STATUS_ACTIVE},
+	}
+	if e := next(); e.Type != dome.AgentEventUpdated || e.Agent.Name != "planner-v2" {
+		t.Fatalf("event = %+v, want update of a1", e)
+	}
+
+	// Changes made while the stream is down are recovered by resync.
+	handler.mu.Lock()
+	handler.agents["a1"].Name = "planner-v2"
+	handler.agents["a1"].Status = apiv1.AgentStatus_AGENT_STATUS_REVOKED
+	handler.agents["a2"] = &apiv1.Agent{Id: "a2", Name: "worker", Status: apiv1.AgentStatus_AGENT_STATUS_ACTIVE}
+	handler.mu.Unlock()
+	handler.watch <- nil
+
+	if e := next(); e.Type != dome.AgentEventRevoked || e.Agent.ID != "a1" {
+		t.Errorf("event = %+v, want revocation of a1", e)
+	}
+	if e := next(); e.Type != dome.AgentEventRegistered || e.Agent.ID != "a2" {
+		t.Errorf("event = %+v, want registration of a2", e)
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("watch did not stop after cancel")
+	}
+}
+
+// failingWatcher is a registry whose WatchAgents fails immediately.
+type failingWatcher struct {
+	*mockHandler
+	code  connect.Code
+	calls atomic.Int32
+}
+
+func (h *failingWatcher) WatchAgents(context.Context, *connect.Request[apiv1.WatchAgentsRequest], *connect.ServerStream[apiv1.AgentEvent]) error {
+	h.calls.Add(1)
+	return connect.NewError(h.code, nil)
+}
+
+func TestWatchAgents_StopsOnPermanentError(t *testing.T) {
+	handler := &failingWatcher{mockHandler: newMockHandler(), code: connect.CodePermissionDenied}
+	client := adminClient(t, serveRegistry(t, handler))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	var errs []error
+	for _, err := range client.WatchAgents(ctx, dome.WatchFilter{}) {
+		errs = append(errs, err)
+	}
+	if ctx.Err() != nil {
+		t.Fatal("watch did not stop on PermissionDenied")
+	}
+	if len(errs) != 1 || connect.CodeOf(errs[0]) != connect.CodePermissionDenied {
+		t.Errorf("errors = %v, want one PermissionDenied", errs)
+	}
+	if n := handler.calls.Load(); n != 1 {
+		t.Errorf("stream opened %d times, want 1", n)
+	}
+}
+
+func TestWatchAgents_BacksOffWhenStreamFailsImmediately(t *testing.T) {
+	handler := &failingWatcher{mockHandler: newMockHandler(), code: connect.CodeUnavailable}
+	client := adminClient(t, serveRegistry(t, handler))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1500*time.Millisecond)
+	defer cancel()
+	for _, err := range client.WatchAgents(ctx, dome.WatchFilter{}) {
+		t.Errorf("WatchAgents error: %v", err)
+	}
+	// Backoff grows from 500ms because a stream that fails at once is not
+	// healthy; a reset backoff would reconnect every 250ms.
+	if n := handler.calls.Load(); n > 4 {
+		t.Errorf("stream opened %d times in 1.5s, want backoff", n)
+	}
+}